@@ -0,0 +1,291 @@
+package main
+
+import (
+        "errors"
+        "fmt"
+        "log"
+        "net"
+        "net/netip"
+        "sort"
+        "strings"
+        "time"
+)
+
+// 地址选择方式 (AddrPolicy.Select)
+const (
+        selectFirst           = "first"
+        selectIndex           = "index"
+        selectLongestLifetime = "longest_lifetime"
+)
+
+// IPv6 地址策略 (AddrPolicy.IPv6Policy)
+const (
+        ipv6PolicyStable        = "stable"         // 优先使用没有临时、弃用、暂定标志的地址 (默认)
+        ipv6PolicyEUI64         = "eui64"          // 同 stable, 并优先使用 EUI-64 地址
+        ipv6PolicyStablePrivacy = "stable_privacy" // 同 stable, 并优先使用 RFC 7217 稳定隐私地址
+        ipv6PolicyAny           = "any"            // 不按标志过滤 (旧行为)
+)
+
+// stable 策略下 IPv6 地址的稳定等级, 数值越小越稳定
+// 有稳定地址时只在稳定地址中选取, 否则退而使用等级最低的地址并给出警告
+const (
+        levelStable     = 0
+        levelTransient  = 1 // temporary 或 tentative: 可用, 但会轮换或尚未完成 DAD
+        levelDeprecated = 2 // deprecated: 首选生命周期已结束, 即将失效
+)
+
+// errCGNATOnly 表示接口上唯一的公网候选地址都位于 CGNAT 共享地址段
+var errCGNATOnly = errors.New("only CGNAT addresses found")
+
+// AddrPolicy 控制从接口上的多个地址中挑选哪一个
+type AddrPolicy struct {
+        // IncludeCIDRs 非空时只考虑这些网段内的地址, 按列表顺序决定优先级
+        IncludeCIDRs []string `json:"include_cidrs,omitempty"`
+        // ExcludeCIDRs 中网段内的地址总是被排除 (先于 include_cidrs 判断)
+        ExcludeCIDRs []string `json:"exclude_cidrs,omitempty"`
+        // Select 为 "first" (默认), "index" 或 "longest_lifetime"
+        Select string `json:"select,omitempty"`
+        // Index 是 select=index 时选取的候选地址序号 (从 0 开始)
+        Index int `json:"index,omitempty"`
+        // IPv6Policy 为 "stable" (默认), "eui64", "stable_privacy" 或 "any"
+        IPv6Policy string `json:"ipv6_policy,omitempty"`
+        // MinPreferredLifetime 要求地址的剩余首选生命周期至少为该秒数 (0 表示不限制)
+        MinPreferredLifetime int `json:"min_preferred_lifetime,omitempty"`
+}
+
+// addrSelector 是解析后的 AddrPolicy
+type addrSelector struct {
+        include     []netip.Prefix
+        exclude     []netip.Prefix
+        mode        string
+        index       int
+        ipv6Policy  string
+        minLifetime time.Duration
+}
+
+// newAddrSelector 解析并校验地址选择策略
+func newAddrSelector(p AddrPolicy) (*addrSelector, error) {
+        s := &addrSelector{
+                mode:        p.Select,
+                index:       p.Index,
+                ipv6Policy:  p.IPv6Policy,
+                minLifetime: time.Duration(p.MinPreferredLifetime) * time.Second,
+        }
+        var err error
+        if s.include, err = parseCIDRs(p.IncludeCIDRs); err != nil {
+                return nil, fmt.Errorf("invalid 'include_cidrs': %w", err)
+        }
+        if s.exclude, err = parseCIDRs(p.ExcludeCIDRs); err != nil {
+                return nil, fmt.Errorf("invalid 'exclude_cidrs': %w", err)
+        }
+        switch p.Select {
+        case "":
+                s.mode = selectFirst
+        case selectFirst, selectLongestLifetime:
+        case selectIndex:
+                if p.Index < 0 {
+                        return nil, fmt.Errorf("invalid 'index' (%d), must not be negative", p.Index)
+                }
+        default:
+                return nil, fmt.Errorf("invalid 'select' ('%s'), must be '%s', '%s' or '%s'", p.Select, selectFirst, selectIndex, selectLongestLifetime)
+        }
+        switch p.IPv6Policy {
+        case "":
+                s.ipv6Policy = ipv6PolicyStable
+        case ipv6PolicyStable, ipv6PolicyEUI64, ipv6PolicyStablePrivacy, ipv6PolicyAny:
+        default:
+                return nil, fmt.Errorf("invalid 'ipv6_policy' ('%s'), must be '%s', '%s', '%s' or '%s'",
+                        p.IPv6Policy, ipv6PolicyStable, ipv6PolicyEUI64, ipv6PolicyStablePrivacy, ipv6PolicyAny)
+        }
+        if p.MinPreferredLifetime < 0 {
+                return nil, fmt.Errorf("invalid 'min_preferred_lifetime' (%d), must not be negative", p.MinPreferredLifetime)
+        }
+        return s, nil
+}
+
+// parseCIDRs 解析 CIDR 列表, 单个地址视为 /32 或 /128
+func parseCIDRs(cidrs []string) ([]netip.Prefix, error) {
+        prefixes := make([]netip.Prefix, 0, len(cidrs))
+        for _, c := range cidrs {
+                c = strings.TrimSpace(c)
+                if !strings.Contains(c, "/") {
+                        addr, err := netip.ParseAddr(c)
+                        if err != nil {
+                                return nil, err
+                        }
+                        prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+                        continue
+                }
+                p, err := netip.ParsePrefix(c)
+                if err != nil {
+                        return nil, err
+                }
+                prefixes = append(prefixes, p.Masked())
+        }
+        return prefixes, nil
+}
+
+// matchPrefix 返回第一个包含地址的网段序号, 没有匹配时返回 -1
+func matchPrefix(prefixes []netip.Prefix, addr netip.Addr) int {
+        for i, p := range prefixes {
+                if p.Contains(addr) {
+                        return i
+                }
+        }
+        return -1
+}
+
+// selectAddr 从地址列表中挑选一个可发布的公网地址
+// 依次过滤地址族、scope、特殊用途地址、exclude_cidrs、include_cidrs, 再按 select 策略在候选中选取
+func (s *addrSelector) selectAddr(addrs []InterfaceAddr, ipversion string) (InterfaceAddr, error) {
+        if s == nil {
+                s = &addrSelector{mode: selectFirst, ipv6Policy: ipv6PolicyStable}
+        }
+        nowStr := time.Now().Format("2006-01-02 15:04:05")
+
+        type candidate struct {
+                addr       InterfaceAddr
+                rank       int
+                preference int
+                level      int
+                reason     string // level 非 0 时的原因
+        }
+        var candidates []candidate
+        sawCGNAT, sawPublic := false, false
+        for _, addr := range addrs {
+                if !matchesIPVersion(addr.IP, ipversion) {
+                        continue
+                }
+                ip, _ := toNetipAddr(addr.IP)
+                if addr.Scope != scopeUniverse {
+                        log.Printf("[%s] ℹ️ Skipping non-global IP %s (scope %s)", nowStr, ip, addr.Scope)
+                        continue
+                }
+                if reason := unusableIPReason(ip.String()); reason != "" {
+                        sawCGNAT = sawCGNAT || isCGNAT(addr.IP)
+                        log.Printf("[%s] ℹ️ Skipping IP %s: %s", nowStr, ip, reason)
+                        continue
+                }
+                sawPublic = true
+                if reason := s.excludeReason(addr); reason != "" {
+                        log.Printf("[%s] ℹ️ Skipping IP %s: %s", nowStr, ip, reason)
+                        continue
+                }
+                if i := matchPrefix(s.exclude, ip); i >= 0 {
+                        log.Printf("[%s] ℹ️ Skipping IP %s: matches exclude_cidrs entry %s", nowStr, ip, s.exclude[i])
+                        continue
+                }
+                rank := 0
+                if len(s.include) > 0 {
+                        if rank = matchPrefix(s.include, ip); rank < 0 {
+                                log.Printf("[%s] ℹ️ Skipping IP %s: not in include_cidrs", nowStr, ip)
+                                continue
+                        }
+                }
+                level, reason := s.stability(addr)
+                if level != levelStable {
+                        log.Printf("[%s] ℹ️ Deprioritizing IP %s: %s", nowStr, ip, reason)
+                }
+                candidates = append(candidates, candidate{addr: addr, rank: rank, preference: s.preference(addr), level: level, reason: reason})
+        }
+
+        if len(candidates) == 0 {
+                if sawCGNAT && !sawPublic {
+                        return InterfaceAddr{}, errCGNATOnly
+                }
+                return InterfaceAddr{}, fmt.Errorf("no usable public %s address among %d addresses", ipversion, len(addrs))
+        }
+
+        // 只保留最稳定的一级; 没有稳定地址时退而使用不稳定的地址
+        minLevel := candidates[0].level
+        for _, c := range candidates[1:] {
+                minLevel = min(minLevel, c.level)
+        }
+        stable := candidates[:0]
+        for _, c := range candidates {
+                if c.level == minLevel {
+                        stable = append(stable, c)
+                }
+        }
+        candidates = stable
+
+        // include_cidrs 中靠前的网段优先, 其次是 ipv6_policy 偏好的地址, 其余保持原有顺序
+        sort.SliceStable(candidates, func(i, j int) bool {
+                if candidates[i].rank != candidates[j].rank {
+                        return candidates[i].rank < candidates[j].rank
+                }
+                return candidates[i].preference < candidates[j].preference
+        })
+
+        best := candidates[0]
+        switch s.mode {
+        case selectIndex:
+                if s.index >= len(candidates) {
+                        return InterfaceAddr{}, fmt.Errorf("index %d out of range, only %d candidate addresses", s.index, len(candidates))
+                }
+                best = candidates[s.index]
+        case selectLongestLifetime:
+                for _, c := range candidates[1:] {
+                        if c.addr.PreferredLifetime > best.addr.PreferredLifetime {
+                                best = c
+                        }
+                }
+        }
+        if best.level != levelStable {
+                log.Printf("[%s] ⚠️ Warning: No stable %s address available; falling back to %s (%s). It may change soon.", nowStr, ipversion, best.addr.IP, best.reason)
+        }
+        return best.addr, nil
+}
+
+// excludeReason 返回地址不满足策略而被排除的原因, 满足时返回空字符串
+// DAD 失败的地址与其他主机冲突, 内核不会使用它, 因此除 any 策略外总是排除
+func (s *addrSelector) excludeReason(addr InterfaceAddr) string {
+        if addr.PreferredLifetime < s.minLifetime {
+                return fmt.Sprintf("preferred lifetime %s is below min_preferred_lifetime %s", formatLifetime(addr.PreferredLifetime), s.minLifetime)
+        }
+        if addr.IP.To4() == nil && s.ipv6Policy != ipv6PolicyAny && addr.Flags&flagDADFailed != 0 {
+                return "duplicate address detection failed (dadfailed)"
+        }
+        return ""
+}
+
+// stability 返回地址在 stable 策略下的稳定等级及原因
+func (s *addrSelector) stability(addr InterfaceAddr) (int, string) {
+        if addr.IP.To4() != nil || s.ipv6Policy == ipv6PolicyAny {
+                return levelStable, ""
+        }
+        level := levelStable
+        if addr.Flags&(flagTemporary|flagTentative) != 0 {
+                level = levelTransient
+        }
+        if addr.Flags&flagDeprecated != 0 {
+                level = levelDeprecated
+        }
+        if level == levelStable {
+                return level, ""
+        }
+        flags := addr.Flags & (flagTemporary | flagTentative | flagDeprecated)
+        return level, fmt.Sprintf("unstable IPv6 address (%s)", formatAddrFlags(addr.IP, flags))
+}
+
+// preference 返回地址在 ipv6_policy 下的偏好等级, 数值越小越优先
+func (s *addrSelector) preference(addr InterfaceAddr) int {
+        switch s.ipv6Policy {
+        case ipv6PolicyEUI64:
+                if isEUI64(addr.IP) {
+                        return 0
+                }
+                return 1
+        case ipv6PolicyStablePrivacy:
+                if addr.Flags&flagStablePrivacy != 0 {
+                        return 0
+                }
+                return 1
+        }
+        return 0
+}
+
+// isEUI64 判断 IPv6 地址的接口标识符是否由 MAC 地址按 EUI-64 规则生成 (中间为 ff:fe)
+func isEUI64(ip net.IP) bool {
+        return ip.To4() == nil && len(ip) == net.IPv6len && ip[11] == 0xff && ip[12] == 0xfe
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+        "errors"
+        "net"
+        "strings"
+        "testing"
+        "time"
+)
+
+// v4Addr 构造一个 IPv4 全局地址
+func v4Addr(ip string, preferred time.Duration) InterfaceAddr {
+        return InterfaceAddr{IP: net.ParseIP(ip), PrefixLen: 29, Scope: scopeUniverse, PreferredLifetime: preferred, ValidLifetime: preferred}
+}
+
+// hostAddrs 模拟一个有路由 /29、VPN 地址和私有地址的接口
+var hostAddrs = []InterfaceAddr{
+        v4Addr("192.168.1.10", lifetimeForever),
+        {IP: net.ParseIP("127.0.0.1"), PrefixLen: 8, Scope: scopeHost, PreferredLifetime: lifetimeForever},
+        v4Addr("45.80.16.1", 600*time.Second),
+        v4Addr("45.80.16.2", lifetimeForever),
+        v4Addr("45.80.16.3", 3600*time.Second),
+        v4Addr("185.12.4.7", lifetimeForever), // VPN
+}
+
+func TestSelectAddr(t *testing.T) {
+        tests := []struct {
+                name    string
+                policy  AddrPolicy
+                want    string
+                wantErr string
+        }{
+                {"first public", AddrPolicy{}, "45.80.16.1", ""},
+                {"exclude", AddrPolicy{ExcludeCIDRs: []string{"45.80.16.0/30"}}, "185.12.4.7", ""},
+                {"exclude single address", AddrPolicy{ExcludeCIDRs: []string{"45.80.16.1"}}, "45.80.16.2", ""},
+                {"include order wins", AddrPolicy{IncludeCIDRs: []string{"185.12.4.0/24", "45.80.16.0/29"}}, "185.12.4.7", ""},
+                {"exclude before include", AddrPolicy{IncludeCIDRs: []string{"185.12.4.0/24", "45.80.16.0/29"}, ExcludeCIDRs: []string{"185.12.4.7"}}, "45.80.16.1", ""},
+                {"include matches nothing", AddrPolicy{IncludeCIDRs: []string{"8.8.8.0/24"}}, "", "no usable public ipv4 address among 6 addresses"},
+                {"index", AddrPolicy{Select: selectIndex, Index: 2}, "45.80.16.3", ""},
+                {"index within include", AddrPolicy{IncludeCIDRs: []string{"45.80.16.0/29"}, Select: selectIndex, Index: 1}, "45.80.16.2", ""},
+                {"index out of range", AddrPolicy{Select: selectIndex, Index: 4}, "", "index 4 out of range, only 4 candidate addresses"},
+                {"longest lifetime", AddrPolicy{Select: selectLongestLifetime}, "45.80.16.2", ""},
+                {"longest lifetime finite", AddrPolicy{Select: selectLongestLifetime, IncludeCIDRs: []string{"45.80.16.1", "45.80.16.3"}}, "45.80.16.3", ""},
+                {"min preferred lifetime", AddrPolicy{MinPreferredLifetime: 1800}, "45.80.16.2", ""},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        s, err := newAddrSelector(tt.policy)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        got, err := s.selectAddr(hostAddrs, "ipv4")
+                        if tt.wantErr != "" {
+                                if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                                        t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+                                }
+                                return
+                        }
+                        if err != nil || got.IP.String() != tt.want {
+                                t.Fatalf("got %v, %v; want %s", got.IP, err, tt.want)
+                        }
+                })
+        }
+}
+
+func TestSelectAddrCGNATOnly(t *testing.T) {
+        addrs := []InterfaceAddr{v4Addr("10.0.0.2", lifetimeForever), v4Addr("100.72.3.4", lifetimeForever)}
+        if _, err := (*addrSelector)(nil).selectAddr(addrs, "ipv4"); !errors.Is(err, errCGNATOnly) {
+                t.Fatalf("error = %v, want errCGNATOnly", err)
+        }
+}
+
+func TestNewAddrSelectorValidation(t *testing.T) {
+        for _, p := range []AddrPolicy{
+                {IncludeCIDRs: []string{"45.80.16.0/33"}},
+                {ExcludeCIDRs: []string{"not-an-ip"}},
+                {Select: "random"},
+                {Select: selectIndex, Index: -1},
+                {IPv6Policy: "privacy"},
+                {MinPreferredLifetime: -1},
+        } {
+                if _, err := newAddrSelector(p); err == nil {
+                        t.Errorf("newAddrSelector(%+v) should fail", p)
+                }
+        }
+}
+
+// v6Addr 构造一个带标志的 IPv6 全局地址
+func v6Addr(ip string, flags addrFlags, preferred time.Duration) InterfaceAddr {
+        return InterfaceAddr{IP: net.ParseIP(ip), PrefixLen: 64, Scope: scopeUniverse, Flags: flags, PreferredLifetime: preferred, ValidLifetime: lifetimeForever}
+}
+
+func TestSelectAddrIPv6Policy(t *testing.T) {
+        temporary := v6Addr("2a01:4f8:1:2::a1b2", flagTemporary, 3600*time.Second)
+        deprecated := v6Addr("2a01:4f8:1:2::dead", flagDeprecated, 0)
+        dadfailed := v6Addr("2a01:4f8:1:2::bad", flagDADFailed|flagTentative, 0)
+        eui64 := v6Addr("2a01:4f8:1:2:211:22ff:fe33:4455", 0, lifetimeForever)
+        privacy := v6Addr("2a01:4f8:1:2:c3d4:e5f6:1:2", flagStablePrivacy, lifetimeForever)
+
+        tests := []struct {
+                name   string
+                policy AddrPolicy
+                addrs  []InterfaceAddr
+                want   string
+        }{
+                {"stable skips temporary", AddrPolicy{}, []InterfaceAddr{temporary, privacy, eui64}, privacy.IP.String()},
+                {"eui64 preferred", AddrPolicy{IPv6Policy: ipv6PolicyEUI64}, []InterfaceAddr{temporary, privacy, eui64}, eui64.IP.String()},
+                {"stable privacy preferred", AddrPolicy{IPv6Policy: ipv6PolicyStablePrivacy}, []InterfaceAddr{eui64, privacy}, privacy.IP.String()},
+                {"any keeps order", AddrPolicy{IPv6Policy: ipv6PolicyAny}, []InterfaceAddr{temporary, eui64}, temporary.IP.String()},
+                {"falls back to temporary", AddrPolicy{}, []InterfaceAddr{deprecated, temporary}, temporary.IP.String()},
+                {"falls back to deprecated last", AddrPolicy{}, []InterfaceAddr{dadfailed, deprecated}, deprecated.IP.String()},
+                {"index counts stable only", AddrPolicy{Select: selectIndex, Index: 1}, []InterfaceAddr{temporary, eui64, privacy}, privacy.IP.String()},
+                {"min lifetime still required", AddrPolicy{MinPreferredLifetime: 60}, []InterfaceAddr{deprecated, temporary}, temporary.IP.String()},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        s, err := newAddrSelector(tt.policy)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        got, err := s.selectAddr(tt.addrs, "ipv6")
+                        if err != nil || got.IP.String() != tt.want {
+                                t.Fatalf("got %v, %v; want %s", got.IP, err, tt.want)
+                        }
+                })
+        }
+
+        if _, err := (*addrSelector)(nil).selectAddr([]InterfaceAddr{dadfailed}, "ipv6"); err == nil {
+                t.Fatal("a dadfailed address must never be selected")
+        }
+}
@@ -0,0 +1,100 @@
+//go:build linux
+
+package main
+
+import (
+        "errors"
+        "fmt"
+        "log"
+        "net"
+        "os"
+        "syscall"
+        "time"
+)
+
+// rtnetlink 多播组, syscall 包中没有定义
+const (
+        rtmgrpIPv4IfAddr = 0x10  // RTMGRP_IPV4_IFADDR
+        rtmgrpIPv6IfAddr = 0x100 // RTMGRP_IPV6_IFADDR
+)
+
+// addrWatcher 订阅 rtnetlink 地址变化通知 (RTM_NEWADDR/RTM_DELADDR),
+// 在被监听接口的地址变化时向 C 发送通知 (多次变化会合并为一次)
+type addrWatcher struct {
+        C    <-chan struct{}
+        file *os.File
+}
+
+// watchAddrChanges 开始监听指定接口的地址变化
+func watchAddrChanges(ifaces []string) (*addrWatcher, error) {
+        fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK, syscall.NETLINK_ROUTE)
+        if err != nil {
+                return nil, fmt.Errorf("creating netlink socket failed: %w", err)
+        }
+        sa := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: rtmgrpIPv4IfAddr | rtmgrpIPv6IfAddr}
+        if err := syscall.Bind(fd, sa); err != nil {
+                syscall.Close(fd)
+                return nil, fmt.Errorf("subscribing to address notifications failed: %w", err)
+        }
+
+        // 非阻塞 fd 交给 os.File 后由运行时轮询, Close 可以中断正在等待的 Read
+        file := os.NewFile(uintptr(fd), "rtnetlink")
+        names := make(map[string]bool, len(ifaces))
+        for _, name := range ifaces {
+                names[name] = true
+        }
+        c := make(chan struct{}, 1)
+        w := &addrWatcher{C: c, file: file}
+        go w.loop(c, names)
+        return w, nil
+}
+
+// Close 停止监听
+func (w *addrWatcher) Close() {
+        w.file.Close()
+}
+
+func (w *addrWatcher) loop(c chan<- struct{}, ifaces map[string]bool) {
+        notify := func() {
+                select {
+                case c <- struct{}{}:
+                default: // A notification is already pending
+                }
+        }
+
+        buf := make([]byte, 64*1024)
+        for {
+                n, err := w.file.Read(buf)
+                if err != nil {
+                        if errors.Is(err, os.ErrClosed) {
+                                return
+                        }
+                        if errors.Is(err, syscall.ENOBUFS) {
+                                // The socket buffer overflowed and notifications were lost; assume something changed
+                                notify()
+                                continue
+                        }
+                        log.Printf("[%s] ⚠️ Address change notifications stopped, relying on periodic checks: %v", time.Now().Format("2006-01-02 15:04:05"), err)
+                        return
+                }
+                msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+                if err != nil {
+                        continue
+                }
+                for i := range msgs {
+                        m := &msgs[i]
+                        if m.Header.Type != syscall.RTM_NEWADDR && m.Header.Type != syscall.RTM_DELADDR {
+                                continue
+                        }
+                        addr, index, ok := parseAddrMessage(m)
+                        if !ok || addr.Scope == scopeLink || addr.Scope == scopeHost {
+                                continue // Link-local and loopback addresses never end up in DNS
+                        }
+                        // 接口已被删除时 (如 PPPoE 断线) 无法解析名称, 同样视为相关变化
+                        if ifi, err := net.InterfaceByIndex(index); err == nil && !ifaces[ifi.Name] {
+                                continue
+                        }
+                        notify()
+                }
+        }
+}
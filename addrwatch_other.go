@@ -0,0 +1,18 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// addrWatcher 在非 Linux 系统上不可用, 守护进程只依赖定时检查
+type addrWatcher struct {
+        C <-chan struct{}
+}
+
+// watchAddrChanges 地址变化通知依赖 rtnetlink, 仅在 Linux 上支持
+func watchAddrChanges(ifaces []string) (*addrWatcher, error) {
+        return nil, errors.New("address change notifications are only supported on Linux")
+}
+
+// Close 停止监听
+func (w *addrWatcher) Close() {}
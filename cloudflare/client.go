@@ -0,0 +1,174 @@
+// Package cloudflare 是 Cloudflare API v4 的精简客户端, 覆盖 DDNS 所需的区域和 DNS 记录操作
+//
+// 所有方法都接受 context.Context, 失败的 API 调用返回 *APIError, 可以用 errors.Is / errors.As
+// 按 Cloudflare 错误码分支处理, 例如:
+//
+//	if errors.Is(err, cloudflare.ErrRecordAlreadyExists) { ... }
+package cloudflare
+
+import (
+        "bytes"
+        "context"
+        "encoding/json"
+        "fmt"
+        "io"
+        "net/http"
+        "strings"
+        "time"
+)
+
+// DefaultBaseURL 是 Cloudflare API v4 的地址
+const DefaultBaseURL = "https://api.cloudflare.com/client/v4"
+
+// sharedTransport 在所有客户端间共享, 保持到 API 的长连接 (keep-alive, HTTP/2)
+var sharedTransport = func() *http.Transport {
+        transport := http.DefaultTransport.(*http.Transport).Clone()
+        transport.ForceAttemptHTTP2 = true
+        transport.MaxIdleConnsPerHost = 4
+        transport.IdleConnTimeout = 90 * time.Second
+        transport.TLSHandshakeTimeout = 10 * time.Second
+        transport.ResponseHeaderTimeout = 20 * time.Second
+        return transport
+}()
+
+// Client 是 Cloudflare API 客户端, 可以在多个 goroutine 间共享
+// 创建后可以修改导出字段, 但不要在请求进行中修改
+type Client struct {
+        BaseURL    string       // 默认 DefaultBaseURL, 可指向模拟服务器
+        Token      string       // API Token
+        HTTPClient *http.Client // 默认使用共享的 Transport
+        Retry      RetryPolicy  // 默认 DefaultRetryPolicy()
+        // Logf 接收重试等诊断信息, nil 时不输出
+        Logf func(format string, args ...interface{})
+}
+
+// NewClient 使用默认设置创建客户端
+func NewClient(token string) *Client {
+        return &Client{
+                BaseURL:    DefaultBaseURL,
+                Token:      token,
+                HTTPClient: &http.Client{Transport: sharedTransport, Timeout: 30 * time.Second},
+                Retry:      DefaultRetryPolicy(),
+        }
+}
+
+// ResultInfo 是列表接口的分页信息
+type ResultInfo struct {
+        Page       int `json:"page"`
+        PerPage    int `json:"per_page"`
+        Count      int `json:"count"`
+        TotalCount int `json:"total_count"`
+        TotalPages int `json:"total_pages"`
+}
+
+// response 是 API 响应的通用外层结构
+type response struct {
+        Success    bool            `json:"success"`
+        Errors     []APIError      `json:"errors"`
+        Result     json.RawMessage `json:"result"`
+        ResultInfo *ResultInfo     `json:"result_info"`
+}
+
+// do 执行 API 请求并把 result 解码到 out (可为 nil), 返回分页信息 (如果有)
+// path 相对于 BaseURL (e.g. "/zones?name=example.com"), body 非 nil 时编码为 JSON 请求体
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) (*ResultInfo, error) {
+        var payload []byte
+        if body != nil {
+                var err error
+                if payload, err = json.Marshal(body); err != nil {
+                        return nil, fmt.Errorf("encoding request body failed: %w", err)
+                }
+        }
+
+        resp, data, err := c.request(ctx, method, path, payload)
+        if err != nil {
+                return nil, err
+        }
+
+        var envelope response
+        if err := json.Unmarshal(data, &envelope); err != nil {
+                if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+                        return nil, &APIError{StatusCode: resp.StatusCode, Message: resp.Status}
+                }
+                return nil, fmt.Errorf("parsing %s %s response failed: %w", method, path, err)
+        }
+        if !envelope.Success || resp.StatusCode < 200 || resp.StatusCode >= 300 {
+                return nil, newAPIErrors(resp, envelope.Errors)
+        }
+        if out != nil && len(envelope.Result) > 0 {
+                if err := json.Unmarshal(envelope.Result, out); err != nil {
+                        return nil, fmt.Errorf("parsing %s %s result failed: %w", method, path, err)
+                }
+        }
+        return envelope.ResultInfo, nil
+}
+
+// request 执行一次逻辑请求: 网络错误、5xx 和 429 按重试策略退避重试 (非幂等的 POST 只在 429 时重试)
+// ctx 取消时立即返回
+func (c *Client) request(ctx context.Context, method, path string, payload []byte) (*http.Response, []byte, error) {
+        urlStr := strings.TrimSuffix(c.BaseURL, "/") + path
+        for attempt := 1; ; attempt++ {
+                resp, body, err := c.requestOnce(ctx, method, urlStr, payload)
+                failed := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+                if !failed || ctx.Err() != nil || attempt >= c.Retry.MaxAttempts || !c.Retry.shouldRetry(method, resp) {
+                        return resp, body, err
+                }
+
+                reason := ""
+                if err != nil {
+                        reason = err.Error()
+                } else {
+                        reason = "status " + resp.Status
+                }
+                wait, ok := c.Retry.delay(attempt, resp)
+                if !ok {
+                        c.logf("%s %s failed (%s) and the server asks to wait %s, longer than the retry limit. Giving up.", method, urlStr, reason, wait)
+                        return resp, body, err
+                }
+                c.logf("%s %s failed (%s), attempt %d/%d. Retrying in %s...", method, urlStr, reason, attempt, c.Retry.MaxAttempts, wait.Round(time.Millisecond))
+                timer := time.NewTimer(wait)
+                select {
+                case <-timer.C:
+                case <-ctx.Done():
+                        timer.Stop()
+                        return nil, nil, ctx.Err()
+                }
+        }
+}
+
+// requestOnce 执行一次 HTTP 请求 (不重试)
+func (c *Client) requestOnce(ctx context.Context, method, urlStr string, data []byte) (*http.Response, []byte, error) {
+        var payload io.Reader
+        if data != nil {
+                payload = bytes.NewReader(data)
+        }
+        req, err := http.NewRequestWithContext(ctx, method, urlStr, payload)
+        if err != nil {
+                return nil, nil, fmt.Errorf("creating request failed: %w", err)
+        }
+        req.Header.Set("Authorization", "Bearer "+c.Token)
+        req.Header.Set("Content-Type", "application/json")
+        req.Header.Set("Accept", "application/json")
+
+        httpClient := c.HTTPClient
+        if httpClient == nil {
+                httpClient = http.DefaultClient
+        }
+        resp, err := httpClient.Do(req)
+        if err != nil {
+                return nil, nil, fmt.Errorf("request failed: %w", err)
+        }
+        defer resp.Body.Close()
+
+        body, err := io.ReadAll(resp.Body)
+        if err != nil {
+                return nil, nil, fmt.Errorf("reading response body failed (status: %s): %w", resp.Status, err)
+        }
+        return resp, body, nil
+}
+
+func (c *Client) logf(format string, args ...interface{}) {
+        if c.Logf != nil {
+                c.Logf(format, args...)
+        }
+}
@@ -0,0 +1,280 @@
+package cloudflare
+
+import (
+        "context"
+        "encoding/json"
+        "errors"
+        "fmt"
+        "net/http"
+        "net/http/httptest"
+        "sort"
+        "strconv"
+        "strings"
+        "sync"
+        "testing"
+)
+
+// mockAPI 是内存中的 Cloudflare API v4, 只实现客户端用到的区域和 DNS 记录接口
+// 列表接口每页固定返回 pageSize 条, 用于检查客户端的分页处理
+type mockAPI struct {
+        mu       sync.Mutex
+        token    string
+        pageSize int
+        zones    []Zone
+        records  map[string][]DNSRecord // Zone ID -> 记录
+        nextID   int
+        requests []string // "METHOD path?query"
+}
+
+func newMockAPI(t *testing.T) (*mockAPI, *Client) {
+        m := &mockAPI{
+                token:    "test-token",
+                pageSize: 2,
+                zones: []Zone{
+                        {ID: "z1", Name: "example.com", Status: "active"},
+                        {ID: "z2", Name: "example.net", Status: "active"},
+                        {ID: "z3", Name: "lab.example.com", Status: "active"},
+                },
+                records: map[string][]DNSRecord{},
+        }
+        srv := httptest.NewServer(m)
+        t.Cleanup(srv.Close)
+        c := NewClient(m.token)
+        c.BaseURL = srv.URL + "/client/v4/"
+        c.HTTPClient = srv.Client()
+        return m, c
+}
+
+func (m *mockAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+        m.mu.Lock()
+        defer m.mu.Unlock()
+        m.requests = append(m.requests, r.Method+" "+r.URL.RequestURI())
+
+        if r.Header.Get("Authorization") != "Bearer "+m.token {
+                m.fail(w, http.StatusForbidden, APIError{Code: CodeAuthentication, Message: "Authentication error"})
+                return
+        }
+        parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/client/v4/"), "/")
+        switch {
+        case len(parts) == 1 && parts[0] == "zones" && r.Method == "GET":
+                var zones []Zone
+                for _, z := range m.zones {
+                        if name := r.URL.Query().Get("name"); name == "" || name == z.Name {
+                                zones = append(zones, z)
+                        }
+                }
+                m.page(w, r, len(zones), func(i int) interface{} { return zones[i] })
+        case len(parts) == 2 && parts[0] == "zones" && r.Method == "GET":
+                for _, z := range m.zones {
+                        if z.ID == parts[1] {
+                                m.ok(w, z, nil)
+                                return
+                        }
+                }
+                m.fail(w, http.StatusNotFound, APIError{Code: 7003, Message: "Could not route to /zones/" + parts[1]})
+        case len(parts) == 3 && parts[2] == "dns_records":
+                m.serveRecords(w, r, parts[1])
+        case len(parts) == 4 && parts[2] == "dns_records":
+                m.serveRecord(w, r, parts[1], parts[3])
+        default:
+                m.fail(w, http.StatusNotFound, APIError{Code: 7000, Message: "No route for that URI"})
+        }
+}
+
+func (m *mockAPI) serveRecords(w http.ResponseWriter, r *http.Request, zoneID string) {
+        switch r.Method {
+        case "GET":
+                var records []DNSRecord
+                q := r.URL.Query()
+                for _, rec := range m.records[zoneID] {
+                        if (q.Get("type") == "" || q.Get("type") == rec.Type) && (q.Get("name") == "" || q.Get("name") == rec.Name) {
+                                records = append(records, rec)
+                        }
+                }
+                m.page(w, r, len(records), func(i int) interface{} { return records[i] })
+        case "POST":
+                var rec DNSRecord
+                if err := json.NewDecoder(r.Body).Decode(&rec); err != nil || rec.ID != "" {
+                        m.fail(w, http.StatusBadRequest, APIError{Code: 9207, Message: "Request body is invalid."})
+                        return
+                }
+                for _, existing := range m.records[zoneID] {
+                        if existing.Type == rec.Type && existing.Name == rec.Name && existing.Content == rec.Content {
+                                m.fail(w, http.StatusBadRequest, APIError{Code: CodeRecordAlreadyExists, Message: "An identical record already exists."})
+                                return
+                        }
+                }
+                m.nextID++
+                rec.ID = fmt.Sprintf("r%d", m.nextID)
+                m.records[zoneID] = append(m.records[zoneID], rec)
+                m.ok(w, rec, nil)
+        default:
+                m.fail(w, http.StatusMethodNotAllowed, APIError{Code: 10000, Message: "method not allowed"})
+        }
+}
+
+func (m *mockAPI) serveRecord(w http.ResponseWriter, r *http.Request, zoneID, recordID string) {
+        records := m.records[zoneID]
+        i := sort.Search(len(records), func(i int) bool { return records[i].ID >= recordID })
+        if i == len(records) || records[i].ID != recordID {
+                m.fail(w, http.StatusNotFound, APIError{Code: CodeRecordNotFound, Message: "Record does not exist."})
+                return
+        }
+        switch r.Method {
+        case "GET":
+                m.ok(w, records[i], nil)
+        case "PUT":
+                var rec DNSRecord
+                json.NewDecoder(r.Body).Decode(&rec)
+                rec.ID = recordID
+                records[i] = rec
+                m.ok(w, rec, nil)
+        case "PATCH":
+                // 只覆盖请求中出现的字段
+                json.NewDecoder(r.Body).Decode(&records[i])
+                m.ok(w, records[i], nil)
+        case "DELETE":
+                m.records[zoneID] = append(records[:i:i], records[i+1:]...)
+                m.ok(w, map[string]string{"id": recordID}, nil)
+        }
+}
+
+// page 按 page 参数返回一页结果和分页信息
+func (m *mockAPI) page(w http.ResponseWriter, r *http.Request, total int, item func(int) interface{}) {
+        page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+        if page < 1 {
+                page = 1
+        }
+        result := []interface{}{}
+        for i := (page - 1) * m.pageSize; i < total && i < page*m.pageSize; i++ {
+                result = append(result, item(i))
+        }
+        pages := (total + m.pageSize - 1) / m.pageSize
+        m.ok(w, result, &ResultInfo{Page: page, PerPage: m.pageSize, Count: len(result), TotalCount: total, TotalPages: pages})
+}
+
+func (m *mockAPI) ok(w http.ResponseWriter, result interface{}, info *ResultInfo) {
+        json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "errors": []APIError{}, "result": result, "result_info": info})
+}
+
+func (m *mockAPI) fail(w http.ResponseWriter, status int, errs ...APIError) {
+        w.WriteHeader(status)
+        json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "errors": errs, "result": nil})
+}
+
+func TestClientZones(t *testing.T) {
+        m, c := newMockAPI(t)
+        ctx := context.Background()
+
+        zones, err := c.ListZones(ctx, "")
+        if err != nil || len(zones) != 3 || zones[2].Name != "lab.example.com" {
+                t.Fatalf("ListZones = %+v, %v", zones, err)
+        }
+        if !strings.HasPrefix(m.requests[1], "GET /client/v4/zones?page=2&per_page=50") {
+                t.Fatalf("second page request = %q", m.requests[1])
+        }
+
+        zones, err = c.ListZones(ctx, "example.net")
+        if err != nil || len(zones) != 1 || zones[0].ID != "z2" {
+                t.Fatalf("ListZones(example.net) = %+v, %v", zones, err)
+        }
+        zone, err := c.GetZone(ctx, "z3")
+        if err != nil || zone.Name != "lab.example.com" {
+                t.Fatalf("GetZone = %+v, %v", zone, err)
+        }
+        if _, err := c.GetZone(ctx, "missing"); err == nil || !strings.Contains(err.Error(), "7003") {
+                t.Fatalf("GetZone(missing) error = %v", err)
+        }
+}
+
+func TestClientDNSRecords(t *testing.T) {
+        m, c := newMockAPI(t)
+        ctx := context.Background()
+
+        created, err := c.CreateDNSRecord(ctx, "z1", DNSRecord{ID: "ignored", Type: "A", Name: "www.example.com", Content: "9.9.9.9", TTL: 1})
+        if err != nil || created.ID != "r1" {
+                t.Fatalf("CreateDNSRecord = %+v, %v", created, err)
+        }
+        for _, name := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+                if _, err := c.CreateDNSRecord(ctx, "z1", DNSRecord{Type: "AAAA", Name: name, Content: "2606:4700::1", TTL: 300}); err != nil {
+                        t.Fatal(err)
+                }
+        }
+        _, err = c.CreateDNSRecord(ctx, "z1", DNSRecord{Type: "A", Name: "www.example.com", Content: "9.9.9.9", TTL: 1})
+        var apiErr *APIError
+        if !errors.Is(err, ErrRecordAlreadyExists) || !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+                t.Fatalf("duplicate create error = %v", err)
+        }
+
+        all, err := c.ListDNSRecords(ctx, "z1", DNSRecordFilter{Type: "AAAA"})
+        if err != nil || len(all) != 3 {
+                t.Fatalf("ListDNSRecords(AAAA) = %+v, %v", all, err)
+        }
+        found, err := c.ListDNSRecords(ctx, "z1", DNSRecordFilter{Type: "A", Name: "www.example.com"})
+        if err != nil || len(found) != 1 || found[0].Content != "9.9.9.9" {
+                t.Fatalf("ListDNSRecords(www) = %+v, %v", found, err)
+        }
+
+        updated, err := c.UpdateDNSRecord(ctx, "z1", "r1", DNSRecord{Type: "A", Name: "www.example.com", Content: "1.1.1.1", TTL: 120, Proxied: true})
+        if err != nil || updated.Content != "1.1.1.1" || !updated.Proxied || updated.ID != "r1" {
+                t.Fatalf("UpdateDNSRecord = %+v, %v", updated, err)
+        }
+        content := "8.8.8.8"
+        patched, err := c.PatchDNSRecord(ctx, "z1", "r1", DNSRecordPatch{Content: &content})
+        if err != nil || patched.Content != content || patched.TTL != 120 || !patched.Proxied {
+                t.Fatalf("PatchDNSRecord = %+v, %v", patched, err)
+        }
+        got, err := c.GetDNSRecord(ctx, "z1", "r1")
+        if err != nil || got.Content != content {
+                t.Fatalf("GetDNSRecord = %+v, %v", got, err)
+        }
+
+        if err := c.DeleteDNSRecord(ctx, "z1", "r1"); err != nil {
+                t.Fatal(err)
+        }
+        if _, err := c.GetDNSRecord(ctx, "z1", "r1"); !errors.Is(err, ErrRecordNotFound) {
+                t.Fatalf("GetDNSRecord after delete error = %v", err)
+        }
+        if last := m.requests[len(m.requests)-1]; last != "GET /client/v4/zones/z1/dns_records/r1" {
+                t.Fatalf("unexpected request %q", last)
+        }
+}
+
+func TestClientErrors(t *testing.T) {
+        _, c := newMockAPI(t)
+        c.Token = "wrong"
+        _, err := c.ListZones(context.Background(), "")
+        if !errors.Is(err, ErrAuthentication) || errors.Is(err, ErrRecordNotFound) {
+                t.Fatalf("error = %v, want ErrAuthentication", err)
+        }
+
+        // 一个响应中的多条错误: 每一条都可以用 errors.Is 匹配
+        srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                switch r.URL.Path {
+                case "/multi":
+                        w.WriteHeader(http.StatusBadRequest)
+                        w.Write([]byte(`{"success":false,"errors":[{"code":1004,"message":"DNS Validation Error"},{"code":81057,"message":"identical record"}]}`))
+                case "/html":
+                        w.WriteHeader(http.StatusBadGateway)
+                        w.Write([]byte("<html>bad gateway</html>"))
+                default:
+                        w.Write([]byte("not json"))
+                }
+        }))
+        defer srv.Close()
+        c = NewClient("t")
+        c.BaseURL, c.HTTPClient, c.Retry.MaxAttempts = srv.URL, srv.Client(), 1
+
+        _, err = c.do(context.Background(), "GET", "/multi", nil, nil)
+        if !errors.Is(err, ErrRecordAlreadyExists) || !strings.Contains(err.Error(), "1004") {
+                t.Fatalf("multi error = %v", err)
+        }
+        _, err = c.do(context.Background(), "GET", "/html", nil, nil)
+        if !errors.As(err, new(*APIError)) || !strings.Contains(err.Error(), "HTTP 502") {
+                t.Fatalf("non-JSON error response = %v", err)
+        }
+        _, err = c.do(context.Background(), "GET", "/ok", nil, nil)
+        if err == nil || !strings.Contains(err.Error(), "parsing GET /ok response failed") {
+                t.Fatalf("non-JSON success response = %v", err)
+        }
+}
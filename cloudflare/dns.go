@@ -0,0 +1,110 @@
+package cloudflare
+
+import (
+        "context"
+        "fmt"
+        "net/url"
+)
+
+// DNSRecord 是一条 DNS 记录
+type DNSRecord struct {
+        ID      string `json:"id,omitempty"`
+        Type    string `json:"type"`
+        Name    string `json:"name"`
+        Content string `json:"content"`
+        Proxied bool   `json:"proxied"`
+        TTL     int    `json:"ttl"` // 1 表示 automatic
+}
+
+// DNSRecordFilter 筛选 ListDNSRecords 的结果, 空字段不参与筛选
+type DNSRecordFilter struct {
+        Type string
+        Name string // 完整域名
+}
+
+// DNSRecordPatch 描述 PatchDNSRecord 要修改的字段, nil 字段保持不变
+type DNSRecordPatch struct {
+        Type    *string `json:"type,omitempty"`
+        Name    *string `json:"name,omitempty"`
+        Content *string `json:"content,omitempty"`
+        Proxied *bool   `json:"proxied,omitempty"`
+        TTL     *int    `json:"ttl,omitempty"`
+}
+
+func recordsPath(zoneID string) string {
+        return "/zones/" + url.PathEscape(zoneID) + "/dns_records"
+}
+
+func recordPath(zoneID, recordID string) string {
+        return recordsPath(zoneID) + "/" + url.PathEscape(recordID)
+}
+
+// ListDNSRecords 列出区域中符合筛选条件的记录; 自动处理分页
+func (c *Client) ListDNSRecords(ctx context.Context, zoneID string, filter DNSRecordFilter) ([]DNSRecord, error) {
+        query := url.Values{}
+        if filter.Type != "" {
+                query.Set("type", filter.Type)
+        }
+        if filter.Name != "" {
+                query.Set("name", filter.Name)
+        }
+        query.Set("per_page", "100")
+
+        var records []DNSRecord
+        for page := 1; ; page++ {
+                query.Set("page", fmt.Sprint(page))
+                var result []DNSRecord
+                info, err := c.do(ctx, "GET", recordsPath(zoneID)+"?"+query.Encode(), nil, &result)
+                if err != nil {
+                        return nil, err
+                }
+                records = append(records, result...)
+                if info == nil || page >= info.TotalPages {
+                        return records, nil
+                }
+        }
+}
+
+// GetDNSRecord 获取指定 ID 的记录
+func (c *Client) GetDNSRecord(ctx context.Context, zoneID, recordID string) (*DNSRecord, error) {
+        var record DNSRecord
+        if _, err := c.do(ctx, "GET", recordPath(zoneID, recordID), nil, &record); err != nil {
+                return nil, err
+        }
+        return &record, nil
+}
+
+// CreateDNSRecord 创建记录 (POST, 只在被限流时重试), 返回创建后的记录
+func (c *Client) CreateDNSRecord(ctx context.Context, zoneID string, record DNSRecord) (*DNSRecord, error) {
+        record.ID = ""
+        var created DNSRecord
+        if _, err := c.do(ctx, "POST", recordsPath(zoneID), record, &created); err != nil {
+                return nil, err
+        }
+        return &created, nil
+}
+
+// UpdateDNSRecord 用 record 整体替换指定记录 (PUT), 返回更新后的记录
+func (c *Client) UpdateDNSRecord(ctx context.Context, zoneID, recordID string, record DNSRecord) (*DNSRecord, error) {
+        record.ID = ""
+        var updated DNSRecord
+        if _, err := c.do(ctx, "PUT", recordPath(zoneID, recordID), record, &updated); err != nil {
+                return nil, err
+        }
+        return &updated, nil
+}
+
+// PatchDNSRecord 只修改 patch 中给出的字段 (PATCH), 返回更新后的记录
+func (c *Client) PatchDNSRecord(ctx context.Context, zoneID, recordID string, patch DNSRecordPatch) (*DNSRecord, error) {
+        var updated DNSRecord
+        if _, err := c.do(ctx, "PATCH", recordPath(zoneID, recordID), patch, &updated); err != nil {
+                return nil, err
+        }
+        return &updated, nil
+}
+
+// DeleteDNSRecord 删除指定记录
+func (c *Client) DeleteDNSRecord(ctx context.Context, zoneID, recordID string) error {
+        _, err := c.do(ctx, "DELETE", recordPath(zoneID, recordID), nil, nil)
+        return err
+}
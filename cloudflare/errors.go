@@ -0,0 +1,59 @@
+package cloudflare
+
+import (
+        "errors"
+        "fmt"
+        "net/http"
+)
+
+// 常用的 Cloudflare API 错误码
+const (
+        CodeAuthentication      = 10000 // 认证失败 (Token 无效或权限不足)
+        CodeRecordNotFound      = 81044 // 记录不存在
+        CodeRecordAlreadyExists = 81057 // 相同的记录已存在
+)
+
+// 用于 errors.Is 的错误码哨兵值
+var (
+        ErrAuthentication      = &APIError{Code: CodeAuthentication}
+        ErrRecordNotFound      = &APIError{Code: CodeRecordNotFound}
+        ErrRecordAlreadyExists = &APIError{Code: CodeRecordAlreadyExists}
+)
+
+// APIError 是 Cloudflare API 返回的一条错误
+// 一次响应包含多条错误时, 返回值是这些 *APIError 的 errors.Join
+type APIError struct {
+        StatusCode int    `json:"-"` // HTTP 状态码
+        Code       int    `json:"code"`
+        Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+        if e.Code == 0 {
+                return fmt.Sprintf("cloudflare API error (HTTP %d): %s", e.StatusCode, e.Message)
+        }
+        return fmt.Sprintf("cloudflare API error %d (HTTP %d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+// Is 让 errors.Is(err, ErrRecordAlreadyExists) 等按错误码匹配
+func (e *APIError) Is(target error) bool {
+        t, ok := target.(*APIError)
+        return ok && t.Code != 0 && t.Code == e.Code
+}
+
+// newAPIErrors 把响应中的错误列表转换为 error
+func newAPIErrors(resp *http.Response, apiErrs []APIError) error {
+        if len(apiErrs) == 0 {
+                return &APIError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+        }
+        errs := make([]error, len(apiErrs))
+        for i := range apiErrs {
+                apiErr := apiErrs[i]
+                apiErr.StatusCode = resp.StatusCode
+                errs[i] = &apiErr
+        }
+        if len(errs) == 1 {
+                return errs[0]
+        }
+        return errors.Join(errs...)
+}
@@ -0,0 +1,87 @@
+package cloudflare
+
+import (
+        "math/rand"
+        "net/http"
+        "strconv"
+        "strings"
+        "time"
+)
+
+// RetryPolicy 是请求失败后的重试策略: 指数退避 + 随机抖动, 遵守 Retry-After 和限流响应头
+// 只有幂等请求 (GET/PUT/PATCH/DELETE) 会在网络错误和 5xx 后重试; POST 只在 429 时重试,
+// 因为被限流的请求一定没有被处理, 而其他失败的 POST 可能已经创建了记录
+type RetryPolicy struct {
+        MaxAttempts int           // 最多尝试次数 (包括第一次), 1 表示不重试
+        BaseDelay   time.Duration // 第一次重试前的等待时间, 之后每次翻倍
+        MaxDelay    time.Duration // 单次等待的上限; 服务器要求等待更久时放弃重试
+}
+
+// DefaultRetryPolicy 返回默认的重试策略: 最多 4 次, 从 1 秒开始翻倍, 单次最多等待 30 秒
+func DefaultRetryPolicy() RetryPolicy {
+        return RetryPolicy{MaxAttempts: 4, BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+}
+
+// shouldRetry 判断一次失败的请求是否值得重试 (resp 为 nil 表示网络层错误)
+func (p RetryPolicy) shouldRetry(method string, resp *http.Response) bool {
+        if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+                return true // Rate-limited requests were not processed, even POST is safe to repeat
+        }
+        if method == http.MethodPost {
+                return false
+        }
+        return resp == nil || resp.StatusCode >= 500
+}
+
+// delay 返回第 attempt 次 (从 1 开始) 失败后的等待时间
+// 服务器给出的 Retry-After / 限流重置时间优先; 超过 MaxDelay 时返回 false 放弃重试
+func (p RetryPolicy) delay(attempt int, resp *http.Response) (time.Duration, bool) {
+        if wait, ok := serverRetryDelay(resp); ok {
+                return wait, wait <= p.MaxDelay
+        }
+        backoff := p.BaseDelay << (attempt - 1)
+        if backoff > p.MaxDelay || backoff <= 0 { // <= 0 guards against shift overflow
+                backoff = p.MaxDelay
+        }
+        if backoff <= 0 {
+                return 0, true
+        }
+        // Jitter: wait between half and the full backoff so concurrent clients spread out
+        half := backoff / 2
+        return half + time.Duration(rand.Int63n(int64(half)+1)), true
+}
+
+// serverRetryDelay 从响应头中读取服务器要求的等待时间:
+// Retry-After (秒数或 HTTP 日期), 以及 Cloudflare 的 Ratelimit ("default";r=0;t=30) 和 RateLimit-Reset
+func serverRetryDelay(resp *http.Response) (time.Duration, bool) {
+        if resp == nil {
+                return 0, false
+        }
+        if v := strings.TrimSpace(resp.Header.Get("Retry-After")); v != "" {
+                if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+                        return time.Duration(secs) * time.Second, true
+                }
+                if t, err := http.ParseTime(v); err == nil {
+                        wait := time.Until(t)
+                        if wait < 0 {
+                                wait = 0
+                        }
+                        return wait, true
+                }
+        }
+        if resp.StatusCode != http.StatusTooManyRequests {
+                return 0, false // Rate-limit headers only tell when to come back once the quota is exhausted
+        }
+        if v := resp.Header.Get("Ratelimit"); v != "" {
+                for _, param := range strings.Split(v, ";") {
+                        key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+                        if secs, err := strconv.Atoi(value); key == "t" && err == nil && secs >= 0 {
+                                return time.Duration(secs) * time.Second, true
+                        }
+                }
+        }
+        if secs, err := strconv.Atoi(strings.TrimSpace(resp.Header.Get("RateLimit-Reset"))); err == nil && secs >= 0 {
+                return time.Duration(secs) * time.Second, true
+        }
+        return 0, false
+}
@@ -0,0 +1,153 @@
+package cloudflare
+
+import (
+        "context"
+        "errors"
+        "net/http"
+        "net/http/httptest"
+        "strings"
+        "sync/atomic"
+        "testing"
+        "time"
+)
+
+// flakyServer 按顺序返回 statuses 中的状态码, 之后总是成功; 状态码 0 表示直接断开连接
+func flakyServer(t *testing.T, header http.Header, statuses ...int) (*httptest.Server, *atomic.Int32) {
+        t.Helper()
+        var calls atomic.Int32
+        srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                n := int(calls.Add(1))
+                if n <= len(statuses) {
+                        status := statuses[n-1]
+                        if status == 0 {
+                                conn, _, err := w.(http.Hijacker).Hijack()
+                                if err == nil {
+                                        conn.Close()
+                                }
+                                return
+                        }
+                        for k, v := range header {
+                                w.Header()[k] = v
+                        }
+                        w.WriteHeader(status)
+                        w.Write([]byte(`{"success":false,"errors":[{"code":10013,"message":"injected failure"}]}`))
+                        return
+                }
+                w.Write([]byte(`{"success":true,"errors":[],"result":{"id":"z1","name":"example.com","status":"active"}}`))
+        }))
+        t.Cleanup(srv.Close)
+        return srv, &calls
+}
+
+// testClient 返回指向 srv 的客户端, 重试等待缩短到毫秒级
+func testClient(srv *httptest.Server) *Client {
+        c := NewClient("test-token")
+        c.BaseURL = srv.URL
+        c.HTTPClient = srv.Client()
+        c.Retry = RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 50 * time.Millisecond}
+        return c
+}
+
+func TestRetry(t *testing.T) {
+        tests := []struct {
+                name      string
+                method    string
+                header    http.Header
+                statuses  []int
+                wantCalls int32
+                wantErr   bool
+        }{
+                {"get recovers from 5xx", "GET", nil, []int{502, 503}, 3, false},
+                {"get recovers from dropped connection", "GET", nil, []int{0}, 2, false},
+                {"get gives up after max attempts", "GET", nil, []int{500, 500, 500, 500}, 4, true},
+                {"get does not retry 4xx", "GET", nil, []int{403}, 1, true},
+                {"put retries 5xx", "PUT", nil, []int{500}, 2, false},
+                {"post does not retry 5xx", "POST", nil, []int{500}, 1, true},
+                {"post does not retry dropped connection", "POST", nil, []int{0}, 1, true},
+                {"post retries 429", "POST", nil, []int{429, 429}, 3, false},
+                {"retry-after within limit", "GET", http.Header{"Retry-After": {"0"}}, []int{503}, 2, false},
+                {"retry-after beyond limit", "GET", http.Header{"Retry-After": {"120"}}, []int{429}, 1, true},
+                {"ratelimit reset beyond limit", "DELETE", http.Header{"Ratelimit": {`"default";r=0;t=60`}}, []int{429}, 1, true},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        srv, calls := flakyServer(t, tt.header, tt.statuses...)
+                        var zone Zone
+                        _, err := testClient(srv).do(context.Background(), tt.method, "/zones/z1", nil, &zone)
+                        if (err != nil) != tt.wantErr {
+                                t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+                        }
+                        if got := calls.Load(); got != tt.wantCalls {
+                                t.Fatalf("server saw %d requests, want %d", got, tt.wantCalls)
+                        }
+                        if !tt.wantErr && zone.ID != "z1" {
+                                t.Fatalf("result not decoded: %+v", zone)
+                        }
+                })
+        }
+}
+
+func TestRetryStopsWhenContextCanceled(t *testing.T) {
+        srv, calls := flakyServer(t, nil, 503, 503, 503)
+        c := testClient(srv)
+        c.Retry.BaseDelay, c.Retry.MaxDelay = time.Hour, time.Hour
+        ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+        defer cancel()
+        start := time.Now()
+        _, err := c.GetZone(ctx, "z1")
+        if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 5*time.Second || calls.Load() != 1 {
+                t.Fatalf("error = %v after %s and %d requests", err, time.Since(start), calls.Load())
+        }
+}
+
+func TestRetryLogs(t *testing.T) {
+        srv, _ := flakyServer(t, nil, 503)
+        c := testClient(srv)
+        var logs []string
+        c.Logf = func(format string, args ...interface{}) { logs = append(logs, format) }
+        if _, err := c.GetZone(context.Background(), "z1"); err != nil {
+                t.Fatal(err)
+        }
+        if len(logs) != 1 || !strings.Contains(logs[0], "Retrying") {
+                t.Fatalf("logs = %q", logs)
+        }
+}
+
+func TestRetryDelay(t *testing.T) {
+        p := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+        for attempt, max := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 5: 10 * time.Second, 70: 10 * time.Second} {
+                wait, ok := p.delay(attempt, nil)
+                if !ok || wait < max/2 || wait > max {
+                        t.Errorf("attempt %d: wait %s, ok %v; want between %s and %s", attempt, wait, ok, max/2, max)
+                }
+        }
+
+        header := func(status int, kv ...string) *http.Response {
+                resp := &http.Response{StatusCode: status, Header: http.Header{}}
+                for i := 0; i < len(kv); i += 2 {
+                        resp.Header.Set(kv[i], kv[i+1])
+                }
+                return resp
+        }
+        tests := []struct {
+                resp   *http.Response
+                want   time.Duration
+                wantOK bool
+        }{
+                {header(503, "Retry-After", "7"), 7 * time.Second, true},
+                {header(503, "Retry-After", "11"), 11 * time.Second, false},
+                {header(429, "Retry-After", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)), 0, true},
+                {header(429, "Ratelimit", `"default";r=0;t=3`), 3 * time.Second, true},
+                {header(429, "RateLimit-Reset", "4"), 4 * time.Second, true},
+        }
+        for _, tt := range tests {
+                wait, ok := p.delay(1, tt.resp)
+                if wait != tt.want || ok != tt.wantOK {
+                        t.Errorf("headers %v: got %s, %v; want %s, %v", tt.resp.Header, wait, ok, tt.want, tt.wantOK)
+                }
+        }
+        // 限流头只在 429 时生效
+        if wait, _ := p.delay(1, header(503, "RateLimit-Reset", "9")); wait > time.Second {
+                t.Errorf("RateLimit-Reset on a 503 should be ignored, got %s", wait)
+        }
+}
@@ -0,0 +1,46 @@
+package cloudflare
+
+import (
+        "context"
+        "fmt"
+        "net/url"
+)
+
+// Zone 是 Cloudflare 上的一个区域 (域名)
+type Zone struct {
+        ID     string `json:"id"`
+        Name   string `json:"name"`
+        Status string `json:"status"`
+}
+
+// ListZones 列出 Token 可访问的区域, name 非空时只返回同名区域; 自动处理分页
+func (c *Client) ListZones(ctx context.Context, name string) ([]Zone, error) {
+        query := url.Values{}
+        if name != "" {
+                query.Set("name", name)
+        }
+        query.Set("per_page", "50")
+
+        var zones []Zone
+        for page := 1; ; page++ {
+                query.Set("page", fmt.Sprint(page))
+                var result []Zone
+                info, err := c.do(ctx, "GET", "/zones?"+query.Encode(), nil, &result)
+                if err != nil {
+                        return nil, err
+                }
+                zones = append(zones, result...)
+                if info == nil || page >= info.TotalPages {
+                        return zones, nil
+                }
+        }
+}
+
+// GetZone 获取指定 ID 的区域
+func (c *Client) GetZone(ctx context.Context, zoneID string) (*Zone, error) {
+        var zone Zone
+        if _, err := c.do(ctx, "GET", "/zones/"+url.PathEscape(zoneID), nil, &zone); err != nil {
+                return nil, err
+        }
+        return &zone, nil
+}
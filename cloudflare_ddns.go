@@ -1,46 +1,172 @@
 package main
 
 import (
-        "bytes"
+        "context"
         "encoding/json"
         "errors"
         "flag"
         "fmt"
-        "io"
         "log"
         "net"
-        "net/http"
+        "net/url"
         "os"
         "os/exec"
+        "os/signal"
         "path/filepath" // Import filepath
         "regexp"
         "strings"
+        "syscall"
         "time"
 )
 
-const (
-        cloudflareAPI = "https://api.cloudflare.com/client/v4"
-        zonesEndpoint = cloudflareAPI + "/zones"
-)
 
 type Config struct {
-        APIToken  string `json:"api_token"`
-        Zone      string `json:"zone"`      // 域名
-        Record    string `json:"record"`    // DNS 记录名
-        IPVersion string `json:"ipversion"` // "ipv4" 或 "ipv6"
-        Interface string `json:"interface"` // 网络接口名
+        APIToken  string `json:"api_token,omitempty"` // Cloudflare API Token (provider 为 cloudflare 时必需)
+        // Provider 选择 DNS 服务商: "cloudflare" (默认), "rfc2136", "powerdns", "alidns", "dnspod", "route53" 或 "dyndns2"; records 中的记录可各自覆盖
+        Provider string `json:"provider,omitempty"`
+        // RFC2136 配置 rfc2136 服务商 (向权威服务器发送 DNS UPDATE, 可选 TSIG 签名)
+        RFC2136 *RFC2136Config `json:"rfc2136,omitempty"`
+        // PowerDNS 配置 powerdns 服务商 (PowerDNS HTTP API)
+        PowerDNS *PowerDNSConfig `json:"powerdns,omitempty"`
+        // AliDNS 配置 alidns 服务商 (阿里云解析)
+        AliDNS *AliDNSConfig `json:"alidns,omitempty"`
+        // DNSPod 配置 dnspod 服务商 (DNSPod / 腾讯云)
+        DNSPod *DNSPodConfig `json:"dnspod,omitempty"`
+        // Route53 配置 route53 服务商 (AWS Route 53)
+        Route53 *Route53Config `json:"route53,omitempty"`
+        // DynDNS2 配置 dyndns2 服务商 (通用 dyndns2 协议, 如 DynDNS、No-IP)
+        DynDNS2 *DynDNS2Config `json:"dyndns2,omitempty"`
+        Zone      string `json:"zone,omitempty"`   // 域名 (records 中的记录可各自覆盖)
+        Record    string `json:"record,omitempty"` // DNS 记录名 (单条记录; 多条记录使用 records)
+        IPVersion ipVersionList `json:"ipversion"` // "ipv4", "ipv6", "both" 或 ["ipv4", "ipv6"]
+        Interface string `json:"interface,omitempty"` // 网络接口名 (未配置 ip_sources 时必需)
+        // IPMethod 指定获取接口 IP 的方式: "native" (默认, rtnetlink) 或 "command" (ip/ifconfig 命令)
+        IPMethod string `json:"ip_method,omitempty"`
+        // IPSources 是按顺序尝试的 IP 来源列表, 配置后取代 interface / ip_method
+        IPSources []IPSourceConfig `json:"ip_sources,omitempty"`
+        // AddrPolicy 控制从 interface 上的多个地址中选取哪一个 (ip_sources 中的接口来源各自配置)
+        AddrPolicy
+        // APIBaseURL 覆盖 Cloudflare API 地址 (默认 https://api.cloudflare.com/client/v4), 可用于指向模拟服务器
+        APIBaseURL string `json:"api_base_url,omitempty"`
+        // Retry 配置 Cloudflare API 请求失败后的重试 (指数退避)
+        Retry *RetryConfig `json:"retry,omitempty"`
+        // Daemon 配置 -daemon 模式下的检查间隔和随机抖动
+        Daemon *DaemonConfig `json:"daemon,omitempty"`
+        // Consensus 启用多源共识模式: 并发查询全部来源, 达到法定票数的地址才会被使用
+        Consensus *ConsensusConfig `json:"consensus,omitempty"`
         TTL       int    `json:"ttl"`       // DNS Time-To-Live
         Proxied   bool   `json:"proxied"`   // 是否启用 Cloudflare 代理
         // ZoneID 将在首次成功获取后自动填充并保存回配置文件
         ZoneID string `json:"zone_id,omitempty"` // Zone UUID (缓存)
+        // ZoneIDs 缓存 records 中其他域名的 Zone ID (域名 -> ID), 同样在首次获取后自动保存
+        ZoneIDs map[string]string `json:"zone_ids,omitempty"`
         // WorkDir 指定 .lastip 缓存文件的工作目录 (可选)
         WorkDir string `json:"work_dir,omitempty"`
+        // IPv6InterfaceID 非空时, AAAA 记录发布为 "检测到的前缀 + 该接口标识符" (用于委派前缀下的 LAN 主机)
+        IPv6InterfaceID string `json:"ipv6_interface_id,omitempty"`
+        // IPv6PrefixLength 是从检测到的地址中截取的前缀长度 (默认 64)
+        IPv6PrefixLength int `json:"ipv6_prefix_length,omitempty"`
+        // Records 列出要更新的多条记录, 每条可覆盖 name/type/ttl/proxied/zone; 与 record 二选一
+        Records []RecordConfig `json:"records,omitempty"`
+        // FailurePolicy 决定何时以非零状态退出: "any" (默认, 任一记录失败) 或 "all" (全部记录失败)
+        FailurePolicy string `json:"failure_policy,omitempty"`
+
+        apiTokenFromCredential bool           // APIToken 来自 systemd 凭据, 写回配置文件时需要去掉
+        targets                []recordTarget // readConfig 展开后的记录 (已应用服务商的最小 TTL)
+}
+
+// ipVersionList 是 ipversion 字段的值, 可写成 "ipv4", "ipv6", "both" 或 ["ipv4", "ipv6"]
+type ipVersionList []string
+
+func (v *ipVersionList) UnmarshalJSON(data []byte) error {
+        var single string
+        if err := json.Unmarshal(data, &single); err == nil {
+                if single == "both" {
+                        *v = ipVersionList{"ipv4", "ipv6"}
+                } else {
+                        *v = ipVersionList{single}
+                }
+                return nil
+        }
+        var list []string
+        if err := json.Unmarshal(data, &list); err != nil {
+                return errors.New("'ipversion' must be a string or a list of strings")
+        }
+        *v = list
+        return nil
+}
+
+func (v ipVersionList) MarshalJSON() ([]byte, error) {
+        switch len(v) {
+        case 1:
+                return json.Marshal(v[0])
+        case 2:
+                return json.Marshal("both")
+        }
+        return json.Marshal([]string(v))
+}
+
+// validate 检查列表非空、只包含 "ipv4"/"ipv6" 且没有重复
+func (v ipVersionList) validate() error {
+        if len(v) == 0 {
+                return errors.New("must be 'ipv4', 'ipv6' or 'both'")
+        }
+        seen := make(map[string]bool)
+        for _, ipversion := range v {
+                if ipversion != "ipv4" && ipversion != "ipv6" {
+                        return fmt.Errorf("'%s' must be 'ipv4', 'ipv6' or 'both'", ipversion)
+                }
+                if seen[ipversion] {
+                        return fmt.Errorf("'%s' listed more than once", ipversion)
+                }
+                seen[ipversion] = true
+        }
+        return nil
+}
+
+// has 报告列表中是否包含指定 IP 版本
+func (v ipVersionList) has(ipversion string) bool {
+        for _, x := range v {
+                if x == ipversion {
+                        return true
+                }
+        }
+        return false
 }
 
 // --- IP Address Handling ---
 
-// getInterfaceIP 获取指定接口的第一个非私有、非链接本地的公网 IP 地址
-func getInterfaceIP(iface string, ipversion string) string {
+// getInterfaceIP 按照地址选择策略获取指定接口的公网 IP 地址
+// method 为 "command" 时调用 ip/ifconfig 命令, 否则使用原生查询 (Linux 上为 rtnetlink)
+func getInterfaceIP(iface string, ipversion string, method string, selector *addrSelector) (string, error) {
+        nowStr := time.Now().Format("2006-01-02 15:04:05") // For logging
+
+        var addrs []InterfaceAddr
+        var err error
+        if method == ipMethodCommand {
+                addrs, err = listInterfaceAddrsByCommand(iface, ipversion)
+        } else {
+                log.Printf("[%s] ℹ️ Querying addresses on interface %s natively", nowStr, iface)
+                addrs, err = listInterfaceAddrs(iface)
+        }
+        if err != nil {
+                return "", err
+        }
+
+        addr, err := selector.selectAddr(addrs, ipversion)
+        if errors.Is(err, errCGNATOnly) {
+                return "", cgnatOnlyError(iface)
+        }
+        if err != nil {
+                return "", fmt.Errorf("interface %s: %w", iface, err)
+        }
+        log.Printf("[%s] ✅ Found public IP %s on interface %s (%s)", nowStr, addr.IP, iface, addr)
+        return addr.IP.String(), nil
+}
+
+// listInterfaceAddrsByCommand 通过 'ip' 或 'ifconfig' 命令的输出获取接口的地址 (回退模式)
+// 'ip' 的输出包含 scope、标志和生命周期; 'ifconfig' 只能识别同一行上的标志词, scope 由地址本身推断
+func listInterfaceAddrsByCommand(iface string, ipversion string) ([]InterfaceAddr, error) {
         var cmd *exec.Cmd
         var ipTypePattern string
         nowStr := time.Now().Format("2006-01-02 15:04:05") // For logging
@@ -61,22 +187,23 @@ func getInterfaceIP(iface string, ipversion string) string {
         } else if ifconfigErr == nil {
                 // 回退到 'ifconfig'
                 log.Printf("[%s] ⚠️ 'ip' command not found, falling back to 'ifconfig' (%s). IP filtering might be less reliable.",
-nowStr, ifconfigCmdPath, ifconfigCmdPath)
+                        nowStr, ifconfigCmdPath)
                 cmd = exec.Command(ifconfigCmdPath, iface)
                 if ipversion == "ipv6" {
+                        log.Printf("[%s] ⚠️ Warning: 'ifconfig' on Linux does not report IPv6 address flags, so ipv6_policy cannot recognize temporary or deprecated addresses. Install iproute2 or use ip_method 'native'.", nowStr)
                         ipTypePattern = `inet6\s(?:addr:\s*)?([0-9a-fA-F:]+)(?:\s|/|%)`
                 } else {
                         ipTypePattern = `inet\s(?:addr:\s*)?([0-9.]+)\s`
                 }
         } else {
-                log.Fatalf("[%s] ❌ Neither 'ip' nor 'ifconfig' command found in PATH. Cannot get interface IP.", nowStr)
+                return nil, errors.New("neither 'ip' nor 'ifconfig' command found in PATH; use the native ip_method instead")
         }
 
         output, err := cmd.CombinedOutput()
         if err != nil {
                 // If 'ip' with scope global fails, try without scope (might need manual filtering later)
-                if ipErr == nil && (strings.Contains(err.Error(), "scope global") || strings.Contains(string(output), "does not support") || (err != nil && strings.Contains(err.Error(), "exit status"))) {
-                        log.Printf("[%s] ⚠️ Failed to get global scope IP for %s (or command failed), trying without scope filter.",, nowStr, iface)
+                if ipErr == nil && (strings.Contains(err.Error(), "scope global") || strings.Contains(string(output), "does not support") || strings.Contains(err.Error(), "exit status")) {
+                        log.Printf("[%s] ⚠️ Failed to get global scope IP for %s (or command failed), trying without scope filter.", nowStr, iface)
                         if ipversion == "ipv6" {
                                 cmd = exec.Command(ipCmdPath, "-6", "addr", "show", iface)
                         } else {
@@ -84,287 +211,58 @@ nowStr, ifconfigCmdPath, ifconfigCmdPath)
                         }
                         output, err = cmd.CombinedOutput() // Retry without scope
                         if err != nil {
-                                log.Fatalf("[%s] ❌ Failed to get IP for interface %s (even without scope): %v\nOutput:\n%s", nowStr, iface, err, string(output))
+                                return nil, fmt.Errorf("failed to get IP for interface %s (even without scope): %w\nOutput:\n%s", iface, err, string(output))
                         }
                 } else {
                         // Handle error from ifconfig or non-scope-related ip error
-                        log.Fatalf("[%s] ❌ Failed to execute command for interface %s: %v\nOutput:\n%s", nowStr, iface, err, string(output))
+                        return nil, fmt.Errorf("failed to execute command for interface %s: %w\nOutput:\n%s", iface, err, string(output))
                 }
         }
 
-        re := regexp.MustCompile(ipTypePattern)
-        matches := re.FindAllStringSubmatch(string(output), -1)
-
-        for _, match := range matches {
-                if len(match) >= 2 {
-                        ipStr := match[1]
-                        // Final check for private/local IP, crucial if scope global wasn't used or ifconfig returned unwanted IPs
-                        if !isPrivateOrLocalIP(ipStr) {
-                                log.Printf("[%s] ✅ Found public IP %s on interface %s", nowStr, ipStr, iface)
-                                return ipStr
-                        } else {
-                                log.Printf("[%s] ℹ️ Skipping private/local IP %s on interface %s", nowStr, ipStr, iface)
+        if ipErr == nil {
+                var addrs []InterfaceAddr
+                for _, addr := range parseIPAddrOutput(string(output)) {
+                        if matchesIPVersion(addr.IP, ipversion) {
+                                addrs = append(addrs, addr)
                         }
                 }
+                return addrs, nil
         }
 
-        log.Fatalf("[%s] ❌ No usable public IP address found for interface %s and IP version %s in command output:\n%s", nowStr, iface, ipversion, string(output))
-        return "" // Should not be reached
-}
-
-// isPrivateOrLocalIP 判断 IP 是否为私有、回环或链接本地地址
-func isPrivateOrLocalIP(ipStr string) bool {
-        ip := net.ParseIP(ipStr)
-        if ip == nil {
-                log.Printf("[%s] ⚠️ Could not parse IP string during check: %s", time.Now().Format("2006-01-02 15:04:05"), ipStr)
-                return true // Treat unparseable as potentially problematic
-        }
-        return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || isULA(ip)
-}
-
-// isULA 检查 IPv6 是否为 Unique Local Address (fc00::/7)
-func isULA(ip net.IP) bool {
-        return ip.To4() == nil && len(ip) == net.IPv6len && (ip[0] == 0xfc || ip[0] == 0xfd)
-}
-
-// --- Cloudflare API Interaction ---
-
-// cfRequest 是执行 Cloudflare API 请求的辅助函数
-func cfRequest(method, urlStr string, apiToken string, payload io.Reader) (*http.Response, []byte, error) {
-        req, err := http.NewRequest(method, urlStr, payload)
-        if err != nil {
-                return nil, nil, fmt.Errorf("creating request failed: %w", err)
-        }
-        req.Header.Set("Authorization", "Bearer "+apiToken)
-        req.Header.Set("Content-Type", "application/json")
-        req.Header.Set("Accept", "application/json")
-
-        client := &http.Client{Timeout: 20 * time.Second} // Increased timeout slightly
-        resp, err := client.Do(req)
-        if err != nil {
-                return nil, nil, fmt.Errorf("request failed: %w", err)
-        }
-        // Defer closing the body right after checking for response error
-        defer resp.Body.Close()
-
-        body, readErr := io.ReadAll(resp.Body)
-        // Return the response even if body reading fails, but prioritize readErr
-        if readErr != nil {
-                // Including status code in the error message can be helpful
-                return resp, body, fmt.Errorf("reading response body failed (status: %s): %w", resp.Status, readErr)
-        }
-
-        return resp, body, nil
-}
-
-// getZoneID 通过 API 获取 Zone ID
-func getZoneID(apiToken, zoneName string) (string, error) {
-        url := fmt.Sprintf("%s?name=%s", zonesEndpoint, zoneName)
-        nowStr := time.Now().Format("2006-01-02 15:04:05")
-        log.Printf("[%s] ℹ️ Fetching Zone ID from Cloudflare API for zone: %s", nowStr, zoneName)
-
-        _, body, err := cfRequest("GET", url, apiToken, nil)
-        if err != nil {
-                return "", fmt.Errorf("[%s] ❌ Requesting Zone ID failed: %w", nowStr, err)
-        }
-
-        var result struct {
-                Success bool `json:"success"`
-                Result  []struct {
-                        ID string `json:"id"`
-                } `json:"result"`
-                Errors []interface{} `json:"errors"`
-        }
-
-        if err := json.Unmarshal(body, &result); err != nil {
-                return "", fmt.Errorf("[%s] ❌ Failed to parse Zone ID response: %v\nResponse: %s", nowStr, err, string(body))
-        }
-
-        if !result.Success || len(result.Result) == 0 {
-                errorMsg := "Unknown error"
-                if len(result.Errors) > 0 {
-                        errorBytes, _ := json.MarshalIndent(result.Errors, "", "  ")
-                        errorMsg = string(errorBytes)
-                }
-                return "", fmt.Errorf("[%s] ❌ Could not find Zone ID for '%s'. API Success: %t. Errors:\n%s\nFull Response:\n%s",
-                        nowStr, zoneName, result.Success, errorMsg, string(body))
-        }
-
-        log.Printf("[%s] ✅ Fetched Zone ID via API: %s", nowStr, result.Result[0].ID)
-        return result.Result[0].ID, nil
-}
-
-// DNSRecord 表示 Cloudflare DNS 记录的关键字段
-type DNSRecord struct {
-        ID      string `json:"id"`
-        Type    string `json:"type"`
-        Name    string `json:"name"`
-        Content string `json:"content"`
-        Proxied bool   `json:"proxied"`
-        TTL     int    `json:"ttl"`
-}
-
-// getDNSRecord 获取指定名称和类型的 DNS 记录信息
-// fqdn should be the fully qualified domain name (e.g., sub.example.com or example.com for root)
-func getDNSRecord(apiToken, zoneID, fqdn, recordType string) (*DNSRecord, error) {
-        nowStr := time.Now().Format("2006-01-02 15:04:05")
-        url := fmt.Sprintf("%s/%s/dns_records?type=%s&name=%s", zonesEndpoint, zoneID, recordType, fqdn)
-        _, body, err := cfRequest("GET", url, apiToken, nil)
-        if err != nil {
-                return nil, fmt.Errorf("[%s] ❌ Requesting DNS record %s (%s) failed: %w", nowStr, fqdn, recordType, err)
-        }
-
-        var result struct {
-                Success bool        `json:"success"`
-                Result  []DNSRecord `json:"result"`
-                Errors  []interface{} `json:"errors"`
-        }
-
-        if err := json.Unmarshal(body, &result); err != nil {
-                return nil, fmt.Errorf("[%s] ❌ Failed to parse DNS record response for %s (%s): %w\nResponse: %s", nowStr, fqdn, recordType, err, string(body))
-        }
-
-        if !result.Success {
-                errorMsg := "Unknown API error"
-                if len(result.Errors) > 0 {
-                        errorBytes, _ := json.MarshalIndent(result.Errors, "", "  ")
-                        errorMsg = string(errorBytes)
+        // ifconfig: BSD/macOS 会在地址所在行列出 temporary、deprecated 等标志词
+        re := regexp.MustCompile(ipTypePattern)
+        var addrs []InterfaceAddr
+        for _, line := range strings.Split(string(output), "\n") {
+                match := re.FindStringSubmatch(line)
+                if len(match) < 2 {
+                        continue
                 }
-                return nil, fmt.Errorf("[%s] ❌ API error finding DNS record %s (%s): %s\nResponse: %s", nowStr, fqdn, recordType, errorMsg, string(body))
-        }
-
-        if len(result.Result) == 0 {
-                log.Printf("[%s] ℹ️ No existing %s record found for %s via API.", nowStr, recordType, fqdn)
-                return nil, nil // Record not found, not an error
-        }
-
-        log.Printf("[%s] ℹ️ Found existing %s record for %s via API (ID: %s, IP: %s).", nowStr, recordType, fqdn, result.Result[0].IID, result.Result[0].Content)
-        if len(result.Result) > 1 {
-                log.Printf("[%s] ⚠️ Warning: Found multiple %s records for %s. Using the first one (ID: %s).",
-                        nowStr, recordType, fqdn, result.Result[0].ID)
-        }
-
-        return &result.Result[0], nil
-}
-
-// upsertDNSRecord 创建或更新 DNS 记录 (返回 bool 表示是否成功，以便缓存 IP)
-func upsertDNSRecord(config Config, currentIP string, zoneID string) bool {
-        recordType := "A"
-        if config.IPVersion == "ipv6" {
-                recordType = "AAAA"
-        }
-
-        var fqdn string
-        if config.Record == "@" || config.Record == config.Zone { // Handle both "@" and zone name itself for root
-                fqdn = config.Zone
-        } else {
-                fqdn = fmt.Sprintf("%s.%s", config.Record, config.Zone)
-        }
-
-        nowStr := time.Now().Format("2006-01-02 15:04:05")
-        log.Printf("[%s] ℹ️ Checking DNS record %s (%s) via Cloudflare API...", nowStr, fqdn, recordType)
-
-        existingRecord, err := getDNSRecord(config.APIToken, zoneID, fqdn, recordType)
-        if err != nil {
-                // getDNSRecord now includes timestamp and logs internally, just log the failure reason here
-                log.Printf("[%s] ❌ Failed to check existing DNS record state: %v", nowStr, err)
-                return false // Indicate failure
-        }
-
-        payload := map[string]interface{}{
-                "type":    recordType,
-                "name":    fqdn,
-                "content": currentIP,
-                "ttl":     config.TTL,
-                "proxied": config.Proxied,
-        }
-        jsonData, err := json.Marshal(payload)
-        if err != nil {
-                log.Printf("[%s] ❌ Failed to marshal request data for %s: %v", nowStr, fqdn, err)
-                return false // Indicate failure
-        }
-
-        action := "" // To track if we are creating or updating
-        var apiErr error
-        var resp *http.Response
-        var body []byte
-
-        if existingRecord != nil {
-                // Record exists
-                if existingRecord.Content == currentIP && existingRecord.Proxied == config.Proxied && existingRecord.TTL == config.TTL {
-                        log.Printf("[%s] ✅ DNS record %s (%s) is already up-to-date (%s). No change needed.", nowStr, fqdn, recordType, currentIP)
-                        return true // Indicate success (state matches)
+                ip := net.ParseIP(match[1])
+                if ip == nil {
+                        log.Printf("[%s] ⚠️ Could not parse IP string from command output: %s", nowStr, match[1])
+                        continue
                 }
-                // Update existing record
-                action = "update"
-                log.Printf("[%s] ℹ️ Existing record IP (%s) / settings differ from current IP (%s) / settings. Updating record ID %ss...", nowStr, existingRecord.Content, currentIP, existingRecord.ID)
-                url := fmt.Sprintf("%s/%s/dns_records/%s", zonesEndpoint, zoneID, existingRecord.ID)
-                resp, body, apiErr = cfRequest("PUT", url, config.APIToken, bytes.NewBuffer(jsonData))
-
-        } else {
-                // Record does not exist, create it
-                action = "create"
-                log.Printf("[%s] ℹ️ No existing %s record found for %s. Creating new record...", nowStr, recordType, fqdn)
-                url := fmt.Sprintf("%s/%s/dns_records", zonesEndpoint, zoneID)
-                resp, body, apiErr = cfRequest("POST", url, config.APIToken, bytes.NewBuffer(jsonData))
-        }
-
-        // Handle response统一处理创建或更新的响应
-        return handleAPIResponse(resp, body, apiErr, fqdn, recordType, currentIP, action)
-}
-
-// handleAPIResponse processes the response (返回 bool 表示 API 操作是否成功)
-func handleAPIResponse(resp *http.Response, body []byte, err error, fqdn, recordType, ip, action string) bool {
-        nowStr := time.Now().Format("2006-01-02 15:04:05")
-        if err != nil {
-                // Log error from cfRequest (e.g., network error, timeout)
-                log.Printf("[%s] ❌ Failed to %s DNS record %s (%s) - Request Error: %v", nowStr, action, fqdn, recordType, err)
-                return false // Indicate failure
-        }
-
-        // Proceed to parse API response body
-        var result struct {
-                Success bool          `json:"success"`
-                Errors  []interface{} `json:"errors"`
-                Result  DNSRecord     `json:"result"` // Capture result details
-        }
-
-        // Check if body is nil before attempting unmarshal (could happen if cfRequest had read error)
-        if body != nil {
-                if jsonErr := json.Unmarshal(body, &result); jsonErr != nil {
-                        log.Printf("[%s] ⚠️ Failed to parse API response body during %s: %v\nRaw Body: %s", nowStr, action, jsonErr,, string(body))
-                        // Continue to check status code, but likely a failure if body is unparseable JSON
+                addr := InterfaceAddr{
+                        IP:                ip,
+                        Scope:             scopeFromIP(ip),
+                        PreferredLifetime: lifetimeForever,
+                        ValidLifetime:     lifetimeForever,
                 }
-        } else {
-                log.Printf("[%s] ⚠️ API response body was nil during %s check (likely due to previous read error). Status: %s", nowSStr, action, resp.Status)
-        }
-
-        if resp.StatusCode >= 200 && resp.StatusCode < 300 && result.Success {
-                actionVerb := "created"
-                if action == "update" {
-                        actionVerb = "updated"
+                for _, word := range strings.Fields(line) {
+                        switch word {
+                        case "temporary":
+                                addr.Flags |= flagTemporary
+                        case "deprecated":
+                                addr.Flags |= flagDeprecated
+                        case "tentative":
+                                addr.Flags |= flagTentative
+                        case "duplicated":
+                                addr.Flags |= flagDADFailed
+                        }
                 }
-                // Log details from the actual result returned by the API for confirmation
-                log.Printf("[%s] ✅ Successfully %s DNS record %s (%s) => %s (ID: %s, Proxied: %t, TTL: %d)",
-                        nowStr, actionVerb, fqdn, recordType, result.Result.Content, result.Result.ID, result.Result.Proxied, result.Result.TTL)
-                return true // Indicate success
-        }
-
-        // --- Failure Case ---
-        errorMsg := fmt.Sprintf("API Status: %s", resp.Status)
-        if !result.Success && len(result.Errors) > 0 {
-                errorBytes, _ := json.MarshalIndent(result.Errors, "", "  ")
-                errorMsg = fmt.Sprintf("%s\nAPI Errors:\n%s", errorMsg, string(errorBytes))
-        } else if !result.Success {
-                errorMsg = fmt.Sprintf("%s (API success=false, no specific errors returned in expected format)", errorMsg)
-        } else {
-                // Success was true, but status code wasn't 2xx? Should be rare.
-                errorMsg = fmt.Sprintf("%s (API success=true, but status code indicates error)", errorMsg)
+                addrs = append(addrs, addr)
         }
-
-        log.Printf("[%s] ❌ Failed to %s DNS record %s (%s).\n%s\nFull Response:\n%s",
-                nowStr, action, fqdn, recordType, errorMsg, string(body))
-        return false // Indicate failure
+        return addrs, nil
 }
 
 // --- Configuration Handling ---
@@ -386,26 +284,90 @@ func readConfig(path string) (Config, error) {
                 return Config{}, fmt.Errorf("parsing config file '%s' failed: %w", path, err)
         }
 
-        // Basic validation
+        // Under systemd the token can come from LoadCredential= instead of the config file
         if config.APIToken == "" {
-                return Config{}, fmt.Errorf("config file '%s' is missing required field 'api_token'", path)
+                token, ok, err := readCredential(apiTokenCredential)
+                if err != nil {
+                        return Config{}, err
+                }
+                if ok {
+                        log.Printf("[%s] ℹ️ Using API token from systemd credential '%s'", nowStr, apiTokenCredential)
+                        config.APIToken = token
+                        config.apiTokenFromCredential = true
+                }
+        }
+
+        // Basic validation
+        if config.Interface == "" && len(config.IPSources) == 0 {
+                return Config{}, fmt.Errorf("config file '%s' is missing required field 'interface' (or 'ip_sources')", path)
+        }
+        if err := config.IPVersion.validate(); err != nil {
+                return Config{}, fmt.Errorf("config file '%s': invalid 'ipversion': %w", path, err)
+        }
+        if config.IPMethod != "" && config.IPMethod != ipMethodNative && config.IPMethod != ipMethodCommand {
+                return Config{}, fmt.Errorf("config file '%s': invalid 'ip_method' ('%s'), must be '%s' or '%s'", path, config.IPMethod, ipMethodNative, ipMethodCommand)
         }
-        if config.Zone == "" {
-                return Config{}, fmt.Errorf("config file '%s' is missing required field 'zone'", path)
+        sources, err := buildIPSources(config)
+        if err != nil {
+                return Config{}, fmt.Errorf("config file '%s': %w", path, err)
         }
-        if config.Record == "" {
-                return Config{}, fmt.Errorf("config file '%s' is missing required field 'record'", path)
+        if config.Consensus != nil && (config.Consensus.Quorum < 1 || config.Consensus.Quorum > len(sources)) {
+                return Config{}, fmt.Errorf("config file '%s': invalid 'consensus.quorum' (%d), must be between 1 and the number of IP sources (%d)",
+                        path, config.Consensus.Quorum, len(sources))
         }
-        if config.Interface == "" {
-                return Config{}, fmt.Errorf("config file '%s' is missing required field 'interface'", path)
+        if config.IPv6PrefixLength != 0 && (config.IPv6PrefixLength < 1 || config.IPv6PrefixLength > 127) {
+                return Config{}, fmt.Errorf("config file '%s': invalid 'ipv6_prefix_length' (%d), must be between 1 and 127", path, config.IPv6PrefixLength)
         }
-        if config.IPVersion != "ipv4" && config.IPVersion != "ipv6" {
-                return Config{}, fmt.Errorf("config file '%s': invalid 'ipversion' ('%s'), must be 'ipv4' or 'ipv6'", path, config.IPVersion)
+        if config.IPv6InterfaceID != "" {
+                if !config.IPVersion.has("ipv6") {
+                        return Config{}, fmt.Errorf("config file '%s': 'ipv6_interface_id' requires 'ipversion' to include 'ipv6'", path)
+                }
+                if _, err := parseInterfaceID(config.IPv6InterfaceID); err != nil {
+                        return Config{}, fmt.Errorf("config file '%s': invalid 'ipv6_interface_id': %w", path, err)
+                }
         }
         if config.TTL < 1 { // TTL 1 means 'automatic' for Cloudflare
                 log.Printf("[%s] ⚠️ TTL value (%d) in config is less than 1, defaulting to 1 (automatic)", nowStr, config.TTL)
                 config.TTL = 1
         }
+        if config.APIBaseURL != "" {
+                if u, err := url.Parse(config.APIBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+                        return Config{}, fmt.Errorf("config file '%s': invalid 'api_base_url' ('%s'), must be an http(s) URL", path, config.APIBaseURL)
+                }
+        }
+        if err := config.Retry.validate(); err != nil {
+                return Config{}, fmt.Errorf("config file '%s': invalid 'retry': %w", path, err)
+        }
+        if err := config.Daemon.validate(); err != nil {
+                return Config{}, fmt.Errorf("config file '%s': invalid 'daemon': %w", path, err)
+        }
+        if config.Provider != "" && !validProvider(config.Provider) {
+                return Config{}, fmt.Errorf("config file '%s': invalid 'provider' ('%s'), must be one of: %s", path, config.Provider, strings.Join(providerNames, ", "))
+        }
+        targets, err := expandRecords(config)
+        if err != nil {
+                return Config{}, fmt.Errorf("config file '%s': %w", path, err)
+        }
+        config.targets = targets
+        // Provider settings are only required when some record actually uses that provider
+        for _, name := range providerNames {
+                if !usesProvider(targets, name) {
+                        continue
+                }
+                if err := validateProviderConfig(config, name); err != nil {
+                        return Config{}, fmt.Errorf("config file '%s': %w", path, err)
+                }
+        }
+        if config.FailurePolicy != "" && config.FailurePolicy != failurePolicyAny && config.FailurePolicy != failurePolicyAll {
+                return Config{}, fmt.Errorf("config file '%s': invalid 'failure_policy' ('%s'), must be '%s' or '%s'", path, config.FailurePolicy, failurePolicyAny, failurePolicyAll)
+        }
+        // Normalize cached zone names so lookups match the lower-cased zones of the expanded records
+        for zone, id := range config.ZoneIDs {
+                if key := strings.ToLower(strings.TrimSuffix(zone, ".")); key != zone {
+                        delete(config.ZoneIDs, zone)
+                        config.ZoneIDs[key] = id
+                }
+        }
         // Trim whitespace from WorkDir just in case
         config.WorkDir = strings.TrimSpace(config.WorkDir)
 
@@ -416,8 +378,11 @@ func readConfig(path string) (Config, error) {
 // writeConfig 将包含 ZoneID 的配置写回文件
 func writeConfig(path string, config Config) error {
         nowStr := time.Now().Format("2006-01-02 15:04:05")
-        log.Printf("[%s] ℹ️ Saving updated configuration (with Zone ID: %s) to file: %s", nowStr, config.ZoneID, path)
+        log.Printf("[%s] ℹ️ Saving updated configuration (with cached Zone IDs) to file: %s", nowStr, path)
 
+        if config.apiTokenFromCredential {
+                config.APIToken = "" // Never persist a token loaded from systemd credentials
+        }
         jsonData, err := json.MarshalIndent(config, "", "  ") // Indent for readability
         if err != nil {
                 return fmt.Errorf("failed to marshal config for writing: %w", err)
@@ -434,10 +399,20 @@ func writeConfig(path string, config Config) error {
 
 // --- IP Caching ---
 
-// getCacheFilePath generates the path for the last IP cache file.
-// It considers the optional WorkDir from the config.
-func getCacheFilePath(config Config, configPath string) string {
-        cacheFileName := filepath.Base(configPath) + ".lastip" // e.g., "myconfig.json.lastip"
+// getCacheFilePath generates the path for the last IP cache file of one IP version.
+// It considers the optional WorkDir from the config. Dual-stack configs get one cache per family.
+func getCacheFilePath(config Config, configPath string, ipversion string) string {
+        suffix := ".lastip" // e.g., "myconfig.json.lastip"
+        if len(config.IPVersion) > 1 {
+                suffix = "." + ipversion + ".lastip" // e.g., "myconfig.json.ipv6.lastip"
+        }
+        return getStateFilePath(config, configPath, suffix)
+}
+
+// getStateFilePath returns the path of a state file named after the config file plus suffix,
+// inside WorkDir when set, otherwise next to the config file.
+func getStateFilePath(config Config, configPath string, suffix string) string {
+        cacheFileName := filepath.Base(configPath) + suffix
         nowStr := time.Now().Format("2006-01-02 15:04:05")     // For logging
 
         if config.WorkDir != "" {
@@ -454,36 +429,41 @@ func getCacheFilePath(config Config, configPath string) string {
                 return cachePath
         } else {
                 // Default: Place cache file next to the config file
-                cachePath := configPath + ".lastip"
+                cachePath := configPath + suffix
                 log.Printf("[%s] ℹ️ No work_dir specified. Default cache file path: %s",
                         nowStr, cachePath)
                 return cachePath
         }
 }
 
-// readLastIP reads the last known IP from the cache file
-func readLastIP(cachePath string) (string, error) {
+// readLastIP reads the last known IP and the fingerprints of the records it was pushed to.
+// The file holds "<ip> <fingerprint>,<fingerprint>,..." with one fingerprint per record;
+// caches written by older versions have no fingerprint or a single one covering all records.
+func readLastIP(cachePath string) (ip string, fingerprints []string, err error) {
         nowStr := time.Now().Format("2006-01-02 15:04:05")
         content, err := os.ReadFile(cachePath)
         if err != nil {
                 if errors.Is(err, os.ErrNotExist) {
                         log.Printf("[%s] ℹ️ IP cache file '%s' not found (first run or cache cleared).", nowStr, cachePath)
-                        return "", nil // Not an error, just no previous IP
+                        return "", nil, nil // Not an error, just no previous IP
                 }
                 // Return error for other read issues (permissions, etc.)
-                return "", fmt.Errorf("failed to read IP cache file '%s': %w", cachePath, err)
+                return "", nil, fmt.Errorf("failed to read IP cache file '%s': %w", cachePath, err)
         }
-        ip := strings.TrimSpace(string(content))
+        ip, list, _ := strings.Cut(strings.TrimSpace(string(content)), " ")
         if ip == "" {
                 log.Printf("[%s] ⚠️ IP cache file '%s' exists but is empty.", nowStr, cachePath)
-                return "", nil // Treat empty file same as non-existent
+                return "", nil, nil // Treat empty file same as non-existent
         }
         log.Printf("[%s] ℹ️ Read last known IP '%s' from cache '%s'", nowStr, ip, cachePath)
-        return ip, nil
+        if list = strings.TrimSpace(list); list != "" {
+                fingerprints = strings.Split(list, ",")
+        }
+        return ip, fingerprints, nil
 }
 
-// writeLastIP writes the current IP to the cache file
-func writeLastIP(cachePath string, ip string) error {
+// writeLastIP writes the current IP and the fingerprints of the records updated to it to the cache file
+func writeLastIP(cachePath string, ip string, fingerprints []string) error {
         nowStr := time.Now().Format("2006-01-02 15:04:05")
         log.Printf("[%s] ℹ️ Writing current IP '%s' to cache file '%s'", nowStr, ip, cachePath)
 
@@ -503,7 +483,7 @@ func writeLastIP(cachePath string, ip string) error {
         }
 
         // Write with restrictive permissions (0600: owner rw, group ---, others ---)
-        err := os.WriteFile(cachePath, []byte(ip+"\n"), 0600)
+        err := os.WriteFile(cachePath, []byte(ip+" "+strings.Join(fingerprints, ",")+"\n"), 0600)
         if err != nil {
                 return fmt.Errorf("failed to write IP cache file '%s': %w", cachePath, err)
         }
@@ -519,6 +499,7 @@ func main() {
 
         // --- 0. Parse Command Line Arguments ---
         configFile := flag.String("f", "", "Path to config JSON file (required)")
+        daemon := flag.Bool("daemon", false, "Keep running and re-check periodically (interval set by 'daemon' in the config file)")
         flag.Parse()
 
         if *configFile == "" {
@@ -530,7 +511,7 @@ func main() {
         // Get absolute path for config file for consistency in logging and cache path generation
         absConfigFile, err := filepath.Abs(*configFile)
         if err != nil {
-                log.Printf("[%s] ⚠️ Warning: Could not determine absolute path for config file '%s': %v. Using provided path.", nowSStr, *configFile, err)
+                log.Printf("[%s] ⚠️ Warning: Could not determine absolute path for config file '%s': %v. Using provided path.", nowStr, *configFile, err)
                 absConfigFile = *configFile // Fallback
         }
 
@@ -538,72 +519,330 @@ func main() {
         log.Printf("[%s] ========= Starting Cloudflare DDNS Update =========", nowStr)
         log.Printf("[%s] Using configuration file: %s", nowStr, absConfigFile)
 
-        // --- 1. Read Configuration ---
-        config, err := readConfig(absConfigFile)
+        // --- 1. Read Configuration And Prepare Sources ---
+        u, err := newUpdater(absConfigFile)
         if err != nil {
                 log.Fatalf("[%s] ❌ Error loading configuration: %v", time.Now().Format("2006-01-02 15:04:05"), err)
         }
 
-        // --- 2. Get Current IP ---
-        currentIP := getInterfaceIP(config.Interface, config.IPVersion) // This will Fatalf if it fails
+        // --- 2. Run Once, Or Keep Running In Daemon Mode ---
+        // SIGINT/SIGTERM cancel in-flight requests and stop the daemon loop
+        ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+        defer stop()
+        if *daemon {
+                runDaemon(ctx, u)
+                return
+        }
+        if !u.runOnce(ctx) {
+                stop()
+                os.Exit(1) // Exit with error status according to the failure policy
+        }
+}
+
+// updater 保存一次运行中各个 IP 版本、各条记录共享的状态
+type updater struct {
+        config     Config
+        configPath string
+        providers  map[string]Provider // 服务商名称 -> 后端, 只创建记录用到的服务商
+        sources    []ipSource
+        targets    []recordTarget
+        records    map[string]*Record // 服务商/区域/类型/FQDN -> 上次成功写入后的记录状态 (守护进程模式下跨检查复用)
+        // ignoreCache 为 true 时下一次检查忽略 .lastip 缓存 (SIGHUP 重新加载后, 凭据等不在摘要中的设置也可能变了)
+        ignoreCache bool
+
+        // 最近一次检查的概况, 用于 systemd 的 STATUS=
+        currentIPs  map[string]string // IP 版本 -> 检测到的地址
+        lastUpdate  time.Time         // 最近一次全部记录都成功 (已更新或确认无需更新) 的时间
+        lastCheck   time.Time
+        lastFailed  int
+        lastRecords int
+}
 
-        // --- 3. Check IP Cache ---
-        cacheFilePath := getCacheFilePath(config, absConfigFile) // Use abs path
-        lastIP, err := readLastIP(cacheFilePath)
+// newUpdater 读取配置文件并准备 IP 来源和记录列表
+func newUpdater(configPath string) (*updater, error) {
+        // --- Read Configuration ---
+        config, err := readConfig(configPath)
+        if err != nil {
+                return nil, err
+        }
+
+        // --- Prepare IP Sources ---
+        sources, err := buildIPSources(config)
+        if err != nil {
+                return nil, fmt.Errorf("setting up IP sources: %w", err)
+        }
+
+        // --- Expand Records (done by readConfig) ---
+        targets := config.targets
+
+        u := &updater{
+                config:     config,
+                configPath: configPath,
+                providers:  make(map[string]Provider),
+                sources:    sources,
+                targets:    targets,
+                records:    make(map[string]*Record),
+                currentIPs: make(map[string]string),
+        }
+
+        // --- Create Provider Backends ---
+        for _, t := range targets {
+                if _, ok := u.providers[t.Provider]; ok {
+                        continue
+                }
+                p, err := newProvider(t.Provider, u)
+                if err != nil {
+                        return nil, err
+                }
+                u.providers[t.Provider] = p
+        }
+        return u, nil
+}
+
+// runOnce 执行一次完整的检查与更新, 逐条报告记录结果, 按失败策略返回本次是否成功
+func (u *updater) runOnce(ctx context.Context) bool {
+        // Update records one IP family at a time.
+        // The IP is detected once per family and applied to every record of that family.
+        // A failure in one family (e.g. no IPv6 connectivity) must not block the other.
+        results := u.run(ctx)
+
+        // --- Report Per-Record Results ---
+        failedCount := 0
+        for _, r := range results {
+                if r.ok {
+                        log.Printf("[%s] ✅ %s: OK", time.Now().Format("2006-01-02 15:04:05"), r.target)
+                } else {
+                        failedCount++
+                        log.Printf("[%s] ❌ %s: FAILED", time.Now().Format("2006-01-02 15:04:05"), r.target)
+                }
+        }
+        u.lastCheck, u.lastFailed, u.lastRecords = time.Now(), failedCount, len(results)
+        if failedCount == 0 {
+                u.lastUpdate = u.lastCheck
+        }
+        failed := failedCount > 0
+        if u.config.FailurePolicy == failurePolicyAll {
+                failed = failedCount == len(results)
+        }
+
+        if failed {
+                log.Printf("[%s] ❌ DDNS update failed for %d of %d records. Check previous error messages.", time.Now().Format("2006-01-02 15:04:05"), failedCount, len(results))
+                log.Printf("[%s] ========= Cloudflare DDNS Update Failed =========", time.Now().Format("2006-01-02 15:04:05"))
+                return false
+        }
+        if failedCount > 0 {
+                log.Printf("[%s] ⚠️ %d of %d records failed, tolerated by failure_policy '%s'.", time.Now().Format("2006-01-02 15:04:05"), failedCount, len(results), u.config.FailurePolicy)
+        }
+        log.Printf("[%s] ========= Cloudflare DDNS Update Completed Successfully =========", time.Now().Format("2006-01-02 15:04:05"))
+        return true
+}
+
+// recordResult 是单条记录的更新结果
+type recordResult struct {
+        target recordTarget
+        ok     bool
+}
+
+// run 依次处理每个 IP 版本, 返回每条记录的结果
+func (u *updater) run(ctx context.Context) []recordResult {
+        var results []recordResult
+        for _, ipversion := range u.config.IPVersion {
+                results = append(results, u.updateFamily(ctx, ipversion)...)
+        }
+        u.ignoreCache = false
+        return results
+}
+
+// status 返回一行概况, 如 "ipv4 203.0.113.5, ipv6 unknown; last successful update 2006-01-02 15:04:05; last check: 3/3 records OK"
+func (u *updater) status() string {
+        var ips []string
+        for _, ipversion := range u.config.IPVersion {
+                ip := u.currentIPs[ipversion]
+                if ip == "" {
+                        ip = "unknown"
+                }
+                ips = append(ips, ipversion+" "+ip)
+        }
+        lastUpdate := "never"
+        if !u.lastUpdate.IsZero() {
+                lastUpdate = u.lastUpdate.Format("2006-01-02 15:04:05")
+        }
+        if u.lastCheck.IsZero() {
+                return fmt.Sprintf("%s; last successful update %s; first check in progress", strings.Join(ips, ", "), lastUpdate)
+        }
+        return fmt.Sprintf("%s; last successful update %s; last check: %d/%d records OK",
+                strings.Join(ips, ", "), lastUpdate, u.lastRecords-u.lastFailed, u.lastRecords)
+}
+
+// updateFamily 检测单个 IP 版本的当前地址并更新该地址族的全部记录
+// 每个 IP 版本有独立的 .lastip 缓存, 记录 IP 以及已经更新为该 IP 的每条记录设置的摘要
+// IP 未变时只处理摘要不在缓存中的记录 (新增、修改或上次失败的), 成功的记录不会被反复提交
+func (u *updater) updateFamily(ctx context.Context, ipversion string) []recordResult {
+        nowStr := time.Now().Format("2006-01-02 15:04:05")
+        var targets []recordTarget
+        for _, t := range u.targets {
+                if t.family() == ipversion {
+                        targets = append(targets, t)
+                }
+        }
+        if len(targets) == 0 {
+                return nil
+        }
+        log.Printf("[%s] ---------- Updating %s (%d records) ----------", nowStr, ipversion, len(targets))
+
+        results := make([]recordResult, len(targets))
+        fingerprints := make([]string, len(targets))
+        for i, t := range targets {
+                results[i].target = t
+                fingerprints[i] = targetsFingerprint([]recordTarget{t}, u.config.IPv6PrefixLength)
+        }
+
+        // --- Get Current IP ---
+        currentIP, err := u.detectCurrentIP(ctx, ipversion)
+        if err != nil {
+                log.Printf("[%s] ❌ Error getting current %s address: %v", time.Now().Format("2006-01-02 15:04:05"), ipversion, err)
+                delete(u.currentIPs, ipversion)
+                return results
+        }
+        u.currentIPs[ipversion] = currentIP
+
+        // --- Check IP Cache ---
+        cacheFilePath := getCacheFilePath(u.config, u.configPath, ipversion)
+        lastIP, lastFingerprints, err := readLastIP(cacheFilePath)
         if err != nil {
                 // Log non-critical read error but continue (will force API check)
-                log.Printf("[%s] ⚠️ Warning: Could not read last IP cache '%s': %v", time.Now().Format("2006-01-02 15:04:05"), cacheeFilePath, err)
+                log.Printf("[%s] ⚠️ Warning: Could not read last IP cache '%s': %v", time.Now().Format("2006-01-02 15:04:05"), cacheFilePath, err)
+        }
+        // Records already updated to the current IP with their current settings
+        done := make(map[string]bool)
+        if currentIP == lastIP && lastIP != "" && !u.ignoreCache {
+                for _, fp := range lastFingerprints {
+                        done[fp] = true
+                }
+        }
+        pending := 0
+        for _, fp := range fingerprints {
+                if !done[fp] {
+                        pending++
+                }
         }
 
-        if currentIP == lastIP && lastIP != "" { // Ensure lastIP is not empty
+        if u.ignoreCache && lastIP != "" {
+                log.Printf("[%s] ℹ️ Configuration was reloaded, ignoring cached IP (%s). Proceeding with DNS record check.", time.Now().Format("2006-01-02 15:04:05"), lastIP)
+        } else if currentIP == lastIP && lastIP != "" && pending == 0 {
                 log.Printf("[%s] ✅ Current IP (%s) matches cached IP from '%s'. No update needed.", time.Now().Format("2006-01-02 15:04:05"), currentIP, cacheFilePath)
-                log.Printf("[%s] ========= Cloudflare DDNS Update Completed (No Action) =========", time.Now().Format("2006-01-02 15:04:05"))
-                os.Exit(0) // Exit successfully
+                for i := range results {
+                        results[i].ok = true
+                }
+                return results
+        } else if currentIP == lastIP && lastIP != "" {
+                log.Printf("[%s] ℹ️ Current IP (%s) matches the cache, but %d of %d records are new, changed or failed last time. Checking those records.", time.Now().Format("2006-01-02 15:04:05"), currentIP, pending, len(targets))
         } else if lastIP != "" {
-                log.Printf("[%s] ℹ️ Current IP (%s) differs from cached IP (%s). Proceeding with Cloudflare check.", time.Now().Formmat("2006-01-02 15:04:05"), currentIP, lastIP)
+                log.Printf("[%s] ℹ️ Current IP (%s) differs from cached IP (%s). Proceeding with DNS record check.", time.Now().Format("2006-01-02 15:04:05"), currentIP, lastIP)
         } else {
-         log.Printf("[%s] ℹ️ No valid cached IP found. Proceeding with Cloudflare check.", time.Now().Format("2006-01-02 15:04:05"))
-    }
+                log.Printf("[%s] ℹ️ No valid cached IP found. Proceeding with DNS record check.", time.Now().Format("2006-01-02 15:04:05"))
+        }
 
+        // --- Upsert Every Pending Record Of This Family ---
+        updated := false
+        var succeeded []string
+        for i := range results {
+                if done[fingerprints[i]] {
+                        results[i].ok = true
+                } else {
+                        results[i].ok = u.updateRecord(ctx, results[i].target, currentIP)
+                        updated = updated || results[i].ok
+                }
+                if results[i].ok {
+                        succeeded = append(succeeded, fingerprints[i])
+                }
+        }
 
-        // --- 4. Handle Zone ID (Cache or Fetch) ---
-        zoneID := config.ZoneID
-        if zoneID == "" {
-                fetchedZoneID, err := getZoneID(config.APIToken, config.Zone)
-                if err != nil {
-                        // Fatal if we can't get the Zone ID when needed
-                        log.Fatalf("[%s] ❌ Error fetching Zone ID: %v", time.Now().Format("2006-01-02 15:04:05"), err)
+        // --- Update IP Cache With The Records That Succeeded ---
+        // Failed records are left out so that only they are retried on the next run
+        if updated {
+                if writeErr := writeLastIP(cacheFilePath, currentIP, succeeded); writeErr != nil {
+                        // Log cache write failure but don't fail the whole process
+                        log.Printf("[%s] ⚠️ Warning: DNS update succeeded, but failed to write current IP to cache file '%s': %v", time.Now().Format("2006-01-02 15:04:05"), cacheFilePath, writeErr)
                 }
-                config.ZoneID = fetchedZoneID // Update in memory
-                zoneID = fetchedZoneID
+        }
+        return results
+}
 
-                // Attempt to save the updated config with the Zone ID
-                // Use the absolute config file path for writing
-                if writeErr := writeConfig(absConfigFile, config); writeErr != nil {
-                        // Log failure to write but continue the current run with the fetched ID
-                        log.Printf("[%s] ⚠️ Warning: Failed to save Zone ID to config file '%s': %v", time.Now().Format("2006-01-02
-15:04:05"), absConfigFile, writeErr)
-                        log.Printf("[%s] ℹ️ Will continue this run using the fetched Zone ID, but it won't be cached for next time uunless manually added or file permissions fixed.", time.Now().Format("2006-01-02 15:04:05"))
+// updateRecord 将单条记录更新为当前地址 (返回 bool 表示是否成功)
+func (u *updater) updateRecord(ctx context.Context, target recordTarget, currentIP string) bool {
+        p := u.providers[target.Provider]
+        zone := target.Zone
+        if zone == "" {
+                detector, ok := p.(zoneDetector)
+                if !ok {
+                        log.Printf("[%s] ❌ %s cannot detect the zone of %s; set 'zone' for this record", time.Now().Format("2006-01-02 15:04:05"), p.Name(), target.FQDN)
+                        return false
+                }
+                var err error
+                if zone, err = detector.DetectZone(ctx, target.FQDN); err != nil {
+                        log.Printf("[%s] ❌ Error detecting zone for %s: %v", time.Now().Format("2006-01-02 15:04:05"), target.FQDN, err)
+                        return false
                 }
+        }
+
+        // In prefix mode the record points at a downstream host: detected prefix + configured interface ID
+        recordIP := currentIP
+        if target.InterfaceID != "" {
+                prefixLen := u.config.IPv6PrefixLength
+                if prefixLen == 0 {
+                        prefixLen = defaultIPv6PrefixLength
+                }
+                var err error
+                recordIP, err = combineIPv6Prefix(currentIP, prefixLen, target.InterfaceID)
+                if err != nil {
+                        log.Printf("[%s] ❌ Error building address from prefix and interface ID: %v", time.Now().Format("2006-01-02 15:04:05"), err)
+                        return false
+                }
+                log.Printf("[%s] ℹ️ Using prefix of %s/%d with interface ID %s for %s: %s", time.Now().Format("2006-01-02 15:04:05"), currentIP, prefixLen, target.InterfaceID, target.FQDN, recordIP)
+        }
+        // upsertRecord returns true on success (including "no change needed"), false on failure
+        key := target.Provider + "/" + zone + "/" + target.Type + "/" + target.FQDN
+        cached := u.records[key]
+        record, ok := upsertRecord(ctx, p, zone, target, recordIP, cached)
+        if !ok && cached != nil && ctx.Err() == nil {
+                // The record may have been changed or deleted outside this tool; look it up again
+                log.Printf("[%s] ℹ️ Retrying %s with a fresh lookup instead of the cached record state.", time.Now().Format("2006-01-02 15:04:05"), target)
+                record, ok = upsertRecord(ctx, p, zone, target, recordIP, nil)
+        }
+        if ok && record != nil {
+                u.records[key] = record
         } else {
-                log.Printf("[%s] ✅ Using cached Zone ID from config file: %s", time.Now().Format("2006-01-02 15:04:05"), zoneID)
+                delete(u.records, key)
         }
+        return ok
+}
 
-        // --- 5. Upsert DNS Record ---
-        // upsertDNSRecord now returns true on success (including "no change needed"), false on failure
-        success := upsertDNSRecord(config, currentIP, zoneID)
+// detectCurrentIP 使用配置的来源检测指定 IP 版本的当前地址 (共识模式或按顺序回退)
+func (u *updater) detectCurrentIP(ctx context.Context, ipversion string) (string, error) {
+        if u.config.Consensus != nil {
+                return detectIPConsensus(ctx, u.sources, ipversion, u.config.Consensus.Quorum)
+        }
+        return detectIP(ctx, u.sources, ipversion)
+}
 
-        // --- 6. Update IP Cache on Success ---
-        if success {
-                // Use the cacheFilePath determined earlier
-                if writeErr := writeLastIP(cacheFilePath, currentIP); writeErr != nil {
-                        // Log cache write failure but don't fail the whole process
-                        log.Printf("[%s] ⚠️ Warning: Cloudflare update succeeded, but failed to write current IP to cache file '%s':: %v", time.Now().Format("2006-01-02 15:04:05"), cacheFilePath, writeErr)
-                }
-                log.Printf("[%s] ========= Cloudflare DDNS Update Completed Successfully =========", time.Now().Format("2006-01-02 15:04:05"))
+// saveZoneID 将通过 API 获取到的 Zone ID 写回配置文件 (顶层 zone 写入 zone_id, 其他域名写入 zone_ids)
+func (u *updater) saveZoneID(zone, id string) {
+        // Update in memory
+        if strings.EqualFold(zone, strings.TrimSuffix(u.config.Zone, ".")) {
+                u.config.ZoneID = id
         } else {
-                log.Printf("[%s] ❌ DDNS update process failed. Check previous error messages.", time.Now().Format("2006-01-02 15:04:05"))
-                log.Printf("[%s] ========= Cloudflare DDNS Update Failed =========", time.Now().Format("2006-01-02 15:04:05"))
-                os.Exit(1) // Exit with error status if Cloudflare update failed
+                if u.config.ZoneIDs == nil {
+                        u.config.ZoneIDs = make(map[string]string)
+                }
+                u.config.ZoneIDs[zone] = id
+        }
+
+        // Attempt to save the updated config with the Zone ID
+        // Use the absolute config file path for writing
+        if writeErr := writeConfig(u.configPath, u.config); writeErr != nil {
+                // Log failure to write but continue the current run with the fetched ID
+                log.Printf("[%s] ⚠️ Warning: Failed to save Zone ID to config file '%s': %v", time.Now().Format("2006-01-02 15:04:05"), u.configPath, writeErr)
+                log.Printf("[%s] ℹ️ Will continue this run using the fetched Zone ID, but it won't be cached for next time unless manually added or file permissions fixed.", time.Now().Format("2006-01-02 15:04:05"))
         }
 }
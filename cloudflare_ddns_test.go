@@ -0,0 +1,126 @@
+package main
+
+import (
+        "context"
+        "os"
+        "path/filepath"
+        "sync"
+        "testing"
+)
+
+// memProvider 是保存在内存中的服务商, 记录查询和写入次数
+type memProvider struct {
+        mu      sync.Mutex
+        records map[string]Record // 类型/FQDN -> 记录
+        lookups int
+        writes  int
+}
+
+func newMemProvider() *memProvider {
+        return &memProvider{records: make(map[string]Record)}
+}
+
+func (p *memProvider) Name() string { return "memory" }
+
+func (p *memProvider) Lookup(ctx context.Context, zone, fqdn, recordType string) (*Record, error) {
+        p.mu.Lock()
+        defer p.mu.Unlock()
+        p.lookups++
+        if r, ok := p.records[recordType+"/"+fqdn]; ok {
+                return &r, nil
+        }
+        return nil, nil
+}
+
+func (p *memProvider) Create(ctx context.Context, zone string, record Record) (*Record, error) {
+        return p.Update(ctx, zone, nil, record)
+}
+
+func (p *memProvider) Update(ctx context.Context, zone string, existing *Record, record Record) (*Record, error) {
+        p.mu.Lock()
+        defer p.mu.Unlock()
+        p.writes++
+        p.records[record.Type+"/"+record.Name] = record
+        return &record, nil
+}
+
+// newTestUpdater 创建一个使用固定地址和内存服务商的 updater, 相当于 cron 每次启动一个新进程
+func newTestUpdater(t *testing.T, configPath string, p Provider, ip string, targets ...recordTarget) *updater {
+        t.Helper()
+        src, err := newIPSource(IPSourceConfig{Type: "static", Value: ip})
+        if err != nil {
+                t.Fatal(err)
+        }
+        for i := range targets {
+                targets[i].Provider = "memory"
+        }
+        return &updater{
+                config:     Config{IPVersion: ipVersionList{"ipv4"}},
+                configPath: configPath,
+                providers:  map[string]Provider{"memory": p},
+                sources:    []ipSource{src},
+                targets:    targets,
+                records:    make(map[string]*Record),
+                currentIPs: make(map[string]string),
+        }
+}
+
+func TestLastIPCacheFollowsRecordConfig(t *testing.T) {
+        configPath := filepath.Join(t.TempDir(), "config.json")
+        p := newMemProvider()
+        www := recordTarget{FQDN: "www.example.com", Zone: "example.com", Type: "A", TTL: 300}
+        api := recordTarget{FQDN: "api.example.com", Zone: "example.com", Type: "A", TTL: 300}
+
+        steps := []struct {
+                name        string
+                ip          string
+                targets     []recordTarget
+                reload      bool
+                wantLookups int // 本步骤中新增的查询次数
+                wantWrites  int
+        }{
+                {"first run", "9.9.9.9", []recordTarget{www}, false, 1, 1},
+                {"unchanged", "9.9.9.9", []recordTarget{www}, false, 0, 0},
+                {"record added", "9.9.9.9", []recordTarget{www, api}, false, 1, 1},
+                {"unchanged again", "9.9.9.9", []recordTarget{api, www}, false, 0, 0},
+                {"ttl changed", "9.9.9.9", []recordTarget{www, {FQDN: "api.example.com", Zone: "example.com", Type: "A", TTL: 600}}, false, 1, 1},
+                {"reloaded", "9.9.9.9", []recordTarget{www, {FQDN: "api.example.com", Zone: "example.com", Type: "A", TTL: 600}}, true, 2, 0},
+                {"ip changed", "1.1.1.1", []recordTarget{www, {FQDN: "api.example.com", Zone: "example.com", Type: "A", TTL: 600}}, false, 2, 2},
+        }
+        for _, step := range steps {
+                lookups, writes := p.lookups, p.writes
+                u := newTestUpdater(t, configPath, p, step.ip, step.targets...)
+                u.ignoreCache = step.reload
+                if !u.runOnce(context.Background()) {
+                        t.Fatalf("%s: run failed", step.name)
+                }
+                if p.lookups-lookups != step.wantLookups || p.writes-writes != step.wantWrites {
+                        t.Fatalf("%s: %d lookups, %d writes; want %d, %d", step.name, p.lookups-lookups, p.writes-writes, step.wantLookups, step.wantWrites)
+                }
+                if u.ignoreCache {
+                        t.Fatalf("%s: ignoreCache should be cleared after a check", step.name)
+                }
+        }
+}
+
+func TestLastIPCacheOldFormat(t *testing.T) {
+        configPath := filepath.Join(t.TempDir(), "config.json")
+        // 旧版本只写入 IP, 没有记录摘要: 应当重新检查一次并升级缓存格式
+        if err := os.WriteFile(configPath+".lastip", []byte("9.9.9.9\n"), 0600); err != nil {
+                t.Fatal(err)
+        }
+        p := newMemProvider()
+        target := recordTarget{FQDN: "example.com", Zone: "example.com", Type: "A", TTL: 1}
+        newTestUpdater(t, configPath, p, "9.9.9.9", target).runOnce(context.Background())
+        if p.lookups != 1 {
+                t.Fatalf("old cache format: %d lookups, want 1", p.lookups)
+        }
+        newTestUpdater(t, configPath, p, "9.9.9.9", target).runOnce(context.Background())
+        if p.lookups != 1 {
+                t.Fatalf("upgraded cache: %d lookups, want 1", p.lookups)
+        }
+        ip, fingerprints, err := readLastIP(configPath + ".lastip")
+        if err != nil || ip != "9.9.9.9" || len(fingerprints) != 1 || fingerprints[0] != targetsFingerprint([]recordTarget{{FQDN: "example.com", Zone: "example.com", Type: "A", TTL: 1, Provider: "memory"}}, 0) {
+                t.Fatalf("cache = %q %q, %v", ip, fingerprints, err)
+        }
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+        "context"
+        "fmt"
+        "log"
+        "sort"
+        "strings"
+        "sync"
+        "time"
+)
+
+// ConsensusConfig 配置多源共识模式
+type ConsensusConfig struct {
+        // Quorum 是接受一个地址所需的最少一致来源数
+        Quorum int `json:"quorum"`
+}
+
+// sourceResult 是共识模式下单个来源的查询结果
+type sourceResult struct {
+        source ipSource
+        ip     string
+        err    error
+}
+
+// detectIPConsensus 并发查询所有来源, 只有当至少 quorum 个来源返回同一地址时才接受该地址
+// 返回不同地址或查询失败的来源会被记录为异议者
+func detectIPConsensus(ctx context.Context, sources []ipSource, ipversion string, quorum int) (string, error) {
+        nowStr := time.Now().Format("2006-01-02 15:04:05")
+        log.Printf("[%s] ℹ️ Detecting %s address via %d sources in consensus mode (quorum %d)", nowStr, ipversion, len(sources), quorum)
+
+        results := make([]sourceResult, len(sources))
+        var wg sync.WaitGroup
+        for i, src := range sources {
+                wg.Add(1)
+                go func(i int, src ipSource) {
+                        defer wg.Done()
+                        ip, err := detectFromSource(ctx, src, ipversion)
+                        results[i] = sourceResult{source: src, ip: ip, err: err}
+                }(i, src)
+        }
+        wg.Wait()
+
+        votes := make(map[string][]string) // ip -> source names
+        for _, r := range results {
+                if r.err != nil {
+                        log.Printf("[%s] ⚠️ IP source %s failed: %v", nowStr, r.source.Name(), r.err)
+                        continue
+                }
+                log.Printf("[%s] ℹ️ IP source %s voted for %s", nowStr, r.source.Name(), r.ip)
+                votes[r.ip] = append(votes[r.ip], r.source.Name())
+        }
+
+        winner, tie := tallyVotes(votes)
+        if winner == "" {
+                return "", fmt.Errorf("no IP source returned a usable %s address", ipversion)
+        }
+        if tie {
+                return "", fmt.Errorf("no consensus: several addresses received %d votes (%s)", len(votes[winner]), formatVotes(votes))
+        }
+        if len(votes[winner]) < quorum {
+                return "", fmt.Errorf("no consensus: best candidate %s has %d of %d required votes (%s)", winner, len(votes[winner]), quorum, formatVotes(votes))
+        }
+
+        for _, r := range results {
+                if r.err == nil && r.ip != winner {
+                        log.Printf("[%s] ⚠️ Dissenting IP source %s returned %s, consensus is %s", nowStr, r.source.Name(), r.ip, winner)
+                }
+        }
+        log.Printf("[%s] ✅ Consensus reached on %s with %d of %d sources agreeing", nowStr, winner, len(votes[winner]), len(sources))
+        return winner, nil
+}
+
+// tallyVotes 返回得票最多的地址, 以及是否有其他地址与其票数相同
+func tallyVotes(votes map[string][]string) (string, bool) {
+        winner, tie := "", false
+        for ip, names := range votes {
+                switch {
+                case winner == "" || len(names) > len(votes[winner]):
+                        winner, tie = ip, false
+                case len(names) == len(votes[winner]):
+                        tie = true
+                }
+        }
+        return winner, tie
+}
+
+// formatVotes 将投票结果格式化为稳定排序的日志字符串
+func formatVotes(votes map[string][]string) string {
+        ips := make([]string, 0, len(votes))
+        for ip := range votes {
+                ips = append(ips, ip)
+        }
+        sort.Strings(ips)
+        parts := make([]string, 0, len(ips))
+        for _, ip := range ips {
+                parts = append(parts, fmt.Sprintf("%s: %s", ip, strings.Join(votes[ip], ", ")))
+        }
+        return strings.Join(parts, "; ")
+}
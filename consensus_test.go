@@ -0,0 +1,103 @@
+package main
+
+import (
+        "context"
+        "net/http"
+        "net/http/httptest"
+        "strings"
+        "testing"
+)
+
+// echoSource 启动一个返回固定正文的 HTTP 回显服务, 返回指向它的 http 来源
+func echoSource(t *testing.T, status int, body string) ipSource {
+        t.Helper()
+        srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                w.WriteHeader(status)
+                w.Write([]byte(body))
+        }))
+        t.Cleanup(srv.Close)
+        src, err := newIPSource(IPSourceConfig{Type: "http", URL: srv.URL})
+        if err != nil {
+                t.Fatal(err)
+        }
+        return src
+}
+
+func TestDetectIPConsensus(t *testing.T) {
+        tests := []struct {
+                name    string
+                replies []string // "" 表示该来源返回 500
+                quorum  int
+                want    string
+                wantErr string
+        }{
+                {"unanimous", []string{"1.1.1.1", "1.1.1.1", "1.1.1.1"}, 3, "1.1.1.1", ""},
+                {"dissenter outvoted", []string{"1.1.1.1", "9.9.9.9", "1.1.1.1"}, 2, "1.1.1.1", ""},
+                {"failed source does not vote", []string{"1.1.1.1", "", "1.1.1.1"}, 2, "1.1.1.1", ""},
+                {"quorum not reached", []string{"1.1.1.1", "9.9.9.9", "1.1.1.1"}, 3, "", "has 2 of 3 required votes"},
+                {"tie", []string{"1.1.1.1", "9.9.9.9"}, 1, "", "several addresses received 1 votes"},
+                {"private answer rejected", []string{"1.1.1.1", "10.0.0.1", "10.0.0.1"}, 1, "1.1.1.1", ""},
+                {"all failed", []string{"", ""}, 1, "", "no IP source returned a usable ipv4 address"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        var sources []ipSource
+                        for _, reply := range tt.replies {
+                                if reply == "" {
+                                        sources = append(sources, echoSource(t, http.StatusInternalServerError, "oops"))
+                                } else {
+                                        sources = append(sources, echoSource(t, http.StatusOK, reply+"\n"))
+                                }
+                        }
+                        got, err := detectIPConsensus(context.Background(), sources, "ipv4", tt.quorum)
+                        if tt.wantErr != "" {
+                                if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                                        t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+                                }
+                                return
+                        }
+                        if err != nil || got != tt.want {
+                                t.Fatalf("got %q, %v; want %q", got, err, tt.want)
+                        }
+                })
+        }
+}
+
+func TestDetectIPFallsBackInOrder(t *testing.T) {
+        sources := []ipSource{
+                echoSource(t, http.StatusBadGateway, ""),
+                echoSource(t, http.StatusOK, "203.0.113.9"), // 文档地址, 不可用
+                echoSource(t, http.StatusOK, "9.9.9.9"),
+                echoSource(t, http.StatusOK, "1.1.1.1"),
+        }
+        got, err := detectIP(context.Background(), sources, "ipv4")
+        if err != nil || got != "9.9.9.9" {
+                t.Fatalf("got %q, %v; want 9.9.9.9", got, err)
+        }
+}
+
+func TestHTTPSourceParse(t *testing.T) {
+        tests := []struct {
+                cfg  IPSourceConfig
+                body string
+        }{
+                {IPSourceConfig{Parse: "trace"}, "fl=1\nh=1.1.1.1\nip=8.8.4.4\nts=1\n"},
+                {IPSourceConfig{Parse: "json", Field: "data.ip"}, `{"data":{"ip":"8.8.4.4"}}`},
+                {IPSourceConfig{Parse: "regex", Pattern: `Address: ([0-9.]+)`}, "<html>Current IP Address: 8.8.4.4</html>"},
+        }
+        for _, tt := range tests {
+                srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                        w.Write([]byte(tt.body))
+                }))
+                tt.cfg.Type, tt.cfg.URL = "http", srv.URL
+                src, err := newIPSource(tt.cfg)
+                if err != nil {
+                        t.Fatal(err)
+                }
+                got, err := detectFromSource(context.Background(), src, "ipv4")
+                srv.Close()
+                if err != nil || got != "8.8.4.4" {
+                        t.Errorf("parse %s: got %q, %v", tt.cfg.Parse, got, err)
+                }
+        }
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+        "context"
+        "fmt"
+        "log"
+        "math/rand"
+        "os"
+        "os/signal"
+        "syscall"
+        "time"
+)
+
+// 守护进程模式 (-daemon): 常驻运行, 按间隔 (带随机抖动) 重新检查 IP,
+// 期间复用 HTTP 连接以及 Zone ID / 记录状态缓存, 避免 cron 每次启动进程重新读取和查询
+
+const (
+        defaultDaemonInterval = 300 // 秒
+        defaultDaemonDebounce = 2   // 秒
+)
+
+// DaemonConfig 配置守护进程模式的检查间隔
+type DaemonConfig struct {
+        Interval int `json:"interval,omitempty"` // 检查间隔 (秒), 默认 300
+        // Jitter 是每次间隔随机增加的最大秒数, 避免多台主机同时请求; 省略时为间隔的 10%
+        Jitter *int `json:"jitter,omitempty"`
+        // Debounce 是接口地址变化后等待的秒数 (Linux), 等待期间的后续变化会重新计时, 默认 2
+        Debounce int `json:"debounce,omitempty"`
+}
+
+// validate 检查间隔设置 (nil 表示全部使用默认值)
+func (d *DaemonConfig) validate() error {
+        if d == nil {
+                return nil
+        }
+        if d.Interval < 0 {
+                return fmt.Errorf("'interval' (%d) must not be negative", d.Interval)
+        }
+        if d.Debounce < 0 {
+                return fmt.Errorf("'debounce' (%d) must not be negative", d.Debounce)
+        }
+        if d.Jitter != nil && (*d.Jitter < 0 || *d.Jitter >= d.interval()) {
+                return fmt.Errorf("'jitter' (%d) must be between 0 and 'interval' (%d)", *d.Jitter, d.interval())
+        }
+        return nil
+}
+
+// interval 返回检查间隔的秒数
+func (d *DaemonConfig) interval() int {
+        if d == nil || d.Interval == 0 {
+                return defaultDaemonInterval
+        }
+        return d.Interval
+}
+
+// jitter 返回随机抖动的最大秒数
+func (d *DaemonConfig) jitter() int {
+        if d == nil || d.Jitter == nil {
+                return d.interval() / 10
+        }
+        return *d.Jitter
+}
+
+// debounce 返回地址变化后到开始检查的等待时间
+func (d *DaemonConfig) debounce() time.Duration {
+        if d == nil || d.Debounce == 0 {
+                return defaultDaemonDebounce * time.Second
+        }
+        return time.Duration(d.Debounce) * time.Second
+}
+
+// nextDelay 返回到下一次检查的等待时间: 间隔加上 [0, jitter] 秒的随机抖动, 检查不会比配置的间隔更频繁
+func (d *DaemonConfig) nextDelay() time.Duration {
+        delay := time.Duration(d.interval()) * time.Second
+        if j := d.jitter(); j > 0 {
+                delay += time.Duration(rand.Int63n(int64(j)*int64(time.Second) + 1))
+        }
+        return delay
+}
+
+// runDaemon 循环执行检查直到 ctx 被取消 (SIGTERM/SIGINT); 正在进行的 API 请求会被立即取消
+// 收到 SIGHUP 时重新加载配置文件并立即检查一次, 加载失败则继续使用旧配置
+// 在 Linux 上还会监听所用接口的地址变化, 变化后 (去抖动) 立即检查, 定时检查作为兜底
+// 由 systemd 启动时在第一次检查前报告就绪, 每次检查后更新状态, 并在检查循环正常时发送 watchdog ping
+func runDaemon(ctx context.Context, u *updater) {
+        reload := make(chan os.Signal, 1)
+        signal.Notify(reload, syscall.SIGHUP)
+        defer signal.Stop(reload)
+
+        log.Printf("[%s] ℹ️ Daemon mode: checking every %ds (+0-%ds jitter). Send SIGHUP to reload the configuration.",
+                time.Now().Format("2006-01-02 15:04:05"), u.config.Daemon.interval(), u.config.Daemon.jitter())
+        watcher := startAddrWatcher(u)
+        defer func() {
+                if watcher != nil {
+                        watcher.Close()
+                }
+        }()
+
+        // The watchdog pings from its own goroutine, but only while the check in progress (if any) is within its time limit
+        wd := startWatchdog()
+        defer wd.stop()
+        // Startup is complete once the configuration is loaded; the first check may take a while
+        notifySystemd("READY=1\nSTATUS=" + u.status())
+
+        for {
+                wd.beginCheck()
+                u.runOnce(ctx)
+                wd.endCheck()
+                if ctx.Err() != nil {
+                        log.Printf("[%s] ℹ️ Received termination signal, shutting down.", time.Now().Format("2006-01-02 15:04:05"))
+                        notifySystemd("STOPPING=1")
+                        return
+                }
+                notifySystemd("STATUS=" + u.status())
+
+                delay := u.config.Daemon.nextDelay()
+                log.Printf("[%s] ℹ️ Next check in %s.", time.Now().Format("2006-01-02 15:04:05"), delay.Round(time.Second))
+                timer := time.NewTimer(delay)
+                var events <-chan struct{} // nil (never ready) without a watcher
+                if watcher != nil {
+                        events = watcher.C
+                }
+
+        wait:
+                for {
+                        select {
+                        case <-timer.C:
+                                break wait
+                        case <-events:
+                                // Restart the wait on every change so a burst (e.g. PPPoE reconnect) triggers a single check
+                                log.Printf("[%s] ℹ️ Address change detected, checking in %s.", time.Now().Format("2006-01-02 15:04:05"), u.config.Daemon.debounce())
+                                // Drain without blocking: the timer may have fired while this event was pending
+                                timer.Stop()
+                                select {
+                                case <-timer.C:
+                                default:
+                                }
+                                timer.Reset(u.config.Daemon.debounce())
+                        case <-ctx.Done():
+                                timer.Stop()
+                                log.Printf("[%s] ℹ️ Received termination signal, shutting down.", time.Now().Format("2006-01-02 15:04:05"))
+                                notifySystemd("STOPPING=1")
+                                return
+                        case <-reload:
+                                timer.Stop()
+                                log.Printf("[%s] ℹ️ Received SIGHUP, reloading configuration from %s", time.Now().Format("2006-01-02 15:04:05"), u.configPath)
+                                notifySystemd("RELOADING=1")
+                                reloaded, err := newUpdater(u.configPath)
+                                if err != nil {
+                                        log.Printf("[%s] ❌ Error reloading configuration, keeping the previous one: %v", time.Now().Format("2006-01-02 15:04:05"), err)
+                                        notifySystemd("READY=1")
+                                        break wait
+                                }
+                                reloaded.lastUpdate = u.lastUpdate
+                                u = reloaded
+                                u.ignoreCache = true
+                                notifySystemd("READY=1\nSTATUS=" + u.status())
+                                // The watched interfaces may have changed with the configuration
+                                if watcher != nil {
+                                        watcher.Close()
+                                }
+                                watcher = startAddrWatcher(u)
+                                break wait
+                        }
+                }
+                log.Printf("[%s] ========= Starting Cloudflare DDNS Update =========", time.Now().Format("2006-01-02 15:04:05"))
+        }
+}
+
+// notifySystemd 向 systemd 发送状态通知, 失败只记录警告
+func notifySystemd(state string) {
+        if err := sdNotify(state); err != nil {
+                log.Printf("[%s] ⚠️ Warning: systemd notification failed: %v", time.Now().Format("2006-01-02 15:04:05"), err)
+        }
+}
+
+// startAddrWatcher 监听接口来源所用接口的地址变化; 没有接口来源或系统不支持时返回 nil
+func startAddrWatcher(u *updater) *addrWatcher {
+        var ifaces []string
+        for _, src := range u.sources {
+                if s, ok := src.(*interfaceSource); ok {
+                        ifaces = append(ifaces, s.iface)
+                }
+        }
+        if len(ifaces) == 0 {
+                return nil // External sources only: local address changes say nothing about the public address
+        }
+
+        watcher, err := watchAddrChanges(ifaces)
+        if err != nil {
+                log.Printf("[%s] ℹ️ Not watching for address changes (%v), relying on periodic checks.", time.Now().Format("2006-01-02 15:04:05"), err)
+                return nil
+        }
+        log.Printf("[%s] ℹ️ Watching %v for address changes (debounce %s).", time.Now().Format("2006-01-02 15:04:05"), ifaces, u.config.Daemon.debounce())
+        return watcher
+}
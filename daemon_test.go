@@ -0,0 +1,130 @@
+package main
+
+import (
+        "context"
+        "fmt"
+        "os"
+        "path/filepath"
+        "runtime"
+        "strings"
+        "syscall"
+        "testing"
+        "time"
+)
+
+func TestDaemonNextDelay(t *testing.T) {
+        tests := []struct {
+                config   *DaemonConfig
+                min, max time.Duration
+        }{
+                {nil, 300 * time.Second, 330 * time.Second},
+                {&DaemonConfig{Interval: 60, Jitter: intPtr(10)}, 60 * time.Second, 70 * time.Second},
+                {&DaemonConfig{Interval: 60, Jitter: intPtr(0)}, 60 * time.Second, 60 * time.Second},
+                {&DaemonConfig{Interval: 5}, 5 * time.Second, 5 * time.Second}, // 10% of 5s rounds down to no jitter
+        }
+        for _, tt := range tests {
+                seen := make(map[time.Duration]bool)
+                for i := 0; i < 1000; i++ {
+                        d := tt.config.nextDelay()
+                        if d < tt.min || d > tt.max {
+                                t.Fatalf("%+v: delay %s outside [%s, %s]", tt.config, d, tt.min, tt.max)
+                        }
+                        seen[d] = true
+                }
+                if tt.min != tt.max && len(seen) < 2 {
+                        t.Errorf("%+v: delay is not randomized", tt.config)
+                }
+        }
+}
+
+func TestDaemonConfigValidate(t *testing.T) {
+        for _, d := range []*DaemonConfig{
+                {Interval: -1},
+                {Debounce: -1},
+                {Jitter: intPtr(-1)},
+                {Interval: 60, Jitter: intPtr(60)},
+                {Jitter: intPtr(300)}, // default interval
+        } {
+                if err := d.validate(); err == nil {
+                        t.Errorf("validate(%+v) should fail", d)
+                }
+        }
+        for _, d := range []*DaemonConfig{nil, {}, {Interval: 60, Jitter: intPtr(59), Debounce: 5}, {Jitter: intPtr(0)}} {
+                if err := d.validate(); err != nil {
+                        t.Errorf("validate(%+v): %v", d, err)
+                }
+        }
+}
+
+// SIGHUP 重新加载配置后, 下一次检查忽略 .lastip 缓存, 之后的检查恢复使用缓存
+func TestRunDaemonReloadIgnoresCacheOnce(t *testing.T) {
+        if runtime.GOOS == "windows" {
+                t.Skip("no SIGHUP on Windows")
+        }
+        l := listenNotify(t, false)
+        t.Setenv("WATCHDOG_USEC", "")
+        s, srv := startDynDNS2Server(t)
+        configPath := filepath.Join(t.TempDir(), "config.json")
+        config := fmt.Sprintf(`{
+  "provider": "dyndns2",
+  "dyndns2": {"server": %q, "username": "user", "password": "pass"},
+  "ipversion": "ipv4",
+  "ip_sources": [{"type": "static", "value": "9.9.9.9"}],
+  "records": [{"name": "home.example.org"}],
+  "daemon": {"interval": 1, "jitter": 0}
+}`, srv.URL)
+        if err := os.WriteFile(configPath, []byte(config), 0600); err != nil {
+                t.Fatal(err)
+        }
+        u, err := newUpdater(configPath)
+        if err != nil {
+                t.Fatal(err)
+        }
+
+        ctx, cancel := context.WithCancel(context.Background())
+        done := make(chan struct{})
+        go func() {
+                runDaemon(ctx, u)
+                close(done)
+        }()
+        defer func() {
+                cancel()
+                <-done
+        }()
+
+        // checks 等待收到 n 条检查后的 STATUS 通知
+        checks := func(n int) {
+                t.Helper()
+                deadline := time.Now().Add(10 * time.Second)
+                for time.Now().Before(deadline) {
+                        count := 0
+                        for _, msg := range l.all() {
+                                if strings.HasPrefix(msg, "STATUS=") {
+                                        count++
+                                }
+                        }
+                        if count >= n {
+                                return
+                        }
+                        time.Sleep(10 * time.Millisecond)
+                }
+                t.Fatalf("fewer than %d checks, got %q", n, l.all())
+        }
+
+        checks(2) // first run updates, the second one hits the cache
+        if got := s.count(); got != 1 {
+                t.Fatalf("%d requests before the reload, want 1", got)
+        }
+        process, err := os.FindProcess(os.Getpid())
+        if err != nil {
+                t.Fatal(err)
+        }
+        if err := process.Signal(syscall.SIGHUP); err != nil {
+                t.Fatal(err)
+        }
+        l.waitFor(t, "RELOADING=1")
+        checks(4) // the check after the reload ignores the cache, the next one uses it again
+        if got := s.count(); got != 2 {
+                t.Fatalf("%d requests after the reload, want 2", got)
+        }
+}
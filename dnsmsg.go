@@ -0,0 +1,340 @@
+package main
+
+import (
+        "context"
+        "crypto/rand"
+        "encoding/binary"
+        "errors"
+        "fmt"
+        "io"
+        "net"
+        "strings"
+        "time"
+)
+
+// DNS 报文中用到的类型、类别和操作码
+const (
+        dnsTypeA    uint16 = 1
+        dnsTypeSOA  uint16 = 6
+        dnsTypeTXT  uint16 = 16
+        dnsTypeAAAA uint16 = 28
+        dnsTypeTSIG uint16 = 250
+        dnsTypeANY  uint16 = 255
+
+        dnsClassIN   uint16 = 1
+        dnsClassCH   uint16 = 3
+        dnsClassNONE uint16 = 254
+        dnsClassANY  uint16 = 255
+
+        dnsOpcodeQuery  = 0
+        dnsOpcodeUpdate = 5
+
+        dnsFlagQR = 1 << 15
+        dnsFlagTC = 1 << 9
+        dnsFlagRD = 1 << 8
+)
+
+// dnsRcodeNames 用于在错误信息中显示响应码
+var dnsRcodeNames = map[int]string{
+        0: "NOERROR", 1: "FORMERR", 2: "SERVFAIL", 3: "NXDOMAIN", 4: "NOTIMP", 5: "REFUSED",
+        6: "YXDOMAIN", 7: "YXRRSET", 8: "NXRRSET", 9: "NOTAUTH", 10: "NOTZONE",
+        16: "BADSIG", 17: "BADKEY", 18: "BADTIME",
+}
+
+// dnsQuestion 是 DNS 报文中的一个问题 (UPDATE 报文中为 Zone 段)
+type dnsQuestion struct {
+        Name  string
+        Type  uint16
+        Class uint16
+}
+
+// dnsRR 是一条资源记录, Data 为未解析的 RDATA
+type dnsRR struct {
+        Name  string
+        Type  uint16
+        Class uint16
+        TTL   uint32
+        Data  []byte
+}
+
+// dnsMessage 是一个简化的 DNS 报文, 仅支持本工具需要的功能
+// UPDATE 报文中 Answers 为 Prerequisite 段, Authority 为 Update 段
+type dnsMessage struct {
+        ID         uint16
+        Flags      uint16
+        Questions  []dnsQuestion
+        Answers    []dnsRR
+        Authority  []dnsRR
+        Additional []dnsRR
+}
+
+// newDNSQuery 创建一个带随机 ID 的递归查询报文
+func newDNSQuery(name string, qtype, qclass uint16) *dnsMessage {
+        return &dnsMessage{
+                ID:        randomDNSID(),
+                Flags:     dnsFlagRD,
+                Questions: []dnsQuestion{{Name: name, Type: qtype, Class: qclass}},
+        }
+}
+
+// randomDNSID 返回一个随机的报文 ID
+func randomDNSID() uint16 {
+        var b [2]byte
+        if _, err := rand.Read(b[:]); err != nil {
+                return uint16(time.Now().UnixNano())
+        }
+        return binary.BigEndian.Uint16(b[:])
+}
+
+// Opcode 返回报文的操作码
+func (m *dnsMessage) Opcode() int {
+        return int(m.Flags>>11) & 0xf
+}
+
+// Rcode 返回报文的响应码
+func (m *dnsMessage) Rcode() int {
+        return int(m.Flags & 0xf)
+}
+
+// rcodeName 返回响应码的名称
+func rcodeName(rcode int) string {
+        if name, ok := dnsRcodeNames[rcode]; ok {
+                return name
+        }
+        return fmt.Sprintf("RCODE%d", rcode)
+}
+
+// Pack 将报文编码为线路格式 (不使用名称压缩)
+func (m *dnsMessage) Pack() ([]byte, error) {
+        b := make([]byte, 12, 512)
+        binary.BigEndian.PutUint16(b[0:], m.ID)
+        binary.BigEndian.PutUint16(b[2:], m.Flags)
+        binary.BigEndian.PutUint16(b[4:], uint16(len(m.Questions)))
+        binary.BigEndian.PutUint16(b[6:], uint16(len(m.Answers)))
+        binary.BigEndian.PutUint16(b[8:], uint16(len(m.Authority)))
+        binary.BigEndian.PutUint16(b[10:], uint16(len(m.Additional)))
+
+        var err error
+        for _, q := range m.Questions {
+                if b, err = appendDNSName(b, q.Name); err != nil {
+                        return nil, err
+                }
+                b = binary.BigEndian.AppendUint16(b, q.Type)
+                b = binary.BigEndian.AppendUint16(b, q.Class)
+        }
+        for _, section := range [][]dnsRR{m.Answers, m.Authority, m.Additional} {
+                for _, rr := range section {
+                        if b, err = appendDNSRR(b, rr); err != nil {
+                                return nil, err
+                        }
+                }
+        }
+        return b, nil
+}
+
+// appendDNSRR 将一条资源记录追加到 b
+func appendDNSRR(b []byte, rr dnsRR) ([]byte, error) {
+        b, err := appendDNSName(b, rr.Name)
+        if err != nil {
+                return nil, err
+        }
+        if len(rr.Data) > 0xffff {
+                return nil, fmt.Errorf("rdata of %s too long", rr.Name)
+        }
+        b = binary.BigEndian.AppendUint16(b, rr.Type)
+        b = binary.BigEndian.AppendUint16(b, rr.Class)
+        b = binary.BigEndian.AppendUint32(b, rr.TTL)
+        b = binary.BigEndian.AppendUint16(b, uint16(len(rr.Data)))
+        return append(b, rr.Data...), nil
+}
+
+// appendDNSName 将域名以未压缩的线路格式追加到 b
+func appendDNSName(b []byte, name string) ([]byte, error) {
+        name = strings.TrimSuffix(name, ".")
+        if name != "" {
+                for _, label := range strings.Split(name, ".") {
+                        if len(label) == 0 || len(label) > 63 {
+                                return nil, fmt.Errorf("invalid DNS name '%s'", name)
+                        }
+                        b = append(b, byte(len(label)))
+                        b = append(b, label...)
+                }
+        }
+        return append(b, 0), nil
+}
+
+// parseDNSMessage 解析线路格式的 DNS 报文
+func parseDNSMessage(msg []byte) (*dnsMessage, error) {
+        if len(msg) < 12 {
+                return nil, errors.New("DNS message too short")
+        }
+        m := &dnsMessage{
+                ID:    binary.BigEndian.Uint16(msg[0:]),
+                Flags: binary.BigEndian.Uint16(msg[2:]),
+        }
+        counts := [4]int{
+                int(binary.BigEndian.Uint16(msg[4:])),
+                int(binary.BigEndian.Uint16(msg[6:])),
+                int(binary.BigEndian.Uint16(msg[8:])),
+                int(binary.BigEndian.Uint16(msg[10:])),
+        }
+
+        off := 12
+        for i := 0; i < counts[0]; i++ {
+                name, next, err := readDNSName(msg, off)
+                if err != nil {
+                        return nil, err
+                }
+                if next+4 > len(msg) {
+                        return nil, errors.New("DNS question truncated")
+                }
+                m.Questions = append(m.Questions, dnsQuestion{
+                        Name:  name,
+                        Type:  binary.BigEndian.Uint16(msg[next:]),
+                        Class: binary.BigEndian.Uint16(msg[next+2:]),
+                })
+                off = next + 4
+        }
+
+        sections := []*[]dnsRR{&m.Answers, &m.Authority, &m.Additional}
+        for s, section := range sections {
+                for i := 0; i < counts[s+1]; i++ {
+                        name, next, err := readDNSName(msg, off)
+                        if err != nil {
+                                return nil, err
+                        }
+                        if next+10 > len(msg) {
+                                return nil, errors.New("DNS resource record truncated")
+                        }
+                        rdlen := int(binary.BigEndian.Uint16(msg[next+8:]))
+                        if next+10+rdlen > len(msg) {
+                                return nil, errors.New("DNS resource record data truncated")
+                        }
+                        *section = append(*section, dnsRR{
+                                Name:  name,
+                                Type:  binary.BigEndian.Uint16(msg[next:]),
+                                Class: binary.BigEndian.Uint16(msg[next+2:]),
+                                TTL:   binary.BigEndian.Uint32(msg[next+4:]),
+                                Data:  msg[next+10 : next+10+rdlen],
+                        })
+                        off = next + 10 + rdlen
+                }
+        }
+        return m, nil
+}
+
+// readDNSName 从 off 处读取一个 (可能被压缩的) 域名, 返回域名和其后的偏移
+func readDNSName(msg []byte, off int) (string, int, error) {
+        var labels []string
+        next := -1
+        for hops := 0; ; hops++ {
+                if off >= len(msg) || hops > 127 {
+                        return "", 0, errors.New("invalid DNS name")
+                }
+                l := int(msg[off])
+                switch {
+                case l == 0:
+                        if next < 0 {
+                                next = off + 1
+                        }
+                        return strings.Join(labels, ".") + ".", next, nil
+                case l&0xc0 == 0xc0:
+                        if off+1 >= len(msg) {
+                                return "", 0, errors.New("invalid DNS name pointer")
+                        }
+                        if next < 0 {
+                                next = off + 2
+                        }
+                        off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+                default:
+                        if off+1+l > len(msg) {
+                                return "", 0, errors.New("DNS name label truncated")
+                        }
+                        labels = append(labels, string(msg[off+1:off+1+l]))
+                        off += 1 + l
+                }
+        }
+}
+
+// parseTXT 解析 TXT 记录的 RDATA, 返回其中的全部字符串
+func parseTXT(data []byte) []string {
+        var out []string
+        for len(data) > 0 {
+                l := int(data[0])
+                if 1+l > len(data) {
+                        break
+                }
+                out = append(out, string(data[1:1+l]))
+                data = data[1+l:]
+        }
+        return out
+}
+
+// dnsExchange 向 server 发送报文并返回解析后的响应
+// 先使用 UDP, 响应被截断 (TC) 时改用 TCP 重试; network 为 "udp", "udp4" 或 "udp6"
+func dnsExchange(ctx context.Context, network, server string, query []byte) ([]byte, error) {
+        resp, err := dnsExchangeUDP(ctx, network, server, query)
+        if err != nil {
+                return nil, err
+        }
+        if len(resp) >= 4 && binary.BigEndian.Uint16(resp[2:])&dnsFlagTC != 0 {
+                tcpNetwork := "tcp" + strings.TrimPrefix(network, "udp")
+                return dnsExchangeTCP(ctx, tcpNetwork, server, query)
+        }
+        return resp, nil
+}
+
+// dnsExchangeUDP 通过 UDP 发送查询并等待 ID 匹配的响应
+func dnsExchangeUDP(ctx context.Context, network, server string, query []byte) ([]byte, error) {
+        var d net.Dialer
+        conn, err := d.DialContext(ctx, network, server)
+        if err != nil {
+                return nil, fmt.Errorf("dialing DNS server %s failed: %w", server, err)
+        }
+        defer conn.Close()
+        if deadline, ok := ctx.Deadline(); ok {
+                conn.SetDeadline(deadline)
+        }
+
+        if _, err := conn.Write(query); err != nil {
+                return nil, fmt.Errorf("sending DNS query to %s failed: %w", server, err)
+        }
+        buf := make([]byte, 65535)
+        for {
+                n, err := conn.Read(buf)
+                if err != nil {
+                        return nil, fmt.Errorf("reading DNS response from %s failed: %w", server, err)
+                }
+                // 忽略 ID 不匹配的响应 (可能是迟到的旧响应或伪造包)
+                if n >= 12 && buf[0] == query[0] && buf[1] == query[1] {
+                        return append([]byte(nil), buf[:n]...), nil
+                }
+        }
+}
+
+// dnsExchangeTCP 通过 TCP 发送查询 (带两字节长度前缀)
+func dnsExchangeTCP(ctx context.Context, network, server string, query []byte) ([]byte, error) {
+        var d net.Dialer
+        conn, err := d.DialContext(ctx, network, server)
+        if err != nil {
+                return nil, fmt.Errorf("dialing DNS server %s over TCP failed: %w", server, err)
+        }
+        defer conn.Close()
+        if deadline, ok := ctx.Deadline(); ok {
+                conn.SetDeadline(deadline)
+        }
+
+        framed := binary.BigEndian.AppendUint16(make([]byte, 0, len(query)+2), uint16(len(query)))
+        if _, err := conn.Write(append(framed, query...)); err != nil {
+                return nil, fmt.Errorf("sending DNS query to %s failed: %w", server, err)
+        }
+        var lenBuf [2]byte
+        if _, err := io.ReadFull(conn, lenBuf[:]); err != nil {
+                return nil, fmt.Errorf("reading DNS response from %s failed: %w", server, err)
+        }
+        resp := make([]byte, binary.BigEndian.Uint16(lenBuf[:]))
+        if _, err := io.ReadFull(conn, resp); err != nil {
+                return nil, fmt.Errorf("reading DNS response from %s failed: %w", server, err)
+        }
+        return resp, nil
+}
@@ -0,0 +1,53 @@
+//go:build linux
+
+package main
+
+import (
+        "bufio"
+        "encoding/binary"
+        "encoding/hex"
+        "errors"
+        "io"
+        "net"
+        "os"
+        "strings"
+)
+
+// defaultGateway 从 /proc/net/route 读取 IPv4 默认路由的网关地址
+func defaultGateway() (net.IP, error) {
+        file, err := os.Open("/proc/net/route")
+        if err != nil {
+                return nil, err
+        }
+        defer file.Close()
+        return parseRouteTable(file)
+}
+
+// parseRouteTable 解析 /proc/net/route 格式的路由表, 返回 IPv4 默认路由的网关
+func parseRouteTable(r io.Reader) (net.IP, error) {
+        scanner := bufio.NewScanner(r)
+        scanner.Scan() // 跳过表头
+        for scanner.Scan() {
+                // Iface Destination Gateway Flags ...
+                fields := strings.Fields(scanner.Text())
+                if len(fields) < 4 || fields[1] != "00000000" {
+                        continue
+                }
+                flags, err := hex.DecodeString(fields[3])
+                if err != nil || len(flags) != 2 || flags[1]&0x2 == 0 { // RTF_GATEWAY
+                        continue
+                }
+                gw, err := hex.DecodeString(fields[2])
+                if err != nil || len(gw) != 4 {
+                        continue
+                }
+                // 内核以主机字节序的整数打印网络字节序的地址, 按主机字节序还原
+                ip := make(net.IP, 4)
+                binary.BigEndian.PutUint32(ip, binary.NativeEndian.Uint32(gw))
+                return ip, nil
+        }
+        if err := scanner.Err(); err != nil {
+                return nil, err
+        }
+        return nil, errors.New("no IPv4 default route found")
+}
@@ -0,0 +1,32 @@
+//go:build linux
+
+package main
+
+import (
+        "encoding/binary"
+        "fmt"
+        "net"
+        "strings"
+        "testing"
+)
+
+// routeHex 按内核的方式打印地址: 把网络字节序的 4 字节当作主机字节序整数输出
+func routeHex(ip string) string {
+        return fmt.Sprintf("%08X", binary.NativeEndian.Uint32(net.ParseIP(ip).To4()))
+}
+
+func TestParseRouteTable(t *testing.T) {
+        table := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+                "wg0\t" + routeHex("10.8.0.0") + "\t00000000\t0001\t0\t0\t0\t00FFFFFF\t0\t0\t0\n" +
+                "eth0\t00000000\t00000000\t0001\t0\t0\t0\t00000000\t0\t0\t0\n" + // 没有 RTF_GATEWAY 的默认路由
+                "eth0\t00000000\t" + routeHex("192.168.1.254") + "\t0003\t0\t0\t100\t00000000\t0\t0\t0\n"
+        gw, err := parseRouteTable(strings.NewReader(table))
+        if err != nil || gw.String() != "192.168.1.254" {
+                t.Fatalf("got %v, %v; want 192.168.1.254", gw, err)
+        }
+
+        _, err = parseRouteTable(strings.NewReader("Iface\tDestination\tGateway\n"))
+        if err == nil || !strings.Contains(err.Error(), "no IPv4 default route") {
+                t.Fatalf("error = %v", err)
+        }
+}
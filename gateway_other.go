@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+        "errors"
+        "net"
+)
+
+// defaultGateway 在非 Linux 平台上不可用, 需要在配置中显式指定 gateway
+func defaultGateway() (net.IP, error) {
+        return nil, errors.New("default gateway lookup is only supported on Linux")
+}
@@ -0,0 +1,3 @@
+module github.com/Solvris/NetTools
+
+go 1.21
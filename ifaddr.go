@@ -0,0 +1,260 @@
+package main
+
+import (
+        "fmt"
+        "net"
+        "strconv"
+        "strings"
+        "time"
+)
+
+// IP 获取方式 (Config.IPMethod)
+const (
+        ipMethodNative  = "native"  // 原生查询 (Linux 上使用 rtnetlink), 默认
+        ipMethodCommand = "command" // 调用 ip / ifconfig 命令并解析输出 (回退模式)
+)
+
+// lifetimeForever 表示地址的首选/有效期为永久 (内核中为 0xffffffff)
+const lifetimeForever = time.Duration(1<<63 - 1)
+
+// addrScope 对应 rtnetlink 中的 ifa_scope (RT_SCOPE_*)
+type addrScope uint8
+
+const (
+        scopeUniverse addrScope = 0
+        scopeSite     addrScope = 200
+        scopeLink     addrScope = 253
+        scopeHost     addrScope = 254
+        scopeNowhere  addrScope = 255
+)
+
+func (s addrScope) String() string {
+        switch s {
+        case scopeUniverse:
+                return "global"
+        case scopeSite:
+                return "site"
+        case scopeLink:
+                return "link"
+        case scopeHost:
+                return "host"
+        case scopeNowhere:
+                return "nowhere"
+        }
+        return fmt.Sprintf("scope(%d)", uint8(s))
+}
+
+// addrFlags 对应 rtnetlink 中的 IFA_F_* 地址标志
+type addrFlags uint32
+
+const (
+        flagSecondary     addrFlags = 0x01 // IFA_F_SECONDARY, IPv6 下即 IFA_F_TEMPORARY
+        flagNoDAD         addrFlags = 0x02
+        flagOptimistic    addrFlags = 0x04
+        flagDADFailed     addrFlags = 0x08
+        flagHomeAddress   addrFlags = 0x10
+        flagDeprecated    addrFlags = 0x20
+        flagTentative     addrFlags = 0x40
+        flagPermanent     addrFlags = 0x80
+        flagManageTemp    addrFlags = 0x100
+        flagNoPrefixRoute addrFlags = 0x200
+        flagMcAutoJoin    addrFlags = 0x400
+        flagStablePrivacy addrFlags = 0x800
+
+        flagTemporary = flagSecondary
+)
+
+var addrFlagNames = []struct {
+        flag addrFlags
+        name string
+}{
+        {flagSecondary, "secondary"},
+        {flagNoDAD, "nodad"},
+        {flagOptimistic, "optimistic"},
+        {flagDADFailed, "dadfailed"},
+        {flagHomeAddress, "homeaddress"},
+        {flagDeprecated, "deprecated"},
+        {flagTentative, "tentative"},
+        {flagPermanent, "permanent"},
+        {flagManageTemp, "mngtmpaddr"},
+        {flagNoPrefixRoute, "noprefixroute"},
+        {flagMcAutoJoin, "autojoin"},
+        {flagStablePrivacy, "stable-privacy"},
+}
+
+func (f addrFlags) String() string {
+        var names []string
+        for _, fn := range addrFlagNames {
+                if f&fn.flag != 0 {
+                        names = append(names, fn.name)
+                }
+        }
+        if len(names) == 0 {
+                return "-"
+        }
+        return strings.Join(names, ",")
+}
+
+// InterfaceAddr 描述接口上的一个地址及其 rtnetlink 属性
+// 在非 Linux 平台上 Flags 为 0, 生命周期为永久, Scope 由地址本身推断
+type InterfaceAddr struct {
+        IP                net.IP
+        PrefixLen         int
+        Scope             addrScope
+        Flags             addrFlags
+        PreferredLifetime time.Duration
+        ValidLifetime     time.Duration
+}
+
+// IsTemporary 报告地址是否为 IPv6 临时 (RFC 4941 隐私) 地址
+func (a InterfaceAddr) IsTemporary() bool {
+        return a.IP.To4() == nil && a.Flags&flagTemporary != 0
+}
+
+func (a InterfaceAddr) String() string {
+        return fmt.Sprintf("%s/%d scope %s flags %s preferred %s valid %s",
+                a.IP, a.PrefixLen, a.Scope, formatAddrFlags(a.IP, a.Flags), formatLifetime(a.PreferredLifetime), formatLifetime(a.ValidLifetime))
+}
+
+// formatAddrFlags 按地址族格式化标志, IPv6 下 0x01 显示为 temporary (与 'ip addr' 输出一致)
+func formatAddrFlags(ip net.IP, f addrFlags) string {
+        s := f.String()
+        if ip.To4() == nil {
+                s = strings.Replace(s, "secondary", "temporary", 1)
+        }
+        return s
+}
+
+// formatLifetime 将生命周期格式化为可读字符串
+func formatLifetime(d time.Duration) string {
+        if d == lifetimeForever {
+                return "forever"
+        }
+        return d.String()
+}
+
+// lifetimeFromSeconds 将内核返回的秒数转换为 time.Duration (0xffffffff 表示永久)
+func lifetimeFromSeconds(sec uint32) time.Duration {
+        if sec == 0xffffffff {
+                return lifetimeForever
+        }
+        return time.Duration(sec) * time.Second
+}
+
+// scopeFromIP 在无法获得内核 scope 信息时根据地址本身推断 scope
+func scopeFromIP(ip net.IP) addrScope {
+        switch {
+        case ip.IsLoopback():
+                return scopeHost
+        case ip.IsLinkLocalUnicast(), ip.IsLinkLocalMulticast():
+                return scopeLink
+        }
+        return scopeUniverse
+}
+
+// matchesIPVersion 判断地址是否属于 "ipv4" 或 "ipv6" 地址族
+func matchesIPVersion(ip net.IP, ipversion string) bool {
+        if ipversion == "ipv6" {
+                return ip.To4() == nil && len(ip) == net.IPv6len
+        }
+        return ip.To4() != nil
+}
+
+// parseIPAddrOutput 解析 'ip addr show' 的输出, 读取地址、scope、标志和生命周期
+//
+//      inet6 2001:db8::1234/64 scope global temporary dynamic
+//         valid_lft 86300sec preferred_lft 14300sec
+func parseIPAddrOutput(output string) []InterfaceAddr {
+        var addrs []InterfaceAddr
+        for _, line := range strings.Split(output, "\n") {
+                fields := strings.Fields(line)
+                if len(fields) < 2 {
+                        continue
+                }
+                switch fields[0] {
+                case "inet", "inet6":
+                        addr, ok := parseIPAddrLine(fields)
+                        if ok {
+                                addrs = append(addrs, addr)
+                        }
+                case "valid_lft":
+                        if len(addrs) == 0 {
+                                continue
+                        }
+                        last := &addrs[len(addrs)-1]
+                        for i := 0; i+1 < len(fields); i += 2 {
+                                switch fields[i] {
+                                case "valid_lft":
+                                        last.ValidLifetime = parseLifetimeWord(fields[i+1])
+                                case "preferred_lft":
+                                        last.PreferredLifetime = parseLifetimeWord(fields[i+1])
+                                }
+                        }
+                }
+        }
+        return addrs
+}
+
+// parseIPAddrLine 解析 inet/inet6 行: 地址 (点对点链路上可能没有前缀长度), scope 和标志词
+func parseIPAddrLine(fields []string) (InterfaceAddr, bool) {
+        addrStr, lenStr, hasLen := strings.Cut(fields[1], "/")
+        ip := net.ParseIP(addrStr)
+        if ip == nil {
+                return InterfaceAddr{}, false
+        }
+        addr := InterfaceAddr{
+                IP:                ip,
+                PrefixLen:         len(ip) * 8,
+                Scope:             scopeFromIP(ip),
+                PreferredLifetime: lifetimeForever,
+                ValidLifetime:     lifetimeForever,
+        }
+        if ip.To4() != nil {
+                addr.PrefixLen = 32
+        }
+        if hasLen {
+                if n, err := strconv.Atoi(lenStr); err == nil {
+                        addr.PrefixLen = n
+                }
+        }
+        for i := 2; i < len(fields); i++ {
+                word := fields[i]
+                if word == "scope" && i+1 < len(fields) {
+                        i++
+                        addr.Scope = parseScopeWord(fields[i], addr.Scope)
+                        continue
+                }
+                if word == "temporary" {
+                        addr.Flags |= flagTemporary
+                        continue
+                }
+                for _, fn := range addrFlagNames {
+                        if word == fn.name {
+                                addr.Flags |= fn.flag
+                        }
+                }
+        }
+        return addr, true
+}
+
+// parseScopeWord 解析 'ip' 输出中的 scope 名称或数字, 无法识别时返回 def
+func parseScopeWord(word string, def addrScope) addrScope {
+        for _, s := range []addrScope{scopeUniverse, scopeSite, scopeLink, scopeHost, scopeNowhere} {
+                if word == s.String() {
+                        return s
+                }
+        }
+        if n, err := strconv.ParseUint(word, 10, 8); err == nil {
+                return addrScope(n)
+        }
+        return def
+}
+
+// parseLifetimeWord 解析 "forever" 或 "14300sec" 形式的生命周期
+func parseLifetimeWord(word string) time.Duration {
+        sec, err := strconv.ParseUint(strings.TrimSuffix(word, "sec"), 10, 32)
+        if err != nil {
+                return lifetimeForever
+        }
+        return lifetimeFromSeconds(uint32(sec))
+}
@@ -0,0 +1,98 @@
+//go:build linux
+
+package main
+
+import (
+        "encoding/binary"
+        "fmt"
+        "net"
+        "syscall"
+)
+
+// 部分 rtnetlink 常量在 syscall 包中没有定义
+const (
+        ifaCacheInfo = 6 // IFA_CACHEINFO
+        ifaFlags     = 8 // IFA_FLAGS, 32 位扩展标志
+)
+
+// listInterfaceAddrs 通过 rtnetlink (RTM_GETADDR) 获取接口上的全部地址及其属性
+func listInterfaceAddrs(iface string) ([]InterfaceAddr, error) {
+        ifi, err := net.InterfaceByName(iface)
+        if err != nil {
+                return nil, fmt.Errorf("looking up interface '%s' failed: %w", iface, err)
+        }
+
+        rib, err := syscall.NetlinkRIB(syscall.RTM_GETADDR, syscall.AF_UNSPEC)
+        if err != nil {
+                return nil, fmt.Errorf("netlink RTM_GETADDR dump failed: %w", err)
+        }
+        msgs, err := syscall.ParseNetlinkMessage(rib)
+        if err != nil {
+                return nil, fmt.Errorf("parsing netlink messages failed: %w", err)
+        }
+
+        var addrs []InterfaceAddr
+        for i := range msgs {
+                m := &msgs[i]
+                if m.Header.Type == syscall.NLMSG_DONE {
+                        break
+                }
+                if m.Header.Type != syscall.RTM_NEWADDR {
+                        continue
+                }
+                addr, index, ok := parseAddrMessage(m)
+                if ok && index == ifi.Index {
+                        addrs = append(addrs, addr)
+                }
+        }
+        return addrs, nil
+}
+
+// parseAddrMessage 解析一条 RTM_NEWADDR/RTM_DELADDR 消息, 返回地址及其所属接口索引
+func parseAddrMessage(m *syscall.NetlinkMessage) (InterfaceAddr, int, bool) {
+        if len(m.Data) < syscall.SizeofIfAddrmsg {
+                return InterfaceAddr{}, 0, false
+        }
+        // struct ifaddrmsg { family, prefixlen, flags, scope uint8; index uint32 }
+        addr := InterfaceAddr{
+                PrefixLen:         int(m.Data[1]),
+                Flags:             addrFlags(m.Data[2]),
+                Scope:             addrScope(m.Data[3]),
+                PreferredLifetime: lifetimeForever,
+                ValidLifetime:     lifetimeForever,
+        }
+        index := int(binary.NativeEndian.Uint32(m.Data[4:8]))
+
+        attrs, err := syscall.ParseNetlinkRouteAttr(m)
+        if err != nil {
+                return InterfaceAddr{}, 0, false
+        }
+        var address, local net.IP
+        for _, a := range attrs {
+                switch a.Attr.Type {
+                case syscall.IFA_ADDRESS:
+                        address = net.IP(append([]byte(nil), a.Value...))
+                case syscall.IFA_LOCAL:
+                        local = net.IP(append([]byte(nil), a.Value...))
+                case ifaFlags:
+                        if len(a.Value) >= 4 {
+                                addr.Flags = addrFlags(binary.NativeEndian.Uint32(a.Value))
+                        }
+                case ifaCacheInfo:
+                        // struct ifa_cacheinfo { ifa_prefered, ifa_valid, cstamp, tstamp uint32 }
+                        if len(a.Value) >= 8 {
+                                addr.PreferredLifetime = lifetimeFromSeconds(binary.NativeEndian.Uint32(a.Value[0:4]))
+                                addr.ValidLifetime = lifetimeFromSeconds(binary.NativeEndian.Uint32(a.Value[4:8]))
+                        }
+                }
+        }
+        // 点对点链路 (如 PPPoE) 上 IFA_ADDRESS 是对端地址, IFA_LOCAL 才是本机地址
+        addr.IP = address
+        if local != nil {
+                addr.IP = local
+        }
+        if addr.IP == nil {
+                return InterfaceAddr{}, 0, false
+        }
+        return addr, index, true
+}
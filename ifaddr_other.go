@@ -0,0 +1,38 @@
+//go:build !linux
+
+package main
+
+import (
+        "fmt"
+        "net"
+)
+
+// listInterfaceAddrs 通过 net.Interface.Addrs 获取接口上的地址
+// 非 Linux 平台无法获得 rtnetlink 属性, 标志为空且生命周期视为永久
+func listInterfaceAddrs(iface string) ([]InterfaceAddr, error) {
+        ifi, err := net.InterfaceByName(iface)
+        if err != nil {
+                return nil, fmt.Errorf("looking up interface '%s' failed: %w", iface, err)
+        }
+        ifAddrs, err := ifi.Addrs()
+        if err != nil {
+                return nil, fmt.Errorf("listing addresses of interface '%s' failed: %w", iface, err)
+        }
+
+        var addrs []InterfaceAddr
+        for _, a := range ifAddrs {
+                ipNet, ok := a.(*net.IPNet)
+                if !ok {
+                        continue
+                }
+                ones, _ := ipNet.Mask.Size()
+                addrs = append(addrs, InterfaceAddr{
+                        IP:                ipNet.IP,
+                        PrefixLen:         ones,
+                        Scope:             scopeFromIP(ipNet.IP),
+                        PreferredLifetime: lifetimeForever,
+                        ValidLifetime:     lifetimeForever,
+                })
+        }
+        return addrs, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+        "net"
+        "testing"
+        "time"
+)
+
+func TestParseIPAddrOutput(t *testing.T) {
+        output := `2: eth0: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc fq_codel state UP group default qlen 1000
+    link/ether 00:11:22:33:44:55 brd ff:ff:ff:ff:ff:ff
+    inet 45.80.16.1/29 brd 45.80.16.7 scope global eth0
+       valid_lft forever preferred_lft forever
+    inet 45.80.16.2/29 brd 45.80.16.7 scope global secondary eth0
+       valid_lft forever preferred_lft forever
+    inet6 2a01:4f8:1:2:a1b2:c3d4:e5f6:1/64 scope global temporary dynamic
+       valid_lft 86300sec preferred_lft 14300sec
+    inet6 2a01:4f8:1:2:211:22ff:fe33:4455/64 scope global dynamic mngtmpaddr noprefixroute
+       valid_lft 86300sec preferred_lft 14300sec
+    inet6 2a01:4f8:1:2::dead/64 scope global deprecated dynamic
+       valid_lft 3000sec preferred_lft 0sec
+    inet6 2a01:4f8:1:2::bad/64 scope global tentative dadfailed
+       valid_lft forever preferred_lft forever
+    inet6 fe80::211:22ff:fe33:4455/64 scope link
+       valid_lft forever preferred_lft forever
+3: ppp0: <POINTOPOINT,MULTICAST,NOARP,UP,LOWER_UP> mtu 1492 qdisc fq_codel state UNKNOWN group default qlen 3
+    inet 81.2.69.160 peer 10.64.64.64/32 scope global ppp0
+       valid_lft forever preferred_lft forever
+`
+        want := []InterfaceAddr{
+                {IP: net.ParseIP("45.80.16.1"), PrefixLen: 29, Scope: scopeUniverse, PreferredLifetime: lifetimeForever, ValidLifetime: lifetimeForever},
+                {IP: net.ParseIP("45.80.16.2"), PrefixLen: 29, Scope: scopeUniverse, Flags: flagSecondary, PreferredLifetime: lifetimeForever, ValidLifetime: lifetimeForever},
+                {IP: net.ParseIP("2a01:4f8:1:2:a1b2:c3d4:e5f6:1"), PrefixLen: 64, Scope: scopeUniverse, Flags: flagTemporary, PreferredLifetime: 14300 * time.Second, ValidLifetime: 86300 * time.Second},
+                {IP: net.ParseIP("2a01:4f8:1:2:211:22ff:fe33:4455"), PrefixLen: 64, Scope: scopeUniverse, Flags: flagManageTemp | flagNoPrefixRoute, PreferredLifetime: 14300 * time.Second, ValidLifetime: 86300 * time.Second},
+                {IP: net.ParseIP("2a01:4f8:1:2::dead"), PrefixLen: 64, Scope: scopeUniverse, Flags: flagDeprecated, PreferredLifetime: 0, ValidLifetime: 3000 * time.Second},
+                {IP: net.ParseIP("2a01:4f8:1:2::bad"), PrefixLen: 64, Scope: scopeUniverse, Flags: flagTentative | flagDADFailed, PreferredLifetime: lifetimeForever, ValidLifetime: lifetimeForever},
+                {IP: net.ParseIP("fe80::211:22ff:fe33:4455"), PrefixLen: 64, Scope: scopeLink, PreferredLifetime: lifetimeForever, ValidLifetime: lifetimeForever},
+                {IP: net.ParseIP("81.2.69.160"), PrefixLen: 32, Scope: scopeUniverse, PreferredLifetime: lifetimeForever, ValidLifetime: lifetimeForever},
+        }
+        got := parseIPAddrOutput(output)
+        if len(got) != len(want) {
+                t.Fatalf("got %d addresses, want %d: %v", len(got), len(want), got)
+        }
+        for i := range want {
+                if got[i].String() != want[i].String() {
+                        t.Errorf("address %d:\n got %s\nwant %s", i, got[i], want[i])
+                }
+        }
+
+        // 解析结果交给 stable 策略时应跳过临时和弃用地址
+        addr, err := (*addrSelector)(nil).selectAddr(got, "ipv6")
+        if err != nil || addr.IP.String() != "2a01:4f8:1:2:211:22ff:fe33:4455" {
+                t.Fatalf("selected %v, %v", addr.IP, err)
+        }
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+        "fmt"
+        "log"
+        "net"
+        "net/netip"
+        "time"
+)
+
+// specialRange 是 IANA 特殊用途地址注册表 (IPv4/IPv6 Special-Purpose Address Registry) 中的一个地址段
+type specialRange struct {
+        prefix netip.Prefix
+        name   string
+        // reachable 对应注册表中的 "Globally Reachable" 列; 为 true 的条目用于豁免更大的不可用地址段
+        reachable bool
+}
+
+// specialRanges 是 IANA 特殊用途地址注册表, 另外补充了组播和 6to4/Teredo 等不适合作为 DNS 记录的地址段
+// 匹配时取最长前缀
+var specialRanges = []specialRange{
+        // IPv4
+        {netip.MustParsePrefix("0.0.0.0/8"), "\"this network\" (RFC 791)", false},
+        {netip.MustParsePrefix("10.0.0.0/8"), "private-use (RFC 1918)", false},
+        {netip.MustParsePrefix("100.64.0.0/10"), "shared address space / CGNAT (RFC 6598)", false},
+        {netip.MustParsePrefix("127.0.0.0/8"), "loopback (RFC 1122)", false},
+        {netip.MustParsePrefix("169.254.0.0/16"), "link-local (RFC 3927)", false},
+        {netip.MustParsePrefix("172.16.0.0/12"), "private-use (RFC 1918)", false},
+        {netip.MustParsePrefix("192.0.0.0/24"), "IETF protocol assignments (RFC 6890)", false},
+        {netip.MustParsePrefix("192.0.0.0/29"), "IPv4 service continuity prefix / DS-Lite (RFC 7335)", false},
+        {netip.MustParsePrefix("192.0.0.8/32"), "IPv4 dummy address (RFC 7600)", false},
+        {netip.MustParsePrefix("192.0.0.9/32"), "port control protocol anycast (RFC 7723)", true},
+        {netip.MustParsePrefix("192.0.0.10/32"), "TURN anycast (RFC 8155)", true},
+        {netip.MustParsePrefix("192.0.0.170/31"), "NAT64/DNS64 discovery (RFC 7050)", false},
+        {netip.MustParsePrefix("192.0.2.0/24"), "documentation TEST-NET-1 (RFC 5737)", false},
+        {netip.MustParsePrefix("192.31.196.0/24"), "AS112-v4 (RFC 7535)", true},
+        {netip.MustParsePrefix("192.52.193.0/24"), "AMT (RFC 7450)", true},
+        {netip.MustParsePrefix("192.88.99.0/24"), "deprecated 6to4 relay anycast (RFC 7526)", false},
+        {netip.MustParsePrefix("192.168.0.0/16"), "private-use (RFC 1918)", false},
+        {netip.MustParsePrefix("192.175.48.0/24"), "direct delegation AS112 service (RFC 7534)", true},
+        {netip.MustParsePrefix("198.18.0.0/15"), "benchmarking (RFC 2544)", false},
+        {netip.MustParsePrefix("198.51.100.0/24"), "documentation TEST-NET-2 (RFC 5737)", false},
+        {netip.MustParsePrefix("203.0.113.0/24"), "documentation TEST-NET-3 (RFC 5737)", false},
+        {netip.MustParsePrefix("224.0.0.0/4"), "multicast (RFC 5771)", false},
+        {netip.MustParsePrefix("240.0.0.0/4"), "reserved (RFC 1112)", false},
+        {netip.MustParsePrefix("255.255.255.255/32"), "limited broadcast (RFC 919)", false},
+
+        // IPv6
+        {netip.MustParsePrefix("::/128"), "unspecified address (RFC 4291)", false},
+        {netip.MustParsePrefix("::1/128"), "loopback (RFC 4291)", false},
+        {netip.MustParsePrefix("::ffff:0:0/96"), "IPv4-mapped address (RFC 4291)", false},
+        {netip.MustParsePrefix("64:ff9b::/96"), "IPv4/IPv6 translation well-known prefix (RFC 6052)", true},
+        {netip.MustParsePrefix("64:ff9b:1::/48"), "local-use IPv4/IPv6 translation (RFC 8215)", false},
+        {netip.MustParsePrefix("100::/64"), "discard-only (RFC 6666)", false},
+        {netip.MustParsePrefix("2001::/23"), "IETF protocol assignments (RFC 2928)", false},
+        {netip.MustParsePrefix("2001::/32"), "Teredo (RFC 4380)", false},
+        {netip.MustParsePrefix("2001:1::1/128"), "port control protocol anycast (RFC 7723)", true},
+        {netip.MustParsePrefix("2001:1::2/128"), "TURN anycast (RFC 8155)", true},
+        {netip.MustParsePrefix("2001:1::3/128"), "DNS-SD service registration protocol anycast (RFC 9665)", true},
+        {netip.MustParsePrefix("2001:2::/48"), "benchmarking (RFC 5180)", false},
+        {netip.MustParsePrefix("2001:3::/32"), "AMT (RFC 7450)", true},
+        {netip.MustParsePrefix("2001:4:112::/48"), "AS112-v6 (RFC 7535)", true},
+        {netip.MustParsePrefix("2001:10::/28"), "deprecated ORCHID (RFC 4843)", false},
+        {netip.MustParsePrefix("2001:20::/28"), "ORCHIDv2 (RFC 7343)", true},
+        {netip.MustParsePrefix("2001:30::/28"), "drone remote ID protocol entity tags (RFC 9374)", true},
+        {netip.MustParsePrefix("2001:db8::/32"), "documentation (RFC 3849)", false},
+        {netip.MustParsePrefix("2002::/16"), "6to4 (RFC 3056)", false},
+        {netip.MustParsePrefix("2620:4f:8000::/48"), "direct delegation AS112 service (RFC 7534)", true},
+        {netip.MustParsePrefix("3fff::/20"), "documentation (RFC 9637)", false},
+        {netip.MustParsePrefix("5f00::/16"), "segment routing SIDs (RFC 9602)", false},
+        {netip.MustParsePrefix("fc00::/7"), "unique local address (RFC 4193)", false},
+        {netip.MustParsePrefix("fe80::/10"), "link-local unicast (RFC 4291)", false},
+        {netip.MustParsePrefix("ff00::/8"), "multicast (RFC 4291)", false},
+}
+
+// cgnatPrefix 是运营商级 NAT 使用的共享地址段
+var cgnatPrefix = netip.MustParsePrefix("100.64.0.0/10")
+
+// toNetipAddr 将 net.IP 转换为 netip.Addr, IPv4 地址统一为 4 字节形式
+func toNetipAddr(ip net.IP) (netip.Addr, bool) {
+        if ip4 := ip.To4(); ip4 != nil {
+                ip = ip4
+        }
+        return netip.AddrFromSlice(ip)
+}
+
+// classifyIP 返回地址在特殊用途地址注册表中最长匹配的条目; 普通全球单播地址返回 nil
+func classifyIP(ip net.IP) *specialRange {
+        addr, ok := toNetipAddr(ip)
+        if !ok {
+                return nil
+        }
+        var best *specialRange
+        for i := range specialRanges {
+                r := &specialRanges[i]
+                if r.prefix.Contains(addr) && (best == nil || r.prefix.Bits() > best.prefix.Bits()) {
+                        best = r
+                }
+        }
+        return best
+}
+
+// unusableIPReason 返回地址不能发布为公网 DNS 记录的原因, 可用时返回空字符串
+func unusableIPReason(ipStr string) string {
+        ip := net.ParseIP(ipStr)
+        if ip == nil {
+                return "not a valid IP address"
+        }
+        if r := classifyIP(ip); r != nil && !r.reachable {
+                return r.name
+        }
+        return ""
+}
+
+// isCGNAT 判断地址是否位于 100.64.0.0/10 (CGNAT 共享地址段)
+func isCGNAT(ip net.IP) bool {
+        addr, ok := toNetipAddr(ip)
+        return ok && cgnatPrefix.Contains(addr)
+}
+
+// cgnatOnlyError 在接口上只有 CGNAT 地址时输出醒目的警告, 并返回提示改用外部 IP 来源的错误
+func cgnatOnlyError(iface string) error {
+        nowStr := time.Now().Format("2006-01-02 15:04:05")
+        log.Printf("[%s] ⚠️ ================================================================", nowStr)
+        log.Printf("[%s] ⚠️ Interface %s only has carrier-grade NAT (100.64.0.0/10) addresses.", nowStr, iface)
+        log.Printf("[%s] ⚠️ Your ISP is using CGNAT: this address is shared and not reachable from the Internet.", nowStr)
+        log.Printf("[%s] ⚠️ Configure an external 'ip_sources' entry (http, dns or stun) to publish the real public address,", nowStr)
+        log.Printf("[%s] ⚠️ and note that inbound connections will not work unless your ISP provides a public address.", nowStr)
+        log.Printf("[%s] ⚠️ ================================================================", nowStr)
+        return fmt.Errorf("interface %s only has CGNAT (100.64.0.0/10) addresses; use an external IP source", iface)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+        "net"
+        "os"
+        "path/filepath"
+        "runtime"
+        "strings"
+        "testing"
+)
+
+func TestUnusableIPReason(t *testing.T) {
+        tests := []struct {
+                ip   string
+                want string // 原因中应包含的文字, 空字符串表示可以发布
+        }{
+                {"8.8.8.8", ""},
+                {"100.64.0.1", "CGNAT"},
+                {"100.127.255.254", "CGNAT"},
+                {"100.128.0.1", ""},
+                {"10.1.2.3", "private-use"},
+                {"192.0.0.100", "IETF protocol assignments"},
+                {"192.0.0.1", "DS-Lite"}, // /29 inside 192.0.0.0/24
+                {"192.0.0.8", "dummy"},
+                {"192.0.0.9", ""},  // PCP anycast, globally reachable
+                {"192.0.0.10", ""}, // TURN anycast, globally reachable
+                {"192.0.0.171", "NAT64/DNS64"},
+                {"192.0.2.10", "TEST-NET-1"},
+                {"198.51.100.7", "TEST-NET-2"},
+                {"203.0.113.5", "TEST-NET-3"},
+                {"198.18.0.1", "benchmarking"},
+                {"198.19.255.254", "benchmarking"},
+                {"198.20.0.1", ""},
+                {"192.88.99.1", "6to4 relay"},
+                {"224.0.0.251", "multicast"},
+                {"255.255.255.255", "limited broadcast"},
+                {"2606:4700:4700::1111", ""},
+                {"2001:db8::1", "documentation"},
+                {"2002:c000:204::1", "6to4"},
+                {"2001:0:4136:e378::1", "Teredo"}, // /32 inside 2001::/23
+                {"2001:4::1", "IETF protocol assignments"},
+                {"2001:1::1", ""}, // PCP anycast, globally reachable
+                {"2001:2::1", "benchmarking"},
+                {"2001:3::1", ""}, // AMT, globally reachable
+                {"2001:10::1", "ORCHID"},
+                {"2001:20::1", ""},  // ORCHIDv2, globally reachable
+                {"2001:200::1", ""}, // outside 2001::/23
+                {"64:ff9b::808:808", ""},
+                {"64:ff9b:1::1", "local-use IPv4/IPv6 translation"},
+                {"fd00::1", "unique local"},
+                {"fe80::1", "link-local"},
+                {"::1", "loopback"},
+                {"::ffff:100.64.0.1", "CGNAT"}, // IPv4-mapped addresses are classified as IPv4
+                {"not-an-ip", "not a valid IP address"},
+        }
+        for _, tt := range tests {
+                got := unusableIPReason(tt.ip)
+                if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+                        t.Errorf("unusableIPReason(%s) = %q, want %q", tt.ip, got, tt.want)
+                }
+        }
+}
+
+func TestClassifyIPLongestPrefix(t *testing.T) {
+        for ip, want := range map[string]string{
+                "192.0.0.9":   "192.0.0.9/32",
+                "192.0.0.3":   "192.0.0.0/29",
+                "192.0.0.200": "192.0.0.0/24",
+                "2001:1::1":   "2001:1::1/128",
+                "2001::1":     "2001::/32",
+                "2001:5::1":   "2001::/23",
+        } {
+                if r := classifyIP(net.ParseIP(ip)); r == nil || r.prefix.String() != want {
+                        t.Errorf("classifyIP(%s) = %v, want %s", ip, r, want)
+                }
+        }
+        if r := classifyIP(net.ParseIP("1.1.1.1")); r != nil {
+                t.Errorf("classifyIP(1.1.1.1) = %v, want nil", r)
+        }
+        if !isCGNAT(net.ParseIP("100.100.100.100")) || isCGNAT(net.ParseIP("100.63.255.255")) {
+                t.Error("isCGNAT does not match 100.64.0.0/10")
+        }
+}
+
+// fakeIPCommand 在 PATH 最前面放一个输出固定内容的 'ip' 命令
+func fakeIPCommand(t *testing.T, output string) {
+        t.Helper()
+        if runtime.GOOS == "windows" {
+                t.Skip("needs a shell script")
+        }
+        dir := t.TempDir()
+        script := "#!/bin/sh\ncat <<'EOF'\n" + output + "EOF\n"
+        if err := os.WriteFile(filepath.Join(dir, "ip"), []byte(script), 0755); err != nil {
+                t.Fatal(err)
+        }
+        t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestGetInterfaceIPCGNATOnly(t *testing.T) {
+        fakeIPCommand(t, `3: ppp0: <POINTOPOINT,MULTICAST,NOARP,UP,LOWER_UP> mtu 1492 qdisc fq_codel state UNKNOWN group default qlen 3
+    inet 100.72.3.4 peer 10.64.64.64/32 scope global ppp0
+       valid_lft forever preferred_lft forever
+    inet 192.168.1.2/24 scope global ppp0
+       valid_lft forever preferred_lft forever
+`)
+        _, err := getInterfaceIP("ppp0", "ipv4", ipMethodCommand, nil)
+        if err == nil || !strings.Contains(err.Error(), "only has CGNAT") || !strings.Contains(err.Error(), "external IP source") {
+                t.Fatalf("error = %v, want the CGNAT-only error", err)
+        }
+
+        fakeIPCommand(t, `3: ppp0: <POINTOPOINT,MULTICAST,NOARP,UP,LOWER_UP> mtu 1492
+    inet 100.72.3.4 peer 10.64.64.64/32 scope global ppp0
+       valid_lft forever preferred_lft forever
+    inet 81.2.69.160 peer 10.64.64.64/32 scope global ppp0
+       valid_lft forever preferred_lft forever
+`)
+        if ip, err := getInterfaceIP("ppp0", "ipv4", ipMethodCommand, nil); err != nil || ip != "81.2.69.160" {
+                t.Fatalf("got %q, %v; want the public address next to the CGNAT one", ip, err)
+        }
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+        "context"
+        "encoding/json"
+        "errors"
+        "fmt"
+        "io"
+        "log"
+        "net"
+        "net/http"
+        "regexp"
+        "strings"
+        "time"
+)
+
+// defaultSourceTimeout 是未配置 timeout 时单个 IP 来源的超时时间
+const defaultSourceTimeout = 10 * time.Second
+
+// maxEchoBodySize 限制 HTTP 回显服务响应体的读取大小
+const maxEchoBodySize = 64 << 10
+
+// IPSourceConfig 描述 ip_sources 中的一个 IP 来源
+type IPSourceConfig struct {
+        Type string `json:"type"` // "interface", "http", "dns", "stun", "upnp", "natpmp", "pcp" 或 "static"
+        // type=interface
+        Interface string `json:"interface,omitempty"` // 网络接口名
+        IPMethod  string `json:"ip_method,omitempty"` // "native" (默认) 或 "command"
+        AddrPolicy
+        // type=http
+        URL     string `json:"url,omitempty"`     // 回显服务地址, 如 https://1.1.1.1/cdn-cgi/trace; type=upnp 时为设备描述 URL
+        Parse   string `json:"parse,omitempty"`   // "text" (默认), "trace", "json" 或 "regex"
+        Field   string `json:"field,omitempty"`   // parse=json 时的字段路径 (如 "ip" 或 "data.ip"); parse=trace 时的键 (默认 "ip")
+        Pattern string `json:"pattern,omitempty"` // parse=regex 时的正则表达式, 取第一个捕获组
+        // type=dns
+        Service  string `json:"service,omitempty"`  // 预置服务: "opendns", "cloudflare" 或 "google"
+        Resolver string `json:"resolver,omitempty"` // 解析器地址 (host:port), 覆盖预置值
+        Query    string `json:"query,omitempty"`    // 查询的域名, 覆盖预置值
+        QType    string `json:"qtype,omitempty"`    // "A"/"AAAA" (按 IP 版本) 或 "TXT"
+        QClass   string `json:"qclass,omitempty"`   // "IN" 或 "CH"
+        // type=stun
+        Servers []string `json:"servers,omitempty"` // STUN 服务器列表 (host:port), 按顺序尝试
+        // type=upnp / natpmp / pcp
+        Gateway string `json:"gateway,omitempty"` // 网关地址, 省略时使用默认路由的网关 (upnp 省略 url 时使用 SSDP 发现)
+        // type=static
+        Value string `json:"value,omitempty"` // 固定 IP 地址
+        // Timeout 为该来源的超时秒数 (默认 10)
+        Timeout int `json:"timeout,omitempty"`
+}
+
+// ipSource 是一个可以探测当前公网 IP 的来源
+type ipSource interface {
+        // Name 返回用于日志的来源描述
+        Name() string
+        // Detect 返回指定 IP 版本 ("ipv4" 或 "ipv6") 的地址
+        Detect(ctx context.Context, ipversion string) (string, error)
+}
+
+// newIPSource 根据配置创建 IP 来源
+func newIPSource(cfg IPSourceConfig) (ipSource, error) {
+        timeout := defaultSourceTimeout
+        if cfg.Timeout > 0 {
+                timeout = time.Duration(cfg.Timeout) * time.Second
+        }
+
+        switch cfg.Type {
+        case "interface":
+                if cfg.Interface == "" {
+                        return nil, errors.New("interface source is missing 'interface'")
+                }
+                if cfg.IPMethod != "" && cfg.IPMethod != ipMethodNative && cfg.IPMethod != ipMethodCommand {
+                        return nil, fmt.Errorf("interface source has invalid 'ip_method' ('%s')", cfg.IPMethod)
+                }
+                selector, err := newAddrSelector(cfg.AddrPolicy)
+                if err != nil {
+                        return nil, fmt.Errorf("interface source: %w", err)
+                }
+                return &interfaceSource{iface: cfg.Interface, method: cfg.IPMethod, selector: selector}, nil
+        case "http":
+                return newHTTPSource(cfg, timeout)
+        case "dns":
+                return newDNSSource(cfg, timeout)
+        case "stun":
+                return newSTUNSource(cfg, timeout)
+        case "upnp", "natpmp", "pcp":
+                return newGatewaySource(cfg, timeout)
+        case "static":
+                ip := net.ParseIP(strings.TrimSpace(cfg.Value))
+                if ip == nil {
+                        return nil, fmt.Errorf("static source has invalid 'value' ('%s')", cfg.Value)
+                }
+                return &staticSource{ip: ip}, nil
+        case "":
+                return nil, errors.New("ip source is missing 'type'")
+        }
+        return nil, fmt.Errorf("unknown ip source type '%s'", cfg.Type)
+}
+
+// buildIPSources 根据配置创建有序的 IP 来源列表
+// 未配置 ip_sources 时, 使用顶层 interface / ip_method 作为唯一来源 (兼容旧配置)
+func buildIPSources(config Config) ([]ipSource, error) {
+        cfgs := config.IPSources
+        if len(cfgs) == 0 {
+                cfgs = []IPSourceConfig{{Type: "interface", Interface: config.Interface, IPMethod: config.IPMethod, AddrPolicy: config.AddrPolicy}}
+        }
+        sources := make([]ipSource, 0, len(cfgs))
+        for i, cfg := range cfgs {
+                src, err := newIPSource(cfg)
+                if err != nil {
+                        return nil, fmt.Errorf("ip_sources[%d]: %w", i, err)
+                }
+                sources = append(sources, src)
+        }
+        return sources, nil
+}
+
+// detectIP 按顺序尝试各个 IP 来源, 返回第一个有效的结果
+func detectIP(ctx context.Context, sources []ipSource, ipversion string) (string, error) {
+        nowStr := time.Now().Format("2006-01-02 15:04:05")
+        var errs []error
+        for _, src := range sources {
+                log.Printf("[%s] ℹ️ Detecting %s address via %s", nowStr, ipversion, src.Name())
+                ip, err := detectFromSource(ctx, src, ipversion)
+                if err != nil {
+                        log.Printf("[%s] ⚠️ IP source %s failed: %v", nowStr, src.Name(), err)
+                        errs = append(errs, fmt.Errorf("%s: %w", src.Name(), err))
+                        continue
+                }
+                log.Printf("[%s] ✅ IP source %s returned %s", nowStr, src.Name(), ip)
+                return ip, nil
+        }
+        return "", fmt.Errorf("all %d IP sources failed: %w", len(sources), errors.Join(errs...))
+}
+
+// detectFromSource 查询单个来源并校验返回的地址
+func detectFromSource(ctx context.Context, src ipSource, ipversion string) (string, error) {
+        ipStr, err := src.Detect(ctx, ipversion)
+        if err != nil {
+                return "", err
+        }
+        _, isStatic := src.(*staticSource)
+        return validateDetectedIP(ipStr, ipversion, isStatic)
+}
+
+// validateDetectedIP 检查来源返回的地址是否可解析、地址族是否匹配、是否为公网地址
+// allowPrivate 为 true 时 (静态来源) 跳过公网检查
+func validateDetectedIP(ipStr, ipversion string, allowPrivate bool) (string, error) {
+        ip := net.ParseIP(strings.TrimSpace(ipStr))
+        if ip == nil {
+                return "", fmt.Errorf("'%s' is not a valid IP address", ipStr)
+        }
+        if !matchesIPVersion(ip, ipversion) {
+                return "", fmt.Errorf("address %s does not match IP version %s", ip, ipversion)
+        }
+        if reason := unusableIPReason(ip.String()); reason != "" && !allowPrivate {
+                return "", fmt.Errorf("address %s is not publicly routable: %s", ip, reason)
+        }
+        return ip.String(), nil
+}
+
+// --- Interface Source ---
+
+// interfaceSource 从本机网络接口获取地址
+type interfaceSource struct {
+        iface    string
+        method   string
+        selector *addrSelector
+}
+
+func (s *interfaceSource) Name() string {
+        return "interface " + s.iface
+}
+
+func (s *interfaceSource) Detect(ctx context.Context, ipversion string) (string, error) {
+        return getInterfaceIP(s.iface, ipversion, s.method, s.selector)
+}
+
+// --- Static Source ---
+
+// staticSource 返回配置中的固定地址
+type staticSource struct {
+        ip net.IP
+}
+
+func (s *staticSource) Name() string {
+        return "static " + s.ip.String()
+}
+
+func (s *staticSource) Detect(ctx context.Context, ipversion string) (string, error) {
+        return s.ip.String(), nil
+}
+
+// --- HTTP Echo Source ---
+
+// httpSource 通过 HTTP(S) "what is my IP" 回显服务获取地址
+type httpSource struct {
+        url     string
+        parse   string
+        field   string
+        pattern *regexp.Regexp
+        timeout time.Duration
+}
+
+// newHTTPSource 校验 http 来源的配置并创建来源
+func newHTTPSource(cfg IPSourceConfig, timeout time.Duration) (*httpSource, error) {
+        if cfg.URL == "" {
+                return nil, errors.New("http source is missing 'url'")
+        }
+        s := &httpSource{url: cfg.URL, parse: cfg.Parse, field: cfg.Field, timeout: timeout}
+        switch cfg.Parse {
+        case "", "text":
+                s.parse = "text"
+        case "trace":
+                if s.field == "" {
+                        s.field = "ip"
+                }
+        case "json":
+                if s.field == "" {
+                        return nil, errors.New("http source with parse 'json' is missing 'field'")
+                }
+        case "regex":
+                if cfg.Pattern == "" {
+                        return nil, errors.New("http source with parse 'regex' is missing 'pattern'")
+                }
+                re, err := regexp.Compile(cfg.Pattern)
+                if err != nil {
+                        return nil, fmt.Errorf("http source has invalid 'pattern': %w", err)
+                }
+                s.pattern = re
+        default:
+                return nil, fmt.Errorf("http source has invalid 'parse' ('%s'), must be 'text', 'trace', 'json' or 'regex'", cfg.Parse)
+        }
+        return s, nil
+}
+
+func (s *httpSource) Name() string {
+        return "http " + s.url
+}
+
+func (s *httpSource) Detect(ctx context.Context, ipversion string) (string, error) {
+        ctx, cancel := context.WithTimeout(ctx, s.timeout)
+        defer cancel()
+
+        req, err := http.NewRequestWithContext(ctx, "GET", s.url, nil)
+        if err != nil {
+                return "", fmt.Errorf("creating request failed: %w", err)
+        }
+        req.Header.Set("User-Agent", "cloudflare-ddns")
+
+        client := &http.Client{Transport: familyTransport(ipversion)}
+        resp, err := client.Do(req)
+        if err != nil {
+                return "", fmt.Errorf("request failed: %w", err)
+        }
+        defer resp.Body.Close()
+
+        body, err := io.ReadAll(io.LimitReader(resp.Body, maxEchoBodySize))
+        if err != nil {
+                return "", fmt.Errorf("reading response body failed (status: %s): %w", resp.Status, err)
+        }
+        if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+                return "", fmt.Errorf("unexpected status %s", resp.Status)
+        }
+        return s.extract(body)
+}
+
+// extract 按照配置的解析规则从响应体中提取 IP 字符串
+func (s *httpSource) extract(body []byte) (string, error) {
+        switch s.parse {
+        case "trace":
+                // Cloudflare /cdn-cgi/trace 格式: 每行一个 key=value
+                for _, line := range strings.Split(string(body), "\n") {
+                        key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+                        if ok && key == s.field {
+                                return value, nil
+                        }
+                }
+                return "", fmt.Errorf("key '%s' not found in trace response", s.field)
+        case "json":
+                var doc interface{}
+                if err := json.Unmarshal(body, &doc); err != nil {
+                        return "", fmt.Errorf("parsing JSON response failed: %w", err)
+                }
+                for _, key := range strings.Split(s.field, ".") {
+                        obj, ok := doc.(map[string]interface{})
+                        if !ok {
+                                return "", fmt.Errorf("field '%s' not found in JSON response", s.field)
+                        }
+                        doc = obj[key]
+                }
+                value, ok := doc.(string)
+                if !ok {
+                        return "", fmt.Errorf("field '%s' in JSON response is not a string", s.field)
+                }
+                return value, nil
+        case "regex":
+                match := s.pattern.FindSubmatch(body)
+                if match == nil {
+                        return "", fmt.Errorf("pattern '%s' did not match response", s.pattern)
+                }
+                if len(match) > 1 {
+                        return string(match[1]), nil
+                }
+                return string(match[0]), nil
+        }
+        return strings.TrimSpace(string(body)), nil
+}
+
+// familyTransport 返回只通过指定地址族建立连接的 HTTP Transport,
+// 保证回显服务看到的是我们要检测的那个地址族的出口地址
+func familyTransport(ipversion string) *http.Transport {
+        network := "tcp4"
+        if ipversion == "ipv6" {
+                network = "tcp6"
+        }
+        dialer := &net.Dialer{Timeout: 10 * time.Second}
+        transport := http.DefaultTransport.(*http.Transport).Clone()
+        transport.DisableKeepAlives = true
+        transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+                return dialer.DialContext(ctx, network, addr)
+        }
+        return transport
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+        "context"
+        "errors"
+        "fmt"
+        "net"
+        "strings"
+        "time"
+)
+
+// dnsService 是一个预置的 "whoami" DNS 服务
+type dnsService struct {
+        query      string
+        qclass     uint16
+        qtypeV4    uint16 // 通过 IPv4 查询时使用的记录类型
+        qtypeV6    uint16 // 通过 IPv6 查询时使用的记录类型
+        resolverV4 string
+        resolverV6 string
+}
+
+// dnsServices 是 "service" 字段可选的预置服务
+var dnsServices = map[string]dnsService{
+        // myip.opendns.com 只能在 OpenDNS 自己的解析器上查询
+        "opendns": {
+                query: "myip.opendns.com", qclass: dnsClassIN,
+                qtypeV4: dnsTypeA, qtypeV6: dnsTypeAAAA,
+                resolverV4: "208.67.222.222:53", resolverV6: "[2620:119:35::35]:53",
+        },
+        // whoami.cloudflare 是 CHAOS 类的 TXT 记录, 内容为查询的源地址
+        "cloudflare": {
+                query: "whoami.cloudflare", qclass: dnsClassCH,
+                qtypeV4: dnsTypeTXT, qtypeV6: dnsTypeTXT,
+                resolverV4: "1.1.1.1:53", resolverV6: "[2606:4700:4700::1111]:53",
+        },
+        // o-o.myaddr.l.google.com 需要直接查询 Google 的权威服务器
+        "google": {
+                query: "o-o.myaddr.l.google.com", qclass: dnsClassIN,
+                qtypeV4: dnsTypeTXT, qtypeV6: dnsTypeTXT,
+                resolverV4: "216.239.32.10:53", resolverV6: "[2001:4860:4802:32::a]:53",
+        },
+}
+
+// dnsSource 通过向解析器查询 "我的地址" 记录来获取公网地址
+type dnsSource struct {
+        service dnsService
+        timeout time.Duration
+}
+
+// newDNSSource 校验 dns 来源的配置并创建来源
+// 可以指定 service 使用预置服务, 也可以完全自定义 resolver / query / qtype / qclass
+func newDNSSource(cfg IPSourceConfig, timeout time.Duration) (*dnsSource, error) {
+        var svc dnsService
+        if cfg.Service != "" {
+                preset, ok := dnsServices[cfg.Service]
+                if !ok {
+                        return nil, fmt.Errorf("dns source has unknown 'service' ('%s'), must be 'opendns', 'cloudflare' or 'google'", cfg.Service)
+                }
+                svc = preset
+        } else {
+                svc = dnsService{qclass: dnsClassIN, qtypeV4: dnsTypeA, qtypeV6: dnsTypeAAAA}
+        }
+
+        if cfg.Query != "" {
+                svc.query = cfg.Query
+        }
+        if cfg.Resolver != "" {
+                resolver := cfg.Resolver
+                if _, _, err := net.SplitHostPort(resolver); err != nil {
+                        resolver = net.JoinHostPort(strings.Trim(resolver, "[]"), "53")
+                }
+                svc.resolverV4, svc.resolverV6 = resolver, resolver
+        }
+        switch strings.ToUpper(cfg.QType) {
+        case "":
+        case "A", "AAAA":
+                // 按 IP 版本自动选择 A 或 AAAA
+                svc.qtypeV4, svc.qtypeV6 = dnsTypeA, dnsTypeAAAA
+        case "TXT":
+                svc.qtypeV4, svc.qtypeV6 = dnsTypeTXT, dnsTypeTXT
+        default:
+                return nil, fmt.Errorf("dns source has invalid 'qtype' ('%s'), must be 'A', 'AAAA' or 'TXT'", cfg.QType)
+        }
+        switch strings.ToUpper(cfg.QClass) {
+        case "":
+        case "IN":
+                svc.qclass = dnsClassIN
+        case "CH":
+                svc.qclass = dnsClassCH
+        default:
+                return nil, fmt.Errorf("dns source has invalid 'qclass' ('%s'), must be 'IN' or 'CH'", cfg.QClass)
+        }
+
+        if svc.query == "" {
+                return nil, errors.New("dns source is missing 'query' (or 'service')")
+        }
+        if svc.resolverV4 == "" {
+                return nil, errors.New("dns source is missing 'resolver' (or 'service')")
+        }
+        return &dnsSource{service: svc, timeout: timeout}, nil
+}
+
+func (s *dnsSource) Name() string {
+        return fmt.Sprintf("dns %s@%s", s.service.query, s.service.resolverV4)
+}
+
+func (s *dnsSource) Detect(ctx context.Context, ipversion string) (string, error) {
+        ctx, cancel := context.WithTimeout(ctx, s.timeout)
+        defer cancel()
+
+        // 通过对应地址族发送查询, 解析器看到的源地址才是我们要的那个地址族
+        network, resolver, qtype := "udp4", s.service.resolverV4, s.service.qtypeV4
+        if ipversion == "ipv6" {
+                network, resolver, qtype = "udp6", s.service.resolverV6, s.service.qtypeV6
+        }
+
+        query := newDNSQuery(s.service.query, qtype, s.service.qclass)
+        packed, err := query.Pack()
+        if err != nil {
+                return "", err
+        }
+        raw, err := dnsExchange(ctx, network, resolver, packed)
+        if err != nil {
+                return "", err
+        }
+        resp, err := parseDNSMessage(raw)
+        if err != nil {
+                return "", fmt.Errorf("parsing DNS response failed: %w", err)
+        }
+        if resp.Flags&dnsFlagQR == 0 {
+                return "", errors.New("DNS response is not a reply")
+        }
+        if resp.Rcode() != 0 {
+                return "", fmt.Errorf("DNS query for %s failed with %s", s.service.query, rcodeName(resp.Rcode()))
+        }
+        return extractAnswerIP(resp.Answers, qtype)
+}
+
+// extractAnswerIP 从应答段中提取第一个符合类型的地址
+func extractAnswerIP(answers []dnsRR, qtype uint16) (string, error) {
+        for _, rr := range answers {
+                if rr.Type != qtype {
+                        continue
+                }
+                switch rr.Type {
+                case dnsTypeA, dnsTypeAAAA:
+                        if len(rr.Data) == net.IPv4len || len(rr.Data) == net.IPv6len {
+                                return net.IP(rr.Data).String(), nil
+                        }
+                case dnsTypeTXT:
+                        for _, txt := range parseTXT(rr.Data) {
+                                if ip := net.ParseIP(strings.TrimSpace(txt)); ip != nil {
+                                        return ip.String(), nil
+                                }
+                        }
+                }
+        }
+        return "", errors.New("DNS response contains no address answer")
+}
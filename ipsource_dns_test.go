@@ -0,0 +1,241 @@
+package main
+
+import (
+        "context"
+        "encoding/binary"
+        "io"
+        "net"
+        "strings"
+        "sync"
+        "sync/atomic"
+        "testing"
+        "time"
+)
+
+// dnsStub 是测试用的本地 DNS 服务器, 在同一端口上监听 UDP 和 TCP, 由 handler 构造每个查询的响应
+type dnsStub struct {
+        addr    string
+        handler func(q *dnsMessage) *dnsMessage
+        // truncate 为 true 时 UDP 只返回带 TC 标志的空响应, 迫使客户端改用 TCP
+        truncate atomic.Bool
+
+        mu      sync.Mutex
+        queries []string // "udp"/"tcp" + 问题, 便于检查
+}
+
+// startDNSStub 在 network ("udp4" 或 "udp6") 的回环地址上启动 DNS 服务器
+func startDNSStub(t *testing.T, network string, handler func(q *dnsMessage) *dnsMessage) *dnsStub {
+        t.Helper()
+        host := "127.0.0.1"
+        if network == "udp6" {
+                host = "::1"
+        }
+        pc, err := net.ListenPacket(network, net.JoinHostPort(host, "0"))
+        if err != nil {
+                t.Skipf("cannot listen on %s: %v", network, err)
+        }
+        tcpNetwork := "tcp" + strings.TrimPrefix(network, "udp")
+        ln, err := net.Listen(tcpNetwork, pc.LocalAddr().String())
+        if err != nil {
+                pc.Close()
+                t.Skipf("cannot listen on %s: %v", tcpNetwork, err)
+        }
+        s := &dnsStub{addr: pc.LocalAddr().String(), handler: handler}
+        t.Cleanup(func() {
+                pc.Close()
+                ln.Close()
+        })
+
+        go func() {
+                buf := make([]byte, 65535)
+                for {
+                        n, from, err := pc.ReadFrom(buf)
+                        if err != nil {
+                                return
+                        }
+                        if out := s.respond("udp", buf[:n]); out != nil {
+                                pc.WriteTo(out, from)
+                        }
+                }
+        }()
+        go func() {
+                for {
+                        conn, err := ln.Accept()
+                        if err != nil {
+                                return
+                        }
+                        go func() {
+                                defer conn.Close()
+                                conn.SetDeadline(time.Now().Add(5 * time.Second))
+                                var lenBuf [2]byte
+                                if _, err := io.ReadFull(conn, lenBuf[:]); err != nil {
+                                        return
+                                }
+                                msg := make([]byte, binary.BigEndian.Uint16(lenBuf[:]))
+                                if _, err := io.ReadFull(conn, msg); err != nil {
+                                        return
+                                }
+                                if out := s.respond("tcp", msg); out != nil {
+                                        conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(out))), out...))
+                                }
+                        }()
+                }
+        }()
+        return s
+}
+
+// respond 解析查询并返回编码后的响应; handler 返回 nil 时不回复
+func (s *dnsStub) respond(transport string, msg []byte) []byte {
+        q, err := parseDNSMessage(msg)
+        if err != nil || len(q.Questions) == 0 {
+                return nil
+        }
+        s.mu.Lock()
+        s.queries = append(s.queries, transport+" "+q.Questions[0].Name)
+        s.mu.Unlock()
+
+        var resp *dnsMessage
+        if transport == "udp" && s.truncate.Load() {
+                resp = &dnsMessage{Flags: dnsFlagQR | dnsFlagTC, Questions: q.Questions}
+        } else if resp = s.handler(q); resp == nil {
+                return nil
+        }
+        resp.ID = q.ID
+        resp.Flags |= dnsFlagQR
+        out, err := resp.Pack()
+        if err != nil {
+                return nil
+        }
+        return out
+}
+
+// dnsAnswer 返回对查询 q 的一条应答
+func dnsAnswer(q *dnsMessage, rrType uint16, data []byte) *dnsMessage {
+        question := q.Questions[0]
+        return &dnsMessage{
+                Questions: q.Questions,
+                Answers:   []dnsRR{{Name: question.Name, Type: rrType, Class: question.Class, TTL: 0, Data: data}},
+        }
+}
+
+// txtData 把字符串编码为 TXT 记录的 RDATA
+func txtData(s string) []byte {
+        return append([]byte{byte(len(s))}, s...)
+}
+
+func TestDNSSourceOpenDNS(t *testing.T) {
+        stub := startDNSStub(t, "udp4", func(q *dnsMessage) *dnsMessage {
+                question := q.Questions[0]
+                if question.Name != "myip.opendns.com." || question.Type != dnsTypeA || question.Class != dnsClassIN {
+                        return &dnsMessage{Flags: 3, Questions: q.Questions} // NXDOMAIN
+                }
+                return dnsAnswer(q, dnsTypeA, net.ParseIP("8.8.4.4").To4())
+        })
+        src, err := newIPSource(IPSourceConfig{Type: "dns", Service: "opendns", Resolver: stub.addr})
+        if err != nil {
+                t.Fatal(err)
+        }
+        got, err := detectFromSource(context.Background(), src, "ipv4")
+        if err != nil || got != "8.8.4.4" {
+                t.Fatalf("got %q, %v; want 8.8.4.4", got, err)
+        }
+}
+
+func TestDNSSourceCloudflareChaosTXT(t *testing.T) {
+        stub := startDNSStub(t, "udp4", func(q *dnsMessage) *dnsMessage {
+                question := q.Questions[0]
+                if question.Name != "whoami.cloudflare." || question.Type != dnsTypeTXT || question.Class != dnsClassCH {
+                        return &dnsMessage{Flags: 5, Questions: q.Questions} // REFUSED
+                }
+                return dnsAnswer(q, dnsTypeTXT, txtData("1.0.0.1"))
+        })
+        src, err := newIPSource(IPSourceConfig{Type: "dns", Service: "cloudflare", Resolver: stub.addr})
+        if err != nil {
+                t.Fatal(err)
+        }
+        got, err := detectFromSource(context.Background(), src, "ipv4")
+        if err != nil || got != "1.0.0.1" {
+                t.Fatalf("got %q, %v; want 1.0.0.1", got, err)
+        }
+}
+
+func TestDNSSourceAAAA(t *testing.T) {
+        stub := startDNSStub(t, "udp6", func(q *dnsMessage) *dnsMessage {
+                if q.Questions[0].Type != dnsTypeAAAA {
+                        return &dnsMessage{Flags: 3, Questions: q.Questions}
+                }
+                return dnsAnswer(q, dnsTypeAAAA, net.ParseIP("2606:4700:4700::1111"))
+        })
+        src, err := newIPSource(IPSourceConfig{Type: "dns", Service: "opendns", Resolver: stub.addr})
+        if err != nil {
+                t.Fatal(err)
+        }
+        got, err := detectFromSource(context.Background(), src, "ipv6")
+        if err != nil || got != "2606:4700:4700::1111" {
+                t.Fatalf("got %q, %v", got, err)
+        }
+}
+
+func TestDNSSourceTruncatedRetriesOverTCP(t *testing.T) {
+        stub := startDNSStub(t, "udp4", func(q *dnsMessage) *dnsMessage {
+                return dnsAnswer(q, dnsTypeA, net.ParseIP("9.9.9.9").To4())
+        })
+        stub.truncate.Store(true)
+        src, err := newIPSource(IPSourceConfig{Type: "dns", Query: "me.example", Resolver: stub.addr})
+        if err != nil {
+                t.Fatal(err)
+        }
+        got, err := detectFromSource(context.Background(), src, "ipv4")
+        if err != nil || got != "9.9.9.9" {
+                t.Fatalf("got %q, %v; want 9.9.9.9", got, err)
+        }
+        stub.mu.Lock()
+        defer stub.mu.Unlock()
+        if want := []string{"udp me.example.", "tcp me.example."}; strings.Join(stub.queries, ",") != strings.Join(want, ",") {
+                t.Fatalf("queries = %v, want %v", stub.queries, want)
+        }
+}
+
+func TestDNSSourceErrors(t *testing.T) {
+        tests := []struct {
+                name    string
+                handler func(q *dnsMessage) *dnsMessage
+                wantErr string
+        }{
+                {"nxdomain", func(q *dnsMessage) *dnsMessage { return &dnsMessage{Flags: 3, Questions: q.Questions} }, "NXDOMAIN"},
+                {"no answer", func(q *dnsMessage) *dnsMessage { return &dnsMessage{Questions: q.Questions} }, "no address answer"},
+                {"private answer", func(q *dnsMessage) *dnsMessage { return dnsAnswer(q, dnsTypeA, net.ParseIP("192.168.1.2").To4()) }, "not publicly routable"},
+                {"no reply", func(q *dnsMessage) *dnsMessage { return nil }, "reading DNS response"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        stub := startDNSStub(t, "udp4", tt.handler)
+                        src, err := newDNSSource(IPSourceConfig{Type: "dns", Query: "me.example", Resolver: stub.addr}, 500*time.Millisecond)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        _, err = detectFromSource(context.Background(), src, "ipv4")
+                        if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                                t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+                        }
+                })
+        }
+}
+
+func TestNewDNSSourceValidation(t *testing.T) {
+        for _, cfg := range []IPSourceConfig{
+                {Service: "nope"},
+                {Query: "me.example"},
+                {Resolver: "127.0.0.1"},
+                {Service: "opendns", QType: "MX"},
+                {Service: "opendns", QClass: "HS"},
+        } {
+                if _, err := newDNSSource(cfg, time.Second); err == nil {
+                        t.Errorf("newDNSSource(%+v) should fail", cfg)
+                }
+        }
+        src, err := newDNSSource(IPSourceConfig{Service: "google", Resolver: "127.0.0.1"}, time.Second)
+        if err != nil || src.service.resolverV4 != "127.0.0.1:53" || src.service.qtypeV4 != dnsTypeTXT {
+                t.Fatalf("got %+v, %v", src, err)
+        }
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+        "bufio"
+        "bytes"
+        "context"
+        "crypto/rand"
+        "encoding/binary"
+        "encoding/xml"
+        "errors"
+        "fmt"
+        "io"
+        "net"
+        "net/http"
+        "net/url"
+        "strings"
+        "time"
+)
+
+// 网关协议常量
+const (
+        ssdpAddr         = "239.255.255.250:1900"
+        ssdpWait         = 2 * time.Second
+        natpmpPort       = "5351" // NAT-PMP 与 PCP 共用同一端口
+        natpmpInitialRTO = 250 * time.Millisecond
+        pcpVersion       = 2
+        pcpOpcodeMap     = 1
+        pcpMapLifetime   = 30 // 用于探测的临时映射的生命周期 (秒)
+        maxSOAPBodySize  = 64 << 10
+)
+
+// ssdpSearchTargets 是 M-SEARCH 使用的搜索目标
+var ssdpSearchTargets = []string{
+        "urn:schemas-upnp-org:device:InternetGatewayDevice:1",
+        "urn:schemas-upnp-org:device:InternetGatewayDevice:2",
+}
+
+// natpmpResultNames 是 NAT-PMP 响应结果码的含义 (RFC 6886)
+var natpmpResultNames = map[uint16]string{
+        1: "unsupported version",
+        2: "not authorized/refused",
+        3: "network failure",
+        4: "out of resources",
+        5: "unsupported opcode",
+}
+
+// pcpResultNames 是 PCP 响应结果码的含义 (RFC 6887)
+var pcpResultNames = map[byte]string{
+        1:  "UNSUPP_VERSION",
+        2:  "NOT_AUTHORIZED",
+        3:  "MALFORMED_REQUEST",
+        4:  "UNSUPP_OPCODE",
+        5:  "UNSUPP_OPTION",
+        6:  "MALFORMED_OPTION",
+        7:  "NETWORK_FAILURE",
+        8:  "NO_RESOURCES",
+        9:  "UNSUPP_PROTOCOL",
+        10: "USER_EX_QUOTA",
+        11: "CANNOT_PROVIDE_EXTERNAL",
+        12: "ADDRESS_MISMATCH",
+        13: "EXCESSIVE_REMOTE_PEERS",
+}
+
+// gatewaySource 向本地网关 (路由器) 查询其 WAN 地址
+type gatewaySource struct {
+        protocol string // "upnp", "natpmp" 或 "pcp"
+        gateway  string // 网关地址; 为空时使用默认路由的网关
+        location string // upnp: 设备描述 URL; 为空时通过 SSDP 发现
+        timeout  time.Duration
+}
+
+// newGatewaySource 创建网关来源
+func newGatewaySource(cfg IPSourceConfig, timeout time.Duration) (*gatewaySource, error) {
+        if cfg.URL != "" && cfg.Type != "upnp" {
+                return nil, fmt.Errorf("%s source does not support 'url'", cfg.Type)
+        }
+        return &gatewaySource{protocol: cfg.Type, gateway: cfg.Gateway, location: cfg.URL, timeout: timeout}, nil
+}
+
+func (s *gatewaySource) Name() string {
+        target := s.gateway
+        if s.location != "" {
+                target = s.location
+        }
+        if target == "" {
+                target = "default gateway"
+        }
+        return fmt.Sprintf("%s %s", s.protocol, target)
+}
+
+// Detect 通过对应协议查询网关的外部地址 (这些协议只提供 IPv4 WAN 地址)
+func (s *gatewaySource) Detect(ctx context.Context, ipversion string) (string, error) {
+        if ipversion != "ipv4" {
+                return "", fmt.Errorf("%s only reports IPv4 WAN addresses", s.protocol)
+        }
+        ctx, cancel := context.WithTimeout(ctx, s.timeout)
+        defer cancel()
+
+        var ip net.IP
+        var err error
+        switch s.protocol {
+        case "upnp":
+                ip, err = s.detectUPnP(ctx)
+        case "natpmp":
+                ip, err = s.detectNATPMP(ctx)
+        case "pcp":
+                ip, err = s.detectPCP(ctx)
+        default:
+                err = fmt.Errorf("unknown gateway protocol '%s'", s.protocol)
+        }
+        if err != nil {
+                return "", err
+        }
+        return ip.String(), nil
+}
+
+// gatewayAddr 返回网关的 host:port, 未配置时使用默认路由的网关
+func (s *gatewaySource) gatewayAddr(defaultPort string) (string, error) {
+        if s.gateway != "" {
+                if _, _, err := net.SplitHostPort(s.gateway); err == nil {
+                        return s.gateway, nil
+                }
+                return net.JoinHostPort(strings.Trim(s.gateway, "[]"), defaultPort), nil
+        }
+        gw, err := defaultGateway()
+        if err != nil {
+                return "", fmt.Errorf("determining default gateway failed (set 'gateway' explicitly): %w", err)
+        }
+        return net.JoinHostPort(gw.String(), defaultPort), nil
+}
+
+// --- UPnP IGD ---
+
+// detectUPnP 通过 SSDP 发现 IGD, 再调用 WANIPConnection/WANPPPConnection 的 GetExternalIPAddress
+func (s *gatewaySource) detectUPnP(ctx context.Context) (net.IP, error) {
+        locations := []string{s.location}
+        if s.location == "" {
+                var err error
+                if locations, err = ssdpDiscover(ctx); err != nil {
+                        return nil, err
+                }
+        }
+
+        var errs []error
+        for _, location := range locations {
+                ip, err := upnpExternalIP(ctx, location)
+                if err == nil {
+                        return ip, nil
+                }
+                errs = append(errs, fmt.Errorf("%s: %w", location, err))
+        }
+        return nil, errors.Join(errs...)
+}
+
+// ssdpDiscover 发送 M-SEARCH 并收集网关设备描述的 LOCATION
+func ssdpDiscover(ctx context.Context) ([]string, error) {
+        conn, err := net.ListenPacket("udp4", ":0")
+        if err != nil {
+                return nil, fmt.Errorf("opening SSDP socket failed: %w", err)
+        }
+        defer conn.Close()
+
+        dst, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+        if err != nil {
+                return nil, err
+        }
+        for _, st := range ssdpSearchTargets {
+                msg := "M-SEARCH * HTTP/1.1\r\n" +
+                        "HOST: " + ssdpAddr + "\r\n" +
+                        "MAN: \"ssdp:discover\"\r\n" +
+                        "MX: 2\r\n" +
+                        "ST: " + st + "\r\n\r\n"
+                if _, err := conn.WriteTo([]byte(msg), dst); err != nil {
+                        return nil, fmt.Errorf("sending SSDP M-SEARCH failed: %w", err)
+                }
+        }
+
+        deadline := time.Now().Add(ssdpWait)
+        if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+                deadline = ctxDeadline
+        }
+        conn.SetReadDeadline(deadline)
+
+        seen := make(map[string]bool)
+        var locations []string
+        buf := make([]byte, 2048)
+        for {
+                n, _, err := conn.ReadFrom(buf)
+                if err != nil {
+                        break // 超时, 结束收集
+                }
+                resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+                if err != nil {
+                        continue
+                }
+                resp.Body.Close()
+                if location := resp.Header.Get("Location"); location != "" && !seen[location] {
+                        seen[location] = true
+                        locations = append(locations, location)
+                }
+        }
+        if len(locations) == 0 {
+                return nil, errors.New("no UPnP Internet Gateway Device responded to SSDP discovery")
+        }
+        return locations, nil
+}
+
+// upnpDevice 是 UPnP 设备描述 XML 中与我们相关的部分
+type upnpDevice struct {
+        Services []struct {
+                ServiceType string `xml:"serviceType"`
+                ControlURL  string `xml:"controlURL"`
+        } `xml:"serviceList>service"`
+        Devices []upnpDevice `xml:"deviceList>device"`
+}
+
+// findWANService 在设备树中查找 WANIPConnection 或 WANPPPConnection 服务
+func (d *upnpDevice) findWANService() (serviceType, controlURL string, ok bool) {
+        for _, svc := range d.Services {
+                if strings.Contains(svc.ServiceType, ":WANIPConnection:") || strings.Contains(svc.ServiceType, ":WANPPPConnection:") {
+                        return svc.ServiceType, svc.ControlURL, true
+                }
+        }
+        for i := range d.Devices {
+                if serviceType, controlURL, ok = d.Devices[i].findWANService(); ok {
+                        return
+                }
+        }
+        return "", "", false
+}
+
+// upnpExternalIP 读取设备描述并通过 SOAP 调用 GetExternalIPAddress
+func upnpExternalIP(ctx context.Context, location string) (net.IP, error) {
+        body, err := upnpHTTP(ctx, "GET", location, "", nil)
+        if err != nil {
+                return nil, fmt.Errorf("fetching device description failed: %w", err)
+        }
+        var desc struct {
+                URLBase string     `xml:"URLBase"`
+                Device  upnpDevice `xml:"device"`
+        }
+        if err := xml.Unmarshal(body, &desc); err != nil {
+                return nil, fmt.Errorf("parsing device description failed: %w", err)
+        }
+        serviceType, controlURL, ok := desc.Device.findWANService()
+        if !ok {
+                return nil, errors.New("device has no WANIPConnection or WANPPPConnection service")
+        }
+
+        base := location
+        if desc.URLBase != "" {
+                base = desc.URLBase
+        }
+        baseURL, err := url.Parse(base)
+        if err != nil {
+                return nil, fmt.Errorf("invalid base URL '%s': %w", base, err)
+        }
+        ctrl, err := baseURL.Parse(controlURL)
+        if err != nil {
+                return nil, fmt.Errorf("invalid control URL '%s': %w", controlURL, err)
+        }
+
+        envelope := `<?xml version="1.0"?>` +
+                `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+                `<s:Body><u:GetExternalIPAddress xmlns:u="` + serviceType + `"/></s:Body></s:Envelope>`
+        body, err = upnpHTTP(ctx, "POST", ctrl.String(), `"`+serviceType+`#GetExternalIPAddress"`, []byte(envelope))
+        if err != nil {
+                return nil, fmt.Errorf("GetExternalIPAddress failed: %w", err)
+        }
+
+        value, err := xmlElementText(body, "NewExternalIPAddress")
+        if err != nil {
+                return nil, err
+        }
+        ip := net.ParseIP(value)
+        if ip == nil {
+                return nil, fmt.Errorf("gateway returned invalid external address '%s'", value)
+        }
+        return ip, nil
+}
+
+// upnpHTTP 发送 UPnP 描述/控制请求并返回响应体
+func upnpHTTP(ctx context.Context, method, urlStr, soapAction string, payload []byte) ([]byte, error) {
+        req, err := http.NewRequestWithContext(ctx, method, urlStr, bytes.NewReader(payload))
+        if err != nil {
+                return nil, err
+        }
+        if soapAction != "" {
+                req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+                req.Header.Set("SOAPAction", soapAction)
+        }
+        resp, err := http.DefaultClient.Do(req)
+        if err != nil {
+                return nil, err
+        }
+        defer resp.Body.Close()
+        body, err := io.ReadAll(io.LimitReader(resp.Body, maxSOAPBodySize))
+        if err != nil {
+                return nil, err
+        }
+        if resp.StatusCode != http.StatusOK {
+                detail, _ := xmlElementText(body, "errorDescription")
+                return nil, fmt.Errorf("unexpected status %s %s", resp.Status, detail)
+        }
+        return body, nil
+}
+
+// xmlElementText 返回 XML 文档中第一个指定本地名元素的文本内容
+func xmlElementText(doc []byte, name string) (string, error) {
+        dec := xml.NewDecoder(bytes.NewReader(doc))
+        for {
+                tok, err := dec.Token()
+                if err != nil {
+                        return "", fmt.Errorf("element <%s> not found in response", name)
+                }
+                if start, ok := tok.(xml.StartElement); ok && start.Name.Local == name {
+                        var text string
+                        if err := dec.DecodeElement(&text, &start); err != nil {
+                                return "", err
+                        }
+                        return strings.TrimSpace(text), nil
+                }
+        }
+}
+
+// --- NAT-PMP ---
+
+// detectNATPMP 发送 NAT-PMP (RFC 6886) 外部地址请求
+func (s *gatewaySource) detectNATPMP(ctx context.Context) (net.IP, error) {
+        addr, err := s.gatewayAddr(natpmpPort)
+        if err != nil {
+                return nil, err
+        }
+        resp, err := gatewayUDPExchange(ctx, addr, []byte{0, 0}, func(resp []byte) bool {
+                return len(resp) >= 2 && resp[0] == 0 && resp[1] == 128
+        })
+        if err != nil {
+                return nil, err
+        }
+        if len(resp) < 12 {
+                return nil, errors.New("NAT-PMP response too short")
+        }
+        if result := binary.BigEndian.Uint16(resp[2:]); result != 0 {
+                return nil, fmt.Errorf("NAT-PMP error %d (%s)", result, natpmpResultNames[result])
+        }
+        return net.IP(append([]byte(nil), resp[8:12]...)), nil
+}
+
+// --- PCP ---
+
+// detectPCP 通过 PCP (RFC 6887) MAP 请求获取外部地址
+// PCP 没有单独的 "查询外部地址" 操作, 这里创建一个短期 UDP 映射, 读取分配的外部地址后立即删除
+func (s *gatewaySource) detectPCP(ctx context.Context) (net.IP, error) {
+        addr, err := s.gatewayAddr(natpmpPort)
+        if err != nil {
+                return nil, err
+        }
+
+        var d net.Dialer
+        conn, err := d.DialContext(ctx, "udp4", addr)
+        if err != nil {
+                return nil, fmt.Errorf("dialing gateway %s failed: %w", addr, err)
+        }
+        local := conn.LocalAddr().(*net.UDPAddr)
+        conn.Close()
+
+        var nonce [12]byte
+        if _, err := rand.Read(nonce[:]); err != nil {
+                return nil, fmt.Errorf("generating nonce failed: %w", err)
+        }
+        matches := func(resp []byte) bool {
+                if len(resp) < 24 || resp[0] != pcpVersion || resp[1] != 0x80|pcpOpcodeMap {
+                        return false
+                }
+                return len(resp) < 36 || bytes.Equal(resp[24:36], nonce[:])
+        }
+
+        req := pcpMapRequest(local.IP, uint16(local.Port), nonce, pcpMapLifetime)
+        resp, err := gatewayUDPExchange(ctx, addr, req, matches)
+        if err != nil {
+                return nil, err
+        }
+        if resp[0] == 0 {
+                return nil, errors.New("gateway only speaks NAT-PMP, use the natpmp source")
+        }
+        if result := resp[3]; result != 0 {
+                return nil, fmt.Errorf("PCP error %d (%s)", result, pcpResultNames[result])
+        }
+        if len(resp) < 60 {
+                return nil, errors.New("PCP MAP response too short")
+        }
+        external := net.IP(append([]byte(nil), resp[44:60]...))
+
+        // 删除临时映射 (生命周期为 0), 失败无关紧要
+        gatewayUDPExchange(ctx, addr, pcpMapRequest(local.IP, uint16(local.Port), nonce, 0), matches)
+
+        if ip4 := external.To4(); ip4 != nil {
+                return ip4, nil
+        }
+        return external, nil
+}
+
+// pcpMapRequest 构造一个 UDP 协议的 PCP MAP 请求
+func pcpMapRequest(clientIP net.IP, port uint16, nonce [12]byte, lifetime uint32) []byte {
+        req := make([]byte, 60)
+        req[0] = pcpVersion
+        req[1] = pcpOpcodeMap
+        binary.BigEndian.PutUint32(req[4:], lifetime)
+        copy(req[8:24], clientIP.To16())
+        // MAP 操作数据
+        copy(req[24:36], nonce[:])
+        req[36] = 17 // UDP
+        binary.BigEndian.PutUint16(req[40:], port)
+        binary.BigEndian.PutUint16(req[42:], port)
+        copy(req[44:60], net.IPv4zero.To16()) // 不指定建议的外部地址
+        return req
+}
+
+// gatewayUDPExchange 向网关发送请求并等待 matches 认可的响应
+// 按 RFC 6886 从 250ms 开始指数退避重传, 直到 ctx 超时
+func gatewayUDPExchange(ctx context.Context, addr string, req []byte, matches func([]byte) bool) ([]byte, error) {
+        var d net.Dialer
+        conn, err := d.DialContext(ctx, "udp4", addr)
+        if err != nil {
+                return nil, fmt.Errorf("dialing gateway %s failed: %w", addr, err)
+        }
+        defer conn.Close()
+
+        buf := make([]byte, 1100)
+        rto := natpmpInitialRTO
+        for {
+                if _, err := conn.Write(req); err != nil {
+                        return nil, fmt.Errorf("sending request to gateway %s failed: %w", addr, err)
+                }
+                deadline := time.Now().Add(rto)
+                if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+                        deadline = ctxDeadline
+                }
+                conn.SetReadDeadline(deadline)
+
+                for {
+                        n, err := conn.Read(buf)
+                        if err != nil {
+                                var netErr net.Error
+                                if errors.As(err, &netErr) && netErr.Timeout() && ctx.Err() == nil {
+                                        break // 重传
+                                }
+                                return nil, fmt.Errorf("no response from gateway %s: %w", addr, err)
+                        }
+                        if matches(buf[:n]) {
+                                return append([]byte(nil), buf[:n]...), nil
+                        }
+                        // NAT-PMP 网关对 PCP 请求会回复版本 0 的 "unsupported version"
+                        if n >= 4 && buf[0] == 0 && buf[1]&0x80 != 0 && req[0] == pcpVersion {
+                                return append([]byte(nil), buf[:n]...), nil
+                        }
+                }
+                rto *= 2
+        }
+}
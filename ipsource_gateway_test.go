@@ -0,0 +1,219 @@
+package main
+
+import (
+        "context"
+        "encoding/binary"
+        "io"
+        "net"
+        "net/http"
+        "net/http/httptest"
+        "strings"
+        "sync"
+        "testing"
+        "time"
+)
+
+// startGatewayResponder 启动一个假的 NAT-PMP/PCP 网关, reply 返回对每个请求的响应 (nil 表示不回复)
+func startGatewayResponder(t *testing.T, reply func(req []byte) []byte) (addr string, requests func() [][]byte) {
+        t.Helper()
+        pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+        if err != nil {
+                t.Fatal(err)
+        }
+        t.Cleanup(func() { pc.Close() })
+        var mu sync.Mutex
+        var seen [][]byte
+        go func() {
+                buf := make([]byte, 1100)
+                for {
+                        n, from, err := pc.ReadFrom(buf)
+                        if err != nil {
+                                return
+                        }
+                        req := append([]byte(nil), buf[:n]...)
+                        mu.Lock()
+                        seen = append(seen, req)
+                        mu.Unlock()
+                        if resp := reply(req); resp != nil {
+                                pc.WriteTo(resp, from)
+                        }
+                }
+        }()
+        return pc.LocalAddr().String(), func() [][]byte {
+                mu.Lock()
+                defer mu.Unlock()
+                return append([][]byte(nil), seen...)
+        }
+}
+
+// natpmpReply 构造 NAT-PMP 外部地址响应
+func natpmpReply(result uint16, ip string) []byte {
+        resp := make([]byte, 12)
+        resp[1] = 128
+        binary.BigEndian.PutUint16(resp[2:], result)
+        copy(resp[8:], net.ParseIP(ip).To4())
+        return resp
+}
+
+// pcpReply 构造对 PCP MAP 请求 req 的响应, 回显 nonce 并分配 external
+func pcpReply(req []byte, result byte, external string) []byte {
+        resp := make([]byte, 60)
+        resp[0] = pcpVersion
+        resp[1] = 0x80 | pcpOpcodeMap
+        resp[3] = result
+        copy(resp[24:44], req[24:44])
+        copy(resp[44:60], net.ParseIP(external).To16())
+        return resp
+}
+
+// detectGateway 用 cfg 创建网关来源并检测 IPv4 地址
+func detectGateway(t *testing.T, cfg IPSourceConfig) (string, error) {
+        t.Helper()
+        src, err := newIPSource(cfg)
+        if err != nil {
+                t.Fatal(err)
+        }
+        return detectFromSource(context.Background(), src, "ipv4")
+}
+
+func TestNATPMPSource(t *testing.T) {
+        addr, _ := startGatewayResponder(t, func(req []byte) []byte {
+                if len(req) != 2 || req[0] != 0 || req[1] != 0 {
+                        return nil
+                }
+                return natpmpReply(0, "8.8.4.4")
+        })
+        got, err := detectGateway(t, IPSourceConfig{Type: "natpmp", Gateway: addr})
+        if err != nil || got != "8.8.4.4" {
+                t.Fatalf("got %q, %v; want 8.8.4.4", got, err)
+        }
+
+        addr, _ = startGatewayResponder(t, func(req []byte) []byte { return natpmpReply(3, "0.0.0.0") })
+        _, err = detectGateway(t, IPSourceConfig{Type: "natpmp", Gateway: addr})
+        if err == nil || !strings.Contains(err.Error(), "NAT-PMP error 3 (network failure)") {
+                t.Fatalf("error = %v", err)
+        }
+}
+
+func TestPCPSource(t *testing.T) {
+        addr, requests := startGatewayResponder(t, func(req []byte) []byte {
+                if len(req) != 60 || req[0] != pcpVersion || req[1] != pcpOpcodeMap || req[36] != 17 {
+                        return nil
+                }
+                return pcpReply(req, 0, "9.9.9.9")
+        })
+        got, err := detectGateway(t, IPSourceConfig{Type: "pcp", Gateway: addr})
+        if err != nil || got != "9.9.9.9" {
+                t.Fatalf("got %q, %v; want 9.9.9.9", got, err)
+        }
+        // 探测映射之后应当以生命周期 0 删除
+        reqs := requests()
+        if len(reqs) != 2 || binary.BigEndian.Uint32(reqs[0][4:]) != pcpMapLifetime || binary.BigEndian.Uint32(reqs[1][4:]) != 0 {
+                t.Fatalf("unexpected requests %x", reqs)
+        }
+}
+
+func TestPCPSourceErrors(t *testing.T) {
+        tests := []struct {
+                name    string
+                reply   func(req []byte) []byte
+                wantErr string
+        }{
+                {"result code", func(req []byte) []byte { return pcpReply(req, 8, "0.0.0.0") }, "PCP error 8 (NO_RESOURCES)"},
+                {"natpmp only", func(req []byte) []byte { return []byte{0, 0x80 | pcpOpcodeMap, 0, 1} }, "only speaks NAT-PMP"},
+                {"wrong nonce", func(req []byte) []byte {
+                        resp := pcpReply(req, 0, "9.9.9.9")
+                        resp[24] ^= 0xff
+                        return resp
+                }, "no response from gateway"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        addr, _ := startGatewayResponder(t, tt.reply)
+                        src, err := newGatewaySource(IPSourceConfig{Type: "pcp", Gateway: addr}, 600*time.Millisecond)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        _, err = src.Detect(context.Background(), "ipv4")
+                        if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                                t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+                        }
+                })
+        }
+}
+
+const upnpDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <serviceList>
+      <service><serviceType>urn:schemas-upnp-org:service:Layer3Forwarding:1</serviceType><controlURL>/l3f</controlURL></service>
+    </serviceList>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <deviceList>
+          <device>
+            <deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+            <serviceList>
+              <service><serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType><controlURL>ctl/IPConn</controlURL></service>
+            </serviceList>
+          </device>
+        </deviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`
+
+func TestUPnPSource(t *testing.T) {
+        var soapAction, soapBody string
+        external := "1.0.0.1"
+        srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                switch r.URL.Path {
+                case "/igd/desc.xml":
+                        io.WriteString(w, upnpDescription)
+                case "/igd/ctl/IPConn":
+                        body, _ := io.ReadAll(r.Body)
+                        soapAction, soapBody = r.Header.Get("SOAPAction"), string(body)
+                        if external == "" {
+                                w.WriteHeader(http.StatusInternalServerError)
+                                io.WriteString(w, `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault><detail><UPnPError><errorCode>501</errorCode><errorDescription>Action Failed</errorDescription></UPnPError></detail></s:Fault></s:Body></s:Envelope>`)
+                                return
+                        }
+                        io.WriteString(w, `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>`+
+                                `<u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">`+
+                                `<NewExternalIPAddress>`+external+`</NewExternalIPAddress></u:GetExternalIPAddressResponse></s:Body></s:Envelope>`)
+                default:
+                        http.NotFound(w, r)
+                }
+        }))
+        defer srv.Close()
+
+        cfg := IPSourceConfig{Type: "upnp", URL: srv.URL + "/igd/desc.xml"}
+        got, err := detectGateway(t, cfg)
+        if err != nil || got != "1.0.0.1" {
+                t.Fatalf("got %q, %v; want 1.0.0.1", got, err)
+        }
+        if soapAction != `"urn:schemas-upnp-org:service:WANIPConnection:1#GetExternalIPAddress"` || !strings.Contains(soapBody, "<u:GetExternalIPAddress") {
+                t.Fatalf("unexpected SOAP request %s: %s", soapAction, soapBody)
+        }
+
+        external = ""
+        _, err = detectGateway(t, cfg)
+        if err == nil || !strings.Contains(err.Error(), "Action Failed") {
+                t.Fatalf("error = %v", err)
+        }
+}
+
+func TestGatewaySourceIPv6Unsupported(t *testing.T) {
+        src, err := newGatewaySource(IPSourceConfig{Type: "natpmp", Gateway: "127.0.0.1"}, time.Second)
+        if err != nil {
+                t.Fatal(err)
+        }
+        if _, err := src.Detect(context.Background(), "ipv6"); err == nil {
+                t.Fatal("natpmp should not report IPv6 addresses")
+        }
+        if _, err := newGatewaySource(IPSourceConfig{Type: "pcp", URL: "http://x"}, time.Second); err == nil {
+                t.Fatal("pcp should reject 'url'")
+        }
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+        "bytes"
+        "context"
+        "crypto/rand"
+        "encoding/binary"
+        "errors"
+        "fmt"
+        "net"
+        "strings"
+        "time"
+)
+
+// STUN (RFC 5389) 常量
+const (
+        stunBindingRequest  = 0x0001
+        stunBindingSuccess  = 0x0101
+        stunBindingError    = 0x0111
+        stunMagicCookie     = 0x2112A442
+        stunHeaderSize      = 20
+        stunAttrMapped      = 0x0001
+        stunAttrErrorCode   = 0x0009
+        stunAttrXORMapped   = 0x0020
+        stunAttrXORMappedV1 = 0x8020 // 部分旧实现使用的非标准类型
+        stunInitialRTO      = 500 * time.Millisecond
+)
+
+// defaultSTUNServers 是未配置 servers 时使用的公共 STUN 服务器
+var defaultSTUNServers = []string{
+        "stun.cloudflare.com:3478",
+        "stun.l.google.com:19302",
+}
+
+// stunSource 通过 STUN Binding 请求获取 NAT 映射后的公网地址
+type stunSource struct {
+        servers []string
+        timeout time.Duration
+}
+
+// newSTUNSource 校验 stun 来源的配置并创建来源
+func newSTUNSource(cfg IPSourceConfig, timeout time.Duration) (*stunSource, error) {
+        servers := append([]string(nil), cfg.Servers...)
+        if len(servers) == 0 {
+                servers = append(servers, defaultSTUNServers...)
+        }
+        for i, server := range servers {
+                if _, _, err := net.SplitHostPort(server); err != nil {
+                        servers[i] = net.JoinHostPort(strings.Trim(server, "[]"), "3478")
+                }
+        }
+        return &stunSource{servers: servers, timeout: timeout}, nil
+}
+
+func (s *stunSource) Name() string {
+        return "stun " + strings.Join(s.servers, ",")
+}
+
+// Detect 依次向各个服务器发送 Binding 请求, 返回第一个成功的映射地址
+func (s *stunSource) Detect(ctx context.Context, ipversion string) (string, error) {
+        ctx, cancel := context.WithTimeout(ctx, s.timeout)
+        defer cancel()
+
+        network := "udp4"
+        if ipversion == "ipv6" {
+                network = "udp6"
+        }
+        var errs []error
+        for _, server := range s.servers {
+                ip, err := stunBinding(ctx, network, server)
+                if err == nil {
+                        return ip.String(), nil
+                }
+                errs = append(errs, fmt.Errorf("%s: %w", server, err))
+                if ctx.Err() != nil {
+                        break
+                }
+        }
+        return "", errors.Join(errs...)
+}
+
+// stunBinding 向单个服务器发送 Binding 请求, 按 RFC 5389 的 RTO 规则重传
+func stunBinding(ctx context.Context, network, server string) (net.IP, error) {
+        var d net.Dialer
+        conn, err := d.DialContext(ctx, network, server)
+        if err != nil {
+                return nil, fmt.Errorf("dialing STUN server failed: %w", err)
+        }
+        defer conn.Close()
+
+        var txID [12]byte
+        if _, err := rand.Read(txID[:]); err != nil {
+                return nil, fmt.Errorf("generating transaction ID failed: %w", err)
+        }
+        req := make([]byte, stunHeaderSize)
+        binary.BigEndian.PutUint16(req[0:], stunBindingRequest)
+        binary.BigEndian.PutUint16(req[2:], 0)
+        binary.BigEndian.PutUint32(req[4:], stunMagicCookie)
+        copy(req[8:], txID[:])
+
+        buf := make([]byte, 1500)
+        rto := stunInitialRTO
+        for {
+                if _, err := conn.Write(req); err != nil {
+                        return nil, fmt.Errorf("sending binding request failed: %w", err)
+                }
+                deadline := time.Now().Add(rto)
+                if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+                        deadline = ctxDeadline
+                }
+                conn.SetReadDeadline(deadline)
+
+                for {
+                        n, err := conn.Read(buf)
+                        if err != nil {
+                                var netErr net.Error
+                                if errors.As(err, &netErr) && netErr.Timeout() && ctx.Err() == nil {
+                                        break // 重传
+                                }
+                                return nil, fmt.Errorf("reading binding response failed: %w", err)
+                        }
+                        msg := buf[:n]
+                        if n < stunHeaderSize || !bytes.Equal(msg[8:20], txID[:]) {
+                                continue // 不属于本次事务
+                        }
+                        return parseSTUNResponse(msg)
+                }
+                rto *= 2
+        }
+}
+
+// parseSTUNResponse 解析 Binding 响应, 优先使用 XOR-MAPPED-ADDRESS
+func parseSTUNResponse(msg []byte) (net.IP, error) {
+        if len(msg) < stunHeaderSize || binary.BigEndian.Uint32(msg[4:]) != stunMagicCookie {
+                return nil, errors.New("invalid STUN message")
+        }
+        msgType := binary.BigEndian.Uint16(msg[0:])
+        length := int(binary.BigEndian.Uint16(msg[2:]))
+        if stunHeaderSize+length > len(msg) {
+                return nil, errors.New("STUN message truncated")
+        }
+        attrs := msg[stunHeaderSize : stunHeaderSize+length]
+
+        var mapped net.IP
+        for len(attrs) >= 4 {
+                attrType := binary.BigEndian.Uint16(attrs[0:])
+                attrLen := int(binary.BigEndian.Uint16(attrs[2:]))
+                if 4+attrLen > len(attrs) {
+                        return nil, errors.New("STUN attribute truncated")
+                }
+                value := attrs[4 : 4+attrLen]
+
+                switch attrType {
+                case stunAttrXORMapped, stunAttrXORMappedV1:
+                        if ip := decodeSTUNAddress(value, msg[4:20]); ip != nil && msgType == stunBindingSuccess {
+                                return ip, nil
+                        }
+                case stunAttrMapped:
+                        mapped = decodeSTUNAddress(value, nil)
+                case stunAttrErrorCode:
+                        if msgType == stunBindingError && len(value) >= 4 {
+                                code := int(value[2]&0x7)*100 + int(value[3])
+                                return nil, fmt.Errorf("STUN error %d: %s", code, string(value[4:]))
+                        }
+                }
+                // 属性值按 4 字节对齐
+                padded := (attrLen + 3) &^ 3
+                if 4+padded > len(attrs) {
+                        break
+                }
+                attrs = attrs[4+padded:]
+        }
+
+        if msgType != stunBindingSuccess {
+                return nil, fmt.Errorf("unexpected STUN message type 0x%04x", msgType)
+        }
+        if mapped == nil {
+                return nil, errors.New("STUN response contains no mapped address")
+        }
+        return mapped, nil
+}
+
+// decodeSTUNAddress 解析 (XOR-)MAPPED-ADDRESS 属性值
+// xorKey 为魔术字加事务 ID (共 16 字节), 为 nil 时表示未做异或处理
+func decodeSTUNAddress(value []byte, xorKey []byte) net.IP {
+        if len(value) < 4 {
+                return nil
+        }
+        var addrLen int
+        switch value[1] {
+        case 0x01:
+                addrLen = net.IPv4len
+        case 0x02:
+                addrLen = net.IPv6len
+        default:
+                return nil
+        }
+        if len(value) < 4+addrLen {
+                return nil
+        }
+        ip := make(net.IP, addrLen)
+        copy(ip, value[4:4+addrLen])
+        if xorKey != nil {
+                for i := range ip {
+                        ip[i] ^= xorKey[i]
+                }
+        }
+        return ip
+}
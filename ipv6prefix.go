@@ -0,0 +1,50 @@
+package main
+
+import (
+        "fmt"
+        "net"
+)
+
+// defaultIPv6PrefixLength 是未配置 ipv6_prefix_length 时使用的前缀长度
+const defaultIPv6PrefixLength = 64
+
+// parseInterfaceID 解析接口标识符
+// 支持 IPv6 形式 (如 "::1234:5678") 和 MAC 地址 (如 "00:11:22:33:44:55", 按修改后的 EUI-64 规则转换)
+func parseInterfaceID(s string) (net.IP, error) {
+        if ip := net.ParseIP(s); ip != nil && ip.To4() == nil {
+                return ip, nil
+        }
+        mac, err := net.ParseMAC(s)
+        if err != nil || len(mac) != 6 {
+                return nil, fmt.Errorf("'%s' is neither an IPv6 interface identifier nor a 48-bit MAC address", s)
+        }
+        // EUI-64: 在 MAC 中间插入 ff:fe, 并翻转 U/L 位
+        iid := make(net.IP, net.IPv6len)
+        copy(iid[8:11], mac[0:3])
+        iid[8] ^= 0x02
+        iid[11], iid[12] = 0xff, 0xfe
+        copy(iid[13:16], mac[3:6])
+        return iid, nil
+}
+
+// combineIPv6Prefix 取检测到的地址的前 prefixLen 位作为前缀, 与接口标识符的其余位组合成下游主机的地址
+func combineIPv6Prefix(detected string, prefixLen int, interfaceID string) (string, error) {
+        ip := net.ParseIP(detected)
+        if ip == nil || ip.To4() != nil {
+                return "", fmt.Errorf("'%s' is not an IPv6 address", detected)
+        }
+        if prefixLen < 1 || prefixLen > 127 {
+                return "", fmt.Errorf("invalid IPv6 prefix length %d", prefixLen)
+        }
+        iid, err := parseInterfaceID(interfaceID)
+        if err != nil {
+                return "", err
+        }
+
+        mask := net.CIDRMask(prefixLen, 128)
+        out := make(net.IP, net.IPv6len)
+        for i := range out {
+                out[i] = ip[i]&mask[i] | iid[i]&^mask[i]
+        }
+        return out.String(), nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+        "strings"
+        "testing"
+)
+
+func TestParseInterfaceID(t *testing.T) {
+        tests := []struct {
+                in, want string
+        }{
+                {"00:11:22:33:44:55", "::211:22ff:fe33:4455"}, // U/L bit set by the flip
+                {"02:11:22:33:44:55", "::11:22ff:fe33:4455"},  // and cleared when already set
+                {"00-11-22-33-44-55", "::211:22ff:fe33:4455"},
+                {"::1234:5678", "::1234:5678"},
+                {"::1", "::1"},
+        }
+        for _, tt := range tests {
+                iid, err := parseInterfaceID(tt.in)
+                if err != nil || iid.String() != tt.want {
+                        t.Errorf("parseInterfaceID(%s) = %v, %v; want %s", tt.in, iid, err, tt.want)
+                }
+        }
+        for _, bad := range []string{"", "192.0.2.1", "00-11-22-33-44-55-66-77", "host-1"} {
+                if _, err := parseInterfaceID(bad); err == nil {
+                        t.Errorf("parseInterfaceID(%q) should fail", bad)
+                }
+        }
+}
+
+func TestCombineIPv6Prefix(t *testing.T) {
+        tests := []struct {
+                detected    string
+                prefixLen   int
+                interfaceID string
+                want        string
+        }{
+                {"2001:db8:aa:bb:1:2:3:4", 64, "::1234:5678", "2001:db8:aa:bb::1234:5678"},
+                {"2001:db8:aa:bb:1:2:3:4", 56, "::1234:5678", "2001:db8:aa::1234:5678"},
+                // /56: the low byte of the 4th group comes from the interface ID
+                {"2001:db8:aa:bbcc:1:2:3:4", 56, "::42:0:0:1234:5678", "2001:db8:aa:bb42::1234:5678"},
+                {"2001:db8:aa:bb:1:2:3:4", 64, "00:11:22:33:44:55", "2001:db8:aa:bb:211:22ff:fe33:4455"},
+        }
+        for _, tt := range tests {
+                got, err := combineIPv6Prefix(tt.detected, tt.prefixLen, tt.interfaceID)
+                if err != nil || got != tt.want {
+                        t.Errorf("combineIPv6Prefix(%s, %d, %s) = %q, %v; want %s", tt.detected, tt.prefixLen, tt.interfaceID, got, err, tt.want)
+                }
+        }
+
+        errTests := []struct {
+                detected  string
+                prefixLen int
+                wantErr   string
+        }{
+                {"2001:db8::1", 0, "invalid IPv6 prefix length 0"},
+                {"2001:db8::1", 128, "invalid IPv6 prefix length 128"},
+                {"203.0.113.5", 64, "is not an IPv6 address"},
+                {"::ffff:203.0.113.5", 64, "is not an IPv6 address"},
+        }
+        for _, tt := range errTests {
+                _, err := combineIPv6Prefix(tt.detected, tt.prefixLen, "::1")
+                if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                        t.Errorf("combineIPv6Prefix(%s, %d) error = %v, want it to contain %q", tt.detected, tt.prefixLen, err, tt.wantErr)
+                }
+        }
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+        "context"
+        "errors"
+        "fmt"
+        "log"
+        "net/url"
+        "strings"
+        "time"
+)
+
+// 服务商 (Config.Provider / RecordConfig.Provider)
+const (
+        providerCloudflare = "cloudflare" // Cloudflare API (默认)
+        providerRFC2136    = "rfc2136"    // RFC 2136 DNS UPDATE (BIND, Knot 等), 可选 TSIG 签名
+        providerPowerDNS   = "powerdns"   // PowerDNS 权威服务器的 HTTP API
+        providerAliDNS     = "alidns"     // 阿里云解析 (HMAC-SHA1 签名的 RPC API)
+        providerDNSPod     = "dnspod"     // DNSPod / 腾讯云 API 3.0 (TC3-HMAC-SHA256 签名)
+        providerRoute53    = "route53"    // AWS Route 53 (SigV4 签名)
+        providerDynDNS2    = "dyndns2"    // 通用 dyndns2 协议 (/nic/update), 只能更新地址
+)
+
+// providerNames 列出全部支持的服务商, 用于校验配置和错误信息
+var providerNames = []string{providerCloudflare, providerRFC2136, providerPowerDNS, providerAliDNS, providerDNSPod, providerRoute53, providerDynDNS2}
+
+// defaultRecordTTL 是 ttl 为 1 ("自动", 仅 Cloudflare 支持) 时其他服务商使用的 TTL
+const defaultRecordTTL = 300
+
+// providerMinTTL 是服务商接受的最小 TTL (阿里云解析和 DNSPod 免费套餐为 600 秒), 更低的值会被 API 拒绝
+var providerMinTTL = map[string]int{
+        providerAliDNS: 600,
+        providerDNSPod: 600,
+}
+
+// errRecordExists 表示创建记录时发现同名同类型的记录已经存在 (例如被其他实例抢先创建)
+var errRecordExists = errors.New("record already exists")
+
+// Record 是与服务商无关的一条 DNS 记录
+type Record struct {
+        ID      string // 服务商内部的记录 ID (没有时为空)
+        Type    string // "A" 或 "AAAA"
+        Name    string // 完整域名
+        Content string // IP 地址
+        TTL     int
+        Proxied bool // 仅 Cloudflare 使用
+}
+
+// Provider 是 DNS 服务商的后端, 负责查询和修改区域中的记录
+// zone 为小写、不带结尾点的区域名, 服务商需要的区域 ID 等由实现自行解析和缓存
+type Provider interface {
+        // Name 返回服务商名称, 用于日志
+        Name() string
+        // Lookup 查询指定名称和类型的记录, 不存在时返回 nil, nil
+        Lookup(ctx context.Context, zone, fqdn, recordType string) (*Record, error)
+        // Create 创建记录; 相同的记录已经存在时可返回包装了 errRecordExists 的错误
+        Create(ctx context.Context, zone string, record Record) (*Record, error)
+        // Update 将已有的记录 existing 修改为 record
+        Update(ctx context.Context, zone string, existing *Record, record Record) (*Record, error)
+}
+
+// zoneDetector 由能够根据完整域名查找所属区域的服务商实现 (用于未配置 zone 的记录)
+type zoneDetector interface {
+        DetectZone(ctx context.Context, fqdn string) (string, error)
+}
+
+// newProvider 按名称创建服务商后端
+func newProvider(name string, u *updater) (Provider, error) {
+        switch name {
+        case providerCloudflare:
+                return newCloudflareProvider(u.config, u.saveZoneID), nil
+        case providerRFC2136:
+                return newRFC2136Provider(u.config.RFC2136)
+        case providerPowerDNS:
+                return newPowerDNSProvider(u.config.PowerDNS)
+        case providerAliDNS:
+                return newAliDNSProvider(u.config.AliDNS)
+        case providerDNSPod:
+                return newDNSPodProvider(u.config.DNSPod)
+        case providerRoute53:
+                return newRoute53Provider(u.config.Route53)
+        case providerDynDNS2:
+                return newDynDNS2Provider(u.config.DynDNS2, getStateFilePath(u.config, u.configPath, ".dyndns2.hold"))
+        }
+        return nil, fmt.Errorf("unknown provider '%s'", name)
+}
+
+// validateProviderConfig 检查服务商所需的配置是否齐全 (只对记录实际用到的服务商调用)
+func validateProviderConfig(config Config, name string) error {
+        var err error
+        switch name {
+        case providerCloudflare:
+                if config.APIToken == "" {
+                        return fmt.Errorf("missing required field 'api_token' (and no '%s' systemd credential is available)", apiTokenCredential)
+                }
+        case providerRFC2136:
+                err = config.RFC2136.validate()
+        case providerPowerDNS:
+                err = config.PowerDNS.validate()
+        case providerAliDNS:
+                err = config.AliDNS.validate()
+        case providerDNSPod:
+                err = config.DNSPod.validate()
+        case providerRoute53:
+                _, err = config.Route53.validate() // 访问密钥也可以来自环境变量或共享凭据文件, 因此 route53 可以省略
+        case providerDynDNS2:
+                err = config.DynDNS2.validate()
+        }
+        if err != nil {
+                return fmt.Errorf("invalid '%s': %w", name, err)
+        }
+        return nil
+}
+
+// validateEndpoint 检查可选的 API 地址覆盖 (endpoint) 是否为 http(s) URL
+func validateEndpoint(endpoint string) error {
+        if endpoint == "" {
+                return nil
+        }
+        if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+                return fmt.Errorf("invalid 'endpoint' ('%s'), must be an http(s) URL", endpoint)
+        }
+        return nil
+}
+
+// relativeName 返回完整域名相对于区域的主机记录名, 区域顶点为 "@"
+func relativeName(fqdn, zone string) string {
+        name := strings.TrimSuffix(fqdn, ".")
+        if strings.EqualFold(name, zone) {
+                return "@"
+        }
+        if len(name) > len(zone)+1 && strings.EqualFold(name[len(name)-len(zone)-1:], "."+zone) {
+                return name[:len(name)-len(zone)-1]
+        }
+        return name
+}
+
+// percentEncode 按 RFC 3986 编码 (空格为 %20, 只保留 A-Z a-z 0-9 - _ . ~), 用于 AliDNS 和 SigV4 签名
+func percentEncode(s string) string {
+        s = url.QueryEscape(s)
+        s = strings.ReplaceAll(s, "+", "%20")
+        s = strings.ReplaceAll(s, "*", "%2A")
+        return strings.ReplaceAll(s, "%7E", "~")
+}
+
+// validProvider 报告 name 是否为支持的服务商
+func validProvider(name string) bool {
+        for _, n := range providerNames {
+                if n == name {
+                        return true
+                }
+        }
+        return false
+}
+
+// recordDetails 返回用于日志的记录摘要, 如 "ID: 123, Proxied: false, TTL: 300"
+func recordDetails(r *Record) string {
+        if r.ID == "" {
+                return fmt.Sprintf("TTL: %d", r.TTL)
+        }
+        return fmt.Sprintf("ID: %s, Proxied: %t, TTL: %d", r.ID, r.Proxied, r.TTL)
+}
+
+// upsertRecord 创建或更新 DNS 记录 (返回 bool 表示是否成功，以便缓存 IP)
+// cached 非空时视为该记录的已知状态, 跳过查询直接比较和更新; 成功时返回记录的最新状态供下次使用
+func upsertRecord(ctx context.Context, p Provider, zone string, target recordTarget, currentIP string, cached *Record) (*Record, bool) {
+        fqdn, recordType := target.FQDN, target.Type
+
+        nowStr := time.Now().Format("2006-01-02 15:04:05")
+        existingRecord := cached
+        if existingRecord != nil {
+                log.Printf("[%s] ℹ️ Using cached state of DNS record %s (%s) (IP: %s, %s).", nowStr, fqdn, recordType, existingRecord.Content, recordDetails(existingRecord))
+        } else {
+                log.Printf("[%s] ℹ️ Checking DNS record %s (%s) via %s...", nowStr, fqdn, recordType, p.Name())
+                var err error
+                existingRecord, err = p.Lookup(ctx, zone, fqdn, recordType)
+                if err != nil {
+                        log.Printf("[%s] ❌ Failed to check existing DNS record state: %v", nowStr, err)
+                        return nil, false // Indicate failure
+                }
+        }
+
+        desired := Record{
+                Type:    recordType,
+                Name:    fqdn,
+                Content: currentIP,
+                TTL:     target.TTL,
+                Proxied: target.Proxied,
+        }
+
+        var result *Record
+        var err error
+        if existingRecord != nil {
+                // Record exists
+                if existingRecord.Content == currentIP && existingRecord.Proxied == target.Proxied && existingRecord.TTL == target.TTL {
+                        log.Printf("[%s] ✅ DNS record %s (%s) is already up-to-date (%s). No change needed.", nowStr, fqdn, recordType, currentIP)
+                        return existingRecord, true // Indicate success (state matches)
+                }
+                // Update existing record
+                log.Printf("[%s] ℹ️ Existing record IP (%s) / settings differ from current IP (%s) / settings. Updating record...", nowStr, existingRecord.Content, currentIP)
+                result, err = p.Update(ctx, zone, existingRecord, desired)
+                if err != nil {
+                        log.Printf("[%s] ❌ Failed to update DNS record %s (%s): %v", nowStr, fqdn, recordType, err)
+                        return nil, false // Indicate failure
+                }
+                log.Printf("[%s] ✅ Successfully updated DNS record %s (%s) => %s (%s)", nowStr, fqdn, recordType, result.Content, recordDetails(result))
+                return result, true
+        }
+
+        // Record does not exist, create it
+        log.Printf("[%s] ℹ️ No existing %s record found for %s. Creating new record...", nowStr, recordType, fqdn)
+        result, err = p.Create(ctx, zone, desired)
+        if errors.Is(err, errRecordExists) {
+                // Someone else created the same record in the meantime: nothing left to do
+                log.Printf("[%s] ✅ DNS record %s (%s) => %s already exists. No change needed.", nowStr, fqdn, recordType, currentIP)
+                return nil, true
+        }
+        if err != nil {
+                log.Printf("[%s] ❌ Failed to create DNS record %s (%s): %v", nowStr, fqdn, recordType, err)
+                return nil, false // Indicate failure
+        }
+        log.Printf("[%s] ✅ Successfully created DNS record %s (%s) => %s (%s)", nowStr, fqdn, recordType, result.Content, recordDetails(result))
+        return result, true
+}
+
+// usesProvider 报告是否有记录使用指定的服务商
+func usesProvider(targets []recordTarget, name string) bool {
+        for _, t := range targets {
+                if t.Provider == name {
+                        return true
+                }
+        }
+        return false
+}
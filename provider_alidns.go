@@ -0,0 +1,220 @@
+package main
+
+import (
+        "context"
+        "crypto/hmac"
+        "crypto/rand"
+        "crypto/sha1"
+        "encoding/base64"
+        "encoding/hex"
+        "encoding/json"
+        "errors"
+        "fmt"
+        "io"
+        "log"
+        "net/http"
+        "net/url"
+        "sort"
+        "strconv"
+        "strings"
+        "time"
+)
+
+// aliDNSDefaultEndpoint 是阿里云解析 (AliDNS) API 的默认地址
+const aliDNSDefaultEndpoint = "https://alidns.aliyuncs.com"
+
+// AliDNSConfig 配置阿里云解析 (AliDNS) 后端
+type AliDNSConfig struct {
+        AccessKeyID     string `json:"access_key_id"`
+        AccessKeySecret string `json:"access_key_secret"`
+        Endpoint        string `json:"endpoint,omitempty"` // 默认 https://alidns.aliyuncs.com, 可指向其他地域或模拟服务器
+}
+
+// validate 检查 AliDNS 设置
+func (c *AliDNSConfig) validate() error {
+        if c == nil || c.AccessKeyID == "" || c.AccessKeySecret == "" {
+                return errors.New("missing required fields 'access_key_id' and 'access_key_secret'")
+        }
+        return validateEndpoint(c.Endpoint)
+}
+
+// aliDNSProvider 通过阿里云 RPC 风格 API 管理记录, 请求使用 HMAC-SHA1 签名
+type aliDNSProvider struct {
+        endpoint string
+        keyID    string
+        secret   string
+        client   *http.Client
+        now      func() time.Time // 签名时间, 测试时可替换
+}
+
+// newAliDNSProvider 根据配置创建 AliDNS 后端
+func newAliDNSProvider(config *AliDNSConfig) (*aliDNSProvider, error) {
+        if err := config.validate(); err != nil {
+                return nil, fmt.Errorf("invalid 'alidns': %w", err)
+        }
+        endpoint := config.Endpoint
+        if endpoint == "" {
+                endpoint = aliDNSDefaultEndpoint
+        }
+        return &aliDNSProvider{
+                endpoint: strings.TrimSuffix(endpoint, "/") + "/",
+                keyID:    config.AccessKeyID,
+                secret:   config.AccessKeySecret,
+                client:   &http.Client{Timeout: 30 * time.Second},
+                now:      time.Now,
+        }, nil
+}
+
+func (p *aliDNSProvider) Name() string {
+        return "AliDNS API"
+}
+
+// aliDNSRecord 是 API 返回的一条解析记录
+type aliDNSRecord struct {
+        RecordID string `json:"RecordId"`
+        RR       string `json:"RR"`
+        Type     string `json:"Type"`
+        Value    string `json:"Value"`
+        TTL      int    `json:"TTL"`
+        Line     string `json:"Line"`
+}
+
+// aliDNSSignature 计算 RPC 请求签名: 参数按名称排序后用 percentEncode 编码, 以 "方法&%2F&编码后的参数串" 为待签字符串,
+// 使用 AccessKeySecret + "&" 作为密钥做 HMAC-SHA1, 结果 base64 编码
+func aliDNSSignature(method string, params url.Values, secret string) string {
+        keys := make([]string, 0, len(params))
+        for k := range params {
+                keys = append(keys, k)
+        }
+        sort.Strings(keys)
+        pairs := make([]string, 0, len(keys))
+        for _, k := range keys {
+                pairs = append(pairs, percentEncode(k)+"="+percentEncode(params.Get(k)))
+        }
+        stringToSign := method + "&" + percentEncode("/") + "&" + percentEncode(strings.Join(pairs, "&"))
+
+        mac := hmac.New(sha1.New, []byte(secret+"&"))
+        mac.Write([]byte(stringToSign))
+        return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// call 发送一个签名的 API 请求, 成功时把 JSON 响应解析到 out
+// 失败响应的正文为 {"Code": "...", "Message": "..."}
+func (p *aliDNSProvider) call(ctx context.Context, action string, params url.Values, out interface{}) error {
+        var nonce [16]byte
+        if _, err := rand.Read(nonce[:]); err != nil {
+                return fmt.Errorf("generating signature nonce failed: %w", err)
+        }
+        params.Set("Action", action)
+        params.Set("Format", "JSON")
+        params.Set("Version", "2015-01-09")
+        params.Set("AccessKeyId", p.keyID)
+        params.Set("SignatureMethod", "HMAC-SHA1")
+        params.Set("SignatureVersion", "1.0")
+        params.Set("SignatureNonce", hex.EncodeToString(nonce[:]))
+        params.Set("Timestamp", p.now().UTC().Format("2006-01-02T15:04:05Z"))
+        params.Set("Signature", aliDNSSignature(http.MethodGet, params, p.secret))
+
+        req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.endpoint+"?"+params.Encode(), nil)
+        if err != nil {
+                return fmt.Errorf("creating request failed: %w", err)
+        }
+        resp, err := p.client.Do(req)
+        if err != nil {
+                return fmt.Errorf("%s request failed: %w", action, err)
+        }
+        defer resp.Body.Close()
+        body, err := io.ReadAll(resp.Body)
+        if err != nil {
+                return fmt.Errorf("reading %s response failed (status: %s): %w", action, resp.Status, err)
+        }
+
+        if resp.StatusCode != http.StatusOK {
+                var apiErr struct {
+                        Code    string `json:"Code"`
+                        Message string `json:"Message"`
+                }
+                if json.Unmarshal(body, &apiErr) != nil || apiErr.Code == "" {
+                        return fmt.Errorf("%s failed: %s: %s", action, resp.Status, strings.TrimSpace(string(body)))
+                }
+                err := fmt.Errorf("%s failed: %s: %s", action, apiErr.Code, apiErr.Message)
+                switch {
+                case apiErr.Code == "DomainRecordDuplicate":
+                        return fmt.Errorf("%w: %w", errRecordExists, err)
+                case strings.HasPrefix(apiErr.Code, "InvalidAccessKeyId") || apiErr.Code == "SignatureDoesNotMatch" || apiErr.Code == "Forbidden.RAM":
+                        return fmt.Errorf("%w (check 'access_key_id', 'access_key_secret' and the AliyunDNSFullAccess permission)", err)
+                }
+                return err
+        }
+        if err := json.Unmarshal(body, out); err != nil {
+                return fmt.Errorf("parsing %s response failed: %w", action, err)
+        }
+        return nil
+}
+
+// Lookup 通过 DescribeSubDomainRecords 查询记录, 只考虑默认线路
+func (p *aliDNSProvider) Lookup(ctx context.Context, zone, fqdn, recordType string) (*Record, error) {
+        var result struct {
+                DomainRecords struct {
+                        Record []aliDNSRecord `json:"Record"`
+                } `json:"DomainRecords"`
+        }
+        params := url.Values{"SubDomain": {fqdn}, "DomainName": {zone}, "Type": {recordType}, "PageSize": {"100"}}
+        if err := p.call(ctx, "DescribeSubDomainRecords", params, &result); err != nil {
+                return nil, fmt.Errorf("requesting DNS record %s (%s) failed: %w", fqdn, recordType, err)
+        }
+
+        nowStr := time.Now().Format("2006-01-02 15:04:05")
+        var records []aliDNSRecord
+        for _, r := range result.DomainRecords.Record {
+                if r.Type == recordType && (r.Line == "" || r.Line == "default") {
+                        records = append(records, r)
+                }
+        }
+        if len(records) == 0 {
+                log.Printf("[%s] ℹ️ No existing %s record found for %s via API.", nowStr, recordType, fqdn)
+                return nil, nil
+        }
+        log.Printf("[%s] ℹ️ Found existing %s record for %s via API (ID: %s, IP: %s).", nowStr, recordType, fqdn, records[0].RecordID, records[0].Value)
+        if len(records) > 1 {
+                log.Printf("[%s] ⚠️ Warning: Found multiple %s records for %s. Using the first one (ID: %s).", nowStr, recordType, fqdn, records[0].RecordID)
+        }
+        return &Record{ID: records[0].RecordID, Type: recordType, Name: fqdn, Content: records[0].Value, TTL: records[0].TTL}, nil
+}
+
+func (p *aliDNSProvider) Create(ctx context.Context, zone string, record Record) (*Record, error) {
+        var result struct {
+                RecordID string `json:"RecordId"`
+        }
+        params := url.Values{
+                "DomainName": {zone},
+                "RR":         {relativeName(record.Name, zone)},
+                "Type":       {record.Type},
+                "Value":      {record.Content},
+                "TTL":        {strconv.Itoa(record.TTL)},
+        }
+        if err := p.call(ctx, "AddDomainRecord", params, &result); err != nil {
+                return nil, err
+        }
+        record.ID = result.RecordID
+        return &record, nil
+}
+
+// Update 通过 UpdateDomainRecord 修改记录
+func (p *aliDNSProvider) Update(ctx context.Context, zone string, existing *Record, record Record) (*Record, error) {
+        var result struct {
+                RecordID string `json:"RecordId"`
+        }
+        params := url.Values{
+                "RecordId": {existing.ID},
+                "RR":       {relativeName(record.Name, zone)},
+                "Type":     {record.Type},
+                "Value":    {record.Content},
+                "TTL":      {strconv.Itoa(record.TTL)},
+        }
+        if err := p.call(ctx, "UpdateDomainRecord", params, &result); err != nil {
+                return nil, err
+        }
+        record.ID = existing.ID
+        return &record, nil
+}
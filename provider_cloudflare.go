@@ -0,0 +1,214 @@
+package main
+
+import (
+        "context"
+        "errors"
+        "fmt"
+        "log"
+        "strings"
+        "time"
+
+        "github.com/Solvris/NetTools/cloudflare"
+)
+
+// --- Cloudflare API Interaction ---
+
+// cloudflareProvider 通过 Cloudflare API 管理记录, 区域以 Zone ID 标识
+type cloudflareProvider struct {
+        client     *cloudflare.Client
+        configIDs  map[string]string     // 配置文件中缓存的 Zone ID (zone_id / zone_ids)
+        zoneIDs    map[string]string     // zone 名称 -> 本次运行已确定的 Zone ID
+        zones      map[string]string     // 自动识别区域时列出的全部区域, 只列出一次
+        saveZoneID func(zone, id string) // 通过 API 获取到新的 Zone ID 后调用, 用于写回配置文件
+}
+
+// newCloudflareProvider 创建 Cloudflare 后端, 配置文件中的 zone_id 对应顶层 zone, zone_ids 对应其他区域
+func newCloudflareProvider(config Config, saveZoneID func(zone, id string)) *cloudflareProvider {
+        configIDs := make(map[string]string, len(config.ZoneIDs)+1)
+        for zone, id := range config.ZoneIDs {
+                configIDs[zone] = id
+        }
+        if config.Zone != "" && config.ZoneID != "" {
+                configIDs[strings.ToLower(strings.TrimSuffix(config.Zone, "."))] = config.ZoneID
+        }
+        return &cloudflareProvider{
+                client:     newCloudflareClient(config),
+                configIDs:  configIDs,
+                zoneIDs:    make(map[string]string),
+                saveZoneID: saveZoneID,
+        }
+}
+
+// newCloudflareClient 创建 API 客户端, 重试等诊断信息输出到日志
+func newCloudflareClient(config Config) *cloudflare.Client {
+        client := cloudflare.NewClient(config.APIToken)
+        if config.APIBaseURL != "" {
+                client.BaseURL = config.APIBaseURL
+        }
+        client.Retry = config.Retry.policy()
+        client.Logf = func(format string, args ...interface{}) {
+                log.Printf("[%s] ⚠️ %s", time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf(format, args...))
+        }
+        return client
+}
+
+// describeAPIError 为常见的 API 错误补充排查提示
+func describeAPIError(err error) error {
+        if errors.Is(err, cloudflare.ErrAuthentication) {
+                return fmt.Errorf("%w (check that 'api_token' is valid and has Zone:Read and DNS:Edit permissions)", err)
+        }
+        return err
+}
+
+func (p *cloudflareProvider) Name() string {
+        return "Cloudflare API"
+}
+
+// DetectZone 查找拥有该完整域名的区域: 列出 Token 可访问的全部区域后取最长的后缀匹配,
+// 因此委派出去的子区域 (e.g., lab.example.com) 会优先于其父区域 (example.com)
+func (p *cloudflareProvider) DetectZone(ctx context.Context, fqdn string) (string, error) {
+        if p.zones == nil {
+                zones, err := listZones(ctx, p.client)
+                if err != nil {
+                        return "", err
+                }
+                p.zones = zones
+        }
+        zone, ok := findOwningZone(fqdn, p.zones)
+        if !ok {
+                return "", fmt.Errorf("none of the %d zones accessible with the API token owns '%s'", len(p.zones), fqdn)
+        }
+        if _, ok := p.zoneIDs[zone]; !ok {
+                p.zoneIDs[zone] = p.zones[zone] // The listing already carries the ID, no need to look it up again
+        }
+        log.Printf("[%s] ✅ Detected zone %s for %s", time.Now().Format("2006-01-02 15:04:05"), zone, fqdn)
+        return zone, nil
+}
+
+// zoneID 返回区域的 Zone ID: 依次查本次运行的缓存、配置文件中缓存的 ID, 都没有时才通过 API 获取
+func (p *cloudflareProvider) zoneID(ctx context.Context, zone string) (string, error) {
+        if id, ok := p.zoneIDs[zone]; ok {
+                return id, nil
+        }
+        if cachedID := p.configIDs[zone]; cachedID != "" {
+                log.Printf("[%s] ✅ Using cached Zone ID for %s from config file: %s", time.Now().Format("2006-01-02 15:04:05"), zone, cachedID)
+                p.zoneIDs[zone] = cachedID
+                return cachedID, nil
+        }
+
+        fetchedZoneID, err := getZoneID(ctx, p.client, zone)
+        if err != nil {
+                return "", err
+        }
+        p.zoneIDs[zone] = fetchedZoneID
+        if p.saveZoneID != nil {
+                p.saveZoneID(zone, fetchedZoneID)
+        }
+        return fetchedZoneID, nil
+}
+
+func (p *cloudflareProvider) Lookup(ctx context.Context, zone, fqdn, recordType string) (*Record, error) {
+        zoneID, err := p.zoneID(ctx, zone)
+        if err != nil {
+                return nil, err
+        }
+        record, err := getDNSRecord(ctx, p.client, zoneID, fqdn, recordType)
+        if err != nil || record == nil {
+                return nil, err
+        }
+        return fromCloudflareRecord(record), nil
+}
+
+func (p *cloudflareProvider) Create(ctx context.Context, zone string, record Record) (*Record, error) {
+        zoneID, err := p.zoneID(ctx, zone)
+        if err != nil {
+                return nil, err
+        }
+        result, err := p.client.CreateDNSRecord(ctx, zoneID, toCloudflareRecord(record))
+        if errors.Is(err, cloudflare.ErrRecordAlreadyExists) {
+                return nil, fmt.Errorf("%w: %w", errRecordExists, err)
+        }
+        if err != nil {
+                return nil, describeAPIError(err)
+        }
+        return fromCloudflareRecord(result), nil
+}
+
+func (p *cloudflareProvider) Update(ctx context.Context, zone string, existing *Record, record Record) (*Record, error) {
+        zoneID, err := p.zoneID(ctx, zone)
+        if err != nil {
+                return nil, err
+        }
+        result, err := p.client.UpdateDNSRecord(ctx, zoneID, existing.ID, toCloudflareRecord(record))
+        if err != nil {
+                return nil, describeAPIError(err)
+        }
+        return fromCloudflareRecord(result), nil
+}
+
+// toCloudflareRecord 和 fromCloudflareRecord 在通用记录和 API 记录之间转换
+func toCloudflareRecord(r Record) cloudflare.DNSRecord {
+        return cloudflare.DNSRecord{ID: r.ID, Type: r.Type, Name: r.Name, Content: r.Content, TTL: r.TTL, Proxied: r.Proxied}
+}
+
+func fromCloudflareRecord(r *cloudflare.DNSRecord) *Record {
+        return &Record{ID: r.ID, Type: r.Type, Name: r.Name, Content: r.Content, TTL: r.TTL, Proxied: r.Proxied}
+}
+
+// getZoneID 通过 API 获取 Zone ID
+func getZoneID(ctx context.Context, client *cloudflare.Client, zoneName string) (string, error) {
+        nowStr := time.Now().Format("2006-01-02 15:04:05")
+        log.Printf("[%s] ℹ️ Fetching Zone ID from Cloudflare API for zone: %s", nowStr, zoneName)
+
+        zones, err := client.ListZones(ctx, zoneName)
+        if err != nil {
+                return "", fmt.Errorf("requesting Zone ID failed: %w", describeAPIError(err))
+        }
+        if len(zones) == 0 {
+                return "", fmt.Errorf("could not find zone '%s' (check the zone name and that the API token can access it)", zoneName)
+        }
+
+        log.Printf("[%s] ✅ Fetched Zone ID via API: %s", nowStr, zones[0].ID)
+        return zones[0].ID, nil
+}
+
+// listZones 通过 API 列出 Token 可访问的全部区域 (区域名 -> Zone ID)
+func listZones(ctx context.Context, client *cloudflare.Client) (map[string]string, error) {
+        nowStr := time.Now().Format("2006-01-02 15:04:05")
+        log.Printf("[%s] ℹ️ Listing zones accessible with the API token", nowStr)
+
+        result, err := client.ListZones(ctx, "")
+        if err != nil {
+                return nil, fmt.Errorf("listing zones failed: %w", describeAPIError(err))
+        }
+        zones := make(map[string]string, len(result))
+        for _, z := range result {
+                zones[strings.ToLower(z.Name)] = z.ID
+        }
+
+        log.Printf("[%s] ✅ Found %d zones via API", nowStr, len(zones))
+        return zones, nil
+}
+
+// getDNSRecord 获取指定名称和类型的 DNS 记录信息 (不存在时返回 nil, nil)
+// fqdn should be the fully qualified domain name (e.g., sub.example.com or example.com for root)
+func getDNSRecord(ctx context.Context, client *cloudflare.Client, zoneID, fqdn, recordType string) (*cloudflare.DNSRecord, error) {
+        nowStr := time.Now().Format("2006-01-02 15:04:05")
+        records, err := client.ListDNSRecords(ctx, zoneID, cloudflare.DNSRecordFilter{Type: recordType, Name: fqdn})
+        if err != nil {
+                return nil, fmt.Errorf("requesting DNS record %s (%s) failed: %w", fqdn, recordType, describeAPIError(err))
+        }
+
+        if len(records) == 0 {
+                log.Printf("[%s] ℹ️ No existing %s record found for %s via API.", nowStr, recordType, fqdn)
+                return nil, nil // Record not found, not an error
+        }
+
+        log.Printf("[%s] ℹ️ Found existing %s record for %s via API (ID: %s, IP: %s).", nowStr, recordType, fqdn, records[0].ID, records[0].Content)
+        if len(records) > 1 {
+                log.Printf("[%s] ⚠️ Warning: Found multiple %s records for %s. Using the first one (ID: %s).",
+                        nowStr, recordType, fqdn, records[0].ID)
+        }
+
+        return &records[0], nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+        "context"
+        "encoding/json"
+        "net/http"
+        "net/http/httptest"
+        "strings"
+        "sync/atomic"
+        "testing"
+
+        "github.com/Solvris/NetTools/cloudflare"
+)
+
+// startZoneListAPI 启动只实现 GET /zones 的 Cloudflare API 模拟服务器, 返回请求计数
+func startZoneListAPI(t *testing.T, zones ...cloudflare.Zone) (baseURL string, requests *atomic.Int32) {
+        t.Helper()
+        requests = new(atomic.Int32)
+        srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                requests.Add(1)
+                if r.Header.Get("Authorization") != "Bearer test-token" {
+                        w.WriteHeader(http.StatusForbidden)
+                        json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "errors": []cloudflare.APIError{{Code: cloudflare.CodeAuthentication, Message: "Authentication error"}}})
+                        return
+                }
+                if r.Method != http.MethodGet || r.URL.Path != "/client/v4/zones" {
+                        http.NotFound(w, r)
+                        return
+                }
+                json.NewEncoder(w).Encode(map[string]interface{}{
+                        "success":     true,
+                        "errors":      []cloudflare.APIError{},
+                        "result":      zones,
+                        "result_info": cloudflare.ResultInfo{Page: 1, PerPage: 50, Count: len(zones), TotalCount: len(zones), TotalPages: 1},
+                })
+        }))
+        t.Cleanup(srv.Close)
+        return srv.URL + "/client/v4", requests
+}
+
+func TestCloudflareDetectZone(t *testing.T) {
+        baseURL, requests := startZoneListAPI(t,
+                cloudflare.Zone{ID: "z1", Name: "example.co.uk"},
+                cloudflare.Zone{ID: "z2", Name: "Lab.Example.co.uk"},
+                cloudflare.Zone{ID: "z3", Name: "example.com"},
+        )
+        p := newCloudflareProvider(Config{APIToken: "test-token", APIBaseURL: baseURL}, nil)
+        ctx := context.Background()
+
+        tests := []struct {
+                fqdn, zone, id string
+        }{
+                {"home.lab.example.co.uk", "lab.example.co.uk", "z2"},
+                {"WWW.Example.co.uk.", "example.co.uk", "z1"},
+                {"example.com", "example.com", "z3"},
+        }
+        for _, tt := range tests {
+                zone, err := p.DetectZone(ctx, tt.fqdn)
+                if err != nil || zone != tt.zone {
+                        t.Fatalf("DetectZone(%s) = %q, %v; want %s", tt.fqdn, zone, err, tt.zone)
+                }
+                // The zone listing already carries the ID, so no further lookup is needed
+                if id, err := p.zoneID(ctx, zone); err != nil || id != tt.id {
+                        t.Fatalf("zoneID(%s) = %q, %v; want %s", zone, id, err, tt.id)
+                }
+        }
+        _, err := p.DetectZone(ctx, "www.badexample.com")
+        if err == nil || !strings.Contains(err.Error(), "none of the 3 zones accessible with the API token owns 'www.badexample.com'") {
+                t.Fatalf("no-match error = %v", err)
+        }
+        if n := requests.Load(); n != 1 {
+                t.Fatalf("zones should be listed once, got %d requests", n)
+        }
+
+        p = newCloudflareProvider(Config{APIToken: "wrong", APIBaseURL: baseURL}, nil)
+        if _, err := p.DetectZone(ctx, "home.example.com"); err == nil || !strings.Contains(err.Error(), "check that 'api_token' is valid") {
+                t.Fatalf("authentication error = %v", err)
+        }
+}
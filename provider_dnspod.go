@@ -0,0 +1,277 @@
+package main
+
+import (
+        "bytes"
+        "context"
+        "crypto/hmac"
+        "crypto/sha256"
+        "encoding/hex"
+        "encoding/json"
+        "errors"
+        "fmt"
+        "io"
+        "log"
+        "net/http"
+        "net/url"
+        "strconv"
+        "strings"
+        "time"
+)
+
+// dnspodDefaultEndpoint 是 DNSPod (腾讯云) API 3.0 的默认地址
+const dnspodDefaultEndpoint = "https://dnspod.tencentcloudapi.com"
+
+// dnspodDefaultLine 是 DNSPod 的默认线路名称, 只管理该线路上的记录
+const dnspodDefaultLine = "默认"
+
+// DNSPodConfig 配置 DNSPod (腾讯云 API 3.0) 后端
+type DNSPodConfig struct {
+        SecretID  string `json:"secret_id"`
+        SecretKey string `json:"secret_key"`
+        Endpoint  string `json:"endpoint,omitempty"` // 默认 https://dnspod.tencentcloudapi.com, 可指向模拟服务器
+}
+
+// validate 检查 DNSPod 设置
+func (c *DNSPodConfig) validate() error {
+        if c == nil || c.SecretID == "" || c.SecretKey == "" {
+                return errors.New("missing required fields 'secret_id' and 'secret_key'")
+        }
+        return validateEndpoint(c.Endpoint)
+}
+
+// dnspodProvider 通过腾讯云 API 3.0 管理记录, 请求使用 TC3-HMAC-SHA256 签名
+type dnspodProvider struct {
+        endpoint string
+        host     string // 参与签名的 Host 头
+        secretID string
+        key      string
+        client   *http.Client
+        now      func() time.Time // 签名时间, 测试时可替换
+}
+
+// newDNSPodProvider 根据配置创建 DNSPod 后端
+func newDNSPodProvider(config *DNSPodConfig) (*dnspodProvider, error) {
+        if err := config.validate(); err != nil {
+                return nil, fmt.Errorf("invalid 'dnspod': %w", err)
+        }
+        endpoint := config.Endpoint
+        if endpoint == "" {
+                endpoint = dnspodDefaultEndpoint
+        }
+        u, _ := url.Parse(endpoint)
+        return &dnspodProvider{
+                endpoint: strings.TrimSuffix(endpoint, "/") + "/",
+                host:     u.Host,
+                secretID: config.SecretID,
+                key:      config.SecretKey,
+                client:   &http.Client{Timeout: 30 * time.Second},
+                now:      time.Now,
+        }, nil
+}
+
+func (p *dnspodProvider) Name() string {
+        return "DNSPod API"
+}
+
+// dnspodRecord 是 DescribeRecordList 返回的一条记录
+type dnspodRecord struct {
+        RecordID uint64 `json:"RecordId"`
+        Name     string `json:"Name"`
+        Type     string `json:"Type"`
+        Value    string `json:"Value"`
+        TTL      int    `json:"TTL"`
+        Line     string `json:"Line"`
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+        mac := hmac.New(sha256.New, key)
+        mac.Write([]byte(data))
+        return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+        sum := sha256.Sum256(data)
+        return hex.EncodeToString(sum[:])
+}
+
+// tc3Authorization 计算 TC3-HMAC-SHA256 签名, 返回 Authorization 头
+// 规范请求只签 content-type、host 和 x-tc-action 三个头; 派生密钥依次对日期、服务名和 "tc3_request" 做 HMAC
+func tc3Authorization(secretID, secretKey, service, host, action string, payload []byte, t time.Time) string {
+        const signedHeaders = "content-type;host;x-tc-action"
+        canonicalRequest := strings.Join([]string{
+                http.MethodPost,
+                "/",
+                "",
+                "content-type:application/json; charset=utf-8\nhost:" + host + "\nx-tc-action:" + strings.ToLower(action) + "\n",
+                signedHeaders,
+                sha256Hex(payload),
+        }, "\n")
+
+        date := t.UTC().Format("2006-01-02")
+        scope := date + "/" + service + "/tc3_request"
+        stringToSign := "TC3-HMAC-SHA256\n" + strconv.FormatInt(t.Unix(), 10) + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+        secretDate := hmacSHA256([]byte("TC3"+secretKey), date)
+        secretService := hmacSHA256(secretDate, service)
+        secretSigning := hmacSHA256(secretService, "tc3_request")
+        signature := hex.EncodeToString(hmacSHA256(secretSigning, stringToSign))
+
+        return "TC3-HMAC-SHA256 Credential=" + secretID + "/" + scope + ", SignedHeaders=" + signedHeaders + ", Signature=" + signature
+}
+
+// dnspodError 是 API 返回的错误; 无论成功失败 HTTP 状态都是 200, 错误放在 Response.Error 中
+type dnspodError struct {
+        Code    string `json:"Code"`
+        Message string `json:"Message"`
+}
+
+// call 发送一个签名的 API 请求, 成功时把 Response 解析到 out
+func (p *dnspodProvider) call(ctx context.Context, action string, params interface{}, out interface{}) error {
+        payload, err := json.Marshal(params)
+        if err != nil {
+                return fmt.Errorf("encoding request failed: %w", err)
+        }
+        now := p.now()
+        req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(payload))
+        if err != nil {
+                return fmt.Errorf("creating request failed: %w", err)
+        }
+        req.Header.Set("Content-Type", "application/json; charset=utf-8")
+        req.Header.Set("X-TC-Action", action)
+        req.Header.Set("X-TC-Version", "2021-03-23")
+        req.Header.Set("X-TC-Timestamp", strconv.FormatInt(now.Unix(), 10))
+        req.Header.Set("Authorization", tc3Authorization(p.secretID, p.key, "dnspod", p.host, action, payload, now))
+
+        resp, err := p.client.Do(req)
+        if err != nil {
+                return fmt.Errorf("%s request failed: %w", action, err)
+        }
+        defer resp.Body.Close()
+        body, err := io.ReadAll(resp.Body)
+        if err != nil {
+                return fmt.Errorf("reading %s response failed (status: %s): %w", action, resp.Status, err)
+        }
+        if resp.StatusCode != http.StatusOK {
+                return fmt.Errorf("%s failed: %s: %s", action, resp.Status, strings.TrimSpace(string(body)))
+        }
+
+        var envelope struct {
+                Response json.RawMessage `json:"Response"`
+        }
+        var apiErr struct {
+                Error *dnspodError `json:"Error"`
+        }
+        if err := json.Unmarshal(body, &envelope); err != nil || envelope.Response == nil {
+                return fmt.Errorf("parsing %s response failed: unexpected body %s", action, strings.TrimSpace(string(body)))
+        }
+        if err := json.Unmarshal(envelope.Response, &apiErr); err == nil && apiErr.Error != nil {
+                return &dnspodAPIError{Action: action, dnspodError: *apiErr.Error}
+        }
+        if err := json.Unmarshal(envelope.Response, out); err != nil {
+                return fmt.Errorf("parsing %s response failed: %w", action, err)
+        }
+        return nil
+}
+
+// dnspodAPIError 带有错误码, 调用方据此区分 "记录不存在" 等情况
+type dnspodAPIError struct {
+        Action string
+        dnspodError
+}
+
+func (e *dnspodAPIError) Error() string {
+        msg := fmt.Sprintf("%s failed: %s: %s", e.Action, e.Code, e.Message)
+        if strings.HasPrefix(e.Code, "AuthFailure") {
+                msg += " (check 'secret_id', 'secret_key' and the QcloudDNSPodFullAccess permission)"
+        }
+        return msg
+}
+
+// Is 让记录已存在的错误可以用 errors.Is(err, errRecordExists) 判断
+func (e *dnspodAPIError) Is(target error) bool {
+        return target == errRecordExists && e.Code == "InvalidParameter.DomainRecordExist"
+}
+
+// Lookup 通过 DescribeRecordList 查询默认线路上的记录
+func (p *dnspodProvider) Lookup(ctx context.Context, zone, fqdn, recordType string) (*Record, error) {
+        var result struct {
+                RecordList []dnspodRecord `json:"RecordList"`
+        }
+        params := map[string]interface{}{
+                "Domain":     zone,
+                "Subdomain":  relativeName(fqdn, zone),
+                "RecordType": recordType,
+                "Limit":      100,
+        }
+        nowStr := time.Now().Format("2006-01-02 15:04:05")
+        err := p.call(ctx, "DescribeRecordList", params, &result)
+        var apiErr *dnspodAPIError
+        if errors.As(err, &apiErr) && apiErr.Code == "ResourceNotFound.NoDataOfRecord" {
+                log.Printf("[%s] ℹ️ No existing %s record found for %s via API.", nowStr, recordType, fqdn)
+                return nil, nil
+        }
+        if err != nil {
+                return nil, fmt.Errorf("requesting DNS record %s (%s) failed: %w", fqdn, recordType, err)
+        }
+
+        var records []dnspodRecord
+        for _, r := range result.RecordList {
+                if r.Type == recordType && r.Line == dnspodDefaultLine && strings.EqualFold(r.Name, relativeName(fqdn, zone)) {
+                        records = append(records, r)
+                }
+        }
+        if len(records) == 0 {
+                log.Printf("[%s] ℹ️ No existing %s record found for %s via API.", nowStr, recordType, fqdn)
+                return nil, nil
+        }
+        id := strconv.FormatUint(records[0].RecordID, 10)
+        log.Printf("[%s] ℹ️ Found existing %s record for %s via API (ID: %s, IP: %s).", nowStr, recordType, fqdn, id, records[0].Value)
+        if len(records) > 1 {
+                log.Printf("[%s] ⚠️ Warning: Found multiple %s records for %s. Using the first one (ID: %s).", nowStr, recordType, fqdn, id)
+        }
+        return &Record{ID: id, Type: recordType, Name: fqdn, Content: records[0].Value, TTL: records[0].TTL}, nil
+}
+
+func (p *dnspodProvider) Create(ctx context.Context, zone string, record Record) (*Record, error) {
+        var result struct {
+                RecordID uint64 `json:"RecordId"`
+        }
+        params := map[string]interface{}{
+                "Domain":     zone,
+                "SubDomain":  relativeName(record.Name, zone),
+                "RecordType": record.Type,
+                "RecordLine": dnspodDefaultLine,
+                "Value":      record.Content,
+                "TTL":        record.TTL,
+        }
+        if err := p.call(ctx, "CreateRecord", params, &result); err != nil {
+                return nil, err
+        }
+        record.ID = strconv.FormatUint(result.RecordID, 10)
+        return &record, nil
+}
+
+// Update 通过 ModifyRecord 修改记录
+func (p *dnspodProvider) Update(ctx context.Context, zone string, existing *Record, record Record) (*Record, error) {
+        id, err := strconv.ParseUint(existing.ID, 10, 64)
+        if err != nil {
+                return nil, fmt.Errorf("invalid DNSPod record ID '%s'", existing.ID)
+        }
+        var result struct {
+                RecordID uint64 `json:"RecordId"`
+        }
+        params := map[string]interface{}{
+                "Domain":     zone,
+                "RecordId":   id,
+                "SubDomain":  relativeName(record.Name, zone),
+                "RecordType": record.Type,
+                "RecordLine": dnspodDefaultLine,
+                "Value":      record.Content,
+                "TTL":        record.TTL,
+        }
+        if err := p.call(ctx, "ModifyRecord", params, &result); err != nil {
+                return nil, err
+        }
+        record.ID = existing.ID
+        return &record, nil
+}
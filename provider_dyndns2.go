@@ -0,0 +1,314 @@
+package main
+
+import (
+        "context"
+        "crypto/sha256"
+        "encoding/hex"
+        "errors"
+        "fmt"
+        "io"
+        "log"
+        "net/http"
+        "net/url"
+        "os"
+        "path/filepath"
+        "sort"
+        "strings"
+        "time"
+)
+
+// dyndns2 协议 (GET /nic/update?hostname=...&myip=..., Basic 认证), DynDNS、No-IP、OVH 等服务商和很多路由器都支持
+
+const (
+        dyndns2HoldAbuse  = 24 * time.Hour   // 返回 abuse (主机因更新过于频繁被封禁) 后暂停更新的时间
+        dyndns2HoldServer = 30 * time.Minute // 返回 911 / dnserr (服务器故障或维护) 后暂停更新的时间, 协议要求至少 30 分钟
+)
+
+// dyndns2Errors 说明 dyndns2 的错误返回码; 除 abuse、911 和 dnserr 外都需要修改配置或账号后才能恢复
+var dyndns2Errors = map[string]string{
+        "badauth":  "invalid username or password",
+        "badagent": "the client was blocked by the server",
+        "!donator": "the requested feature needs a paid account",
+        "notfqdn":  "the hostname is not a fully qualified domain name",
+        "nohost":   "the hostname does not exist in this account",
+        "numhost":  "too many hostnames in one request",
+        "!yours":   "the hostname belongs to another account",
+        "abuse":    "the hostname is blocked for update abuse",
+        "dnserr":   "DNS error on the server side",
+        "911":      "server-side problem or scheduled maintenance",
+}
+
+// dyndns2AccountCodes 是与主机名无关、暂停整个账号的返回码; 其他返回码只暂停出错的主机名
+var dyndns2AccountCodes = map[string]bool{"badauth": true, "badagent": true, "!donator": true, "911": true, "dnserr": true}
+
+// dyndns2HoldAll 是账号级暂停在 holds 中的键
+const dyndns2HoldAll = "*"
+
+// DynDNS2Config 配置通用的 dyndns2 协议后端
+type DynDNS2Config struct {
+        Server   string `json:"server"` // 更新地址, 如 "https://members.dyndns.org"; 省略路径时使用 /nic/update, 可附带固定的查询参数
+        Username string `json:"username"`
+        Password string `json:"password"`
+}
+
+// validate 检查 dyndns2 设置
+func (c *DynDNS2Config) validate() error {
+        if c == nil || c.Server == "" {
+                return errors.New("missing required field 'server'")
+        }
+        if u, err := url.Parse(c.Server); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+                return fmt.Errorf("invalid 'server' ('%s'), must be an http(s) URL", c.Server)
+        }
+        if c.Username == "" || c.Password == "" {
+                return errors.New("missing required fields 'username' and 'password'")
+        }
+        return nil
+}
+
+// dyndns2Provider 通过 dyndns2 协议更新主机记录
+// 协议无法读取记录, 因此依赖 .lastip 缓存避免重复更新 (重复的 nochg 更新可能被服务商视为滥用)
+type dyndns2Provider struct {
+        server     *url.URL
+        username   string
+        password   string
+        client     *http.Client
+        now        func() time.Time
+        holdPath   string                 // 暂停状态文件, 使暂停在多次运行 (如 cron) 之间保持
+        configHash string                 // dyndns2 设置的摘要, 设置变化后旧的暂停状态失效
+        holds      map[string]dyndns2Hold // 主机名 (或 dyndns2HoldAll) -> 暂停状态
+}
+
+// dyndns2Hold 是一个暂停状态; Until 为零表示一直暂停, 直到设置变化或状态文件被删除
+type dyndns2Hold struct {
+        Code  string
+        Until time.Time
+}
+
+// newDynDNS2Provider 根据配置创建 dyndns2 后端; holdPath 为暂停状态文件的路径
+func newDynDNS2Provider(config *DynDNS2Config, holdPath string) (*dyndns2Provider, error) {
+        if err := config.validate(); err != nil {
+                return nil, fmt.Errorf("invalid 'dyndns2': %w", err)
+        }
+        server, _ := url.Parse(config.Server)
+        if server.Path == "" || server.Path == "/" {
+                server.Path = "/nic/update"
+        }
+        sum := sha256.Sum256([]byte(server.String() + "\n" + config.Username + "\n" + config.Password))
+        p := &dyndns2Provider{
+                server:     server,
+                username:   config.Username,
+                password:   config.Password,
+                client:     &http.Client{Timeout: 30 * time.Second},
+                now:        time.Now,
+                holdPath:   holdPath,
+                configHash: hex.EncodeToString(sum[:8]),
+        }
+        p.loadHolds()
+        nowStr := time.Now().Format("2006-01-02 15:04:05")
+        for name, h := range p.holds {
+                if h.Until.IsZero() {
+                        log.Printf("[%s] ⚠️ Warning: dyndns2 updates for %s are paused until the configuration changes (server returned '%s').", nowStr, name, h.Code)
+                } else if p.now().Before(h.Until) {
+                        log.Printf("[%s] ⚠️ Warning: dyndns2 updates for %s are paused until %s (server returned '%s').", nowStr, name, h.Until.Format("2006-01-02 15:04:05"), h.Code)
+                }
+        }
+        return p, nil
+}
+
+func (p *dyndns2Provider) Name() string {
+        return "dyndns2 server " + p.server.Host
+}
+
+// DetectZone 返回主机名本身: dyndns2 按主机名更新, 没有区域的概念
+func (p *dyndns2Provider) DetectZone(ctx context.Context, fqdn string) (string, error) {
+        return fqdn, nil
+}
+
+// Lookup 总是返回 nil: 协议无法读取当前记录, 每次 IP 变化都发送一次更新
+func (p *dyndns2Provider) Lookup(ctx context.Context, zone, fqdn, recordType string) (*Record, error) {
+        log.Printf("[%s] ℹ️ The dyndns2 protocol cannot read records; sending an update for %s (%s).", time.Now().Format("2006-01-02 15:04:05"), fqdn, recordType)
+        return nil, nil
+}
+
+// Create 与 Update 相同: 发送一次更新请求
+func (p *dyndns2Provider) Create(ctx context.Context, zone string, record Record) (*Record, error) {
+        return p.Update(ctx, zone, nil, record)
+}
+
+func (p *dyndns2Provider) Update(ctx context.Context, zone string, existing *Record, record Record) (*Record, error) {
+        // Re-read the hold file so that deleting it also releases a running daemon
+        p.loadHolds()
+        if name, h, ok := p.activeHold(record.Name); ok {
+                if h.Until.IsZero() {
+                        return nil, fmt.Errorf("not sending updates for %s because the server returned '%s' (%s); fix the 'dyndns2' settings or the account, or remove '%s' to retry",
+                                name, h.Code, dyndns2Errors[h.Code], p.holdPath)
+                }
+                return nil, fmt.Errorf("not sending updates for %s until %s because the server returned '%s' (%s); remove '%s' to retry earlier",
+                        name, h.Until.Format("2006-01-02 15:04:05"), h.Code, dyndns2Errors[h.Code], p.holdPath)
+        }
+
+        u := *p.server
+        query := u.Query()
+        query.Set("hostname", record.Name)
+        query.Set("myip", record.Content)
+        u.RawQuery = query.Encode()
+        req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+        if err != nil {
+                return nil, fmt.Errorf("creating request failed: %w", err)
+        }
+        req.SetBasicAuth(p.username, p.password)
+        req.Header.Set("User-Agent", "cloudflare-ddns")
+
+        resp, err := p.client.Do(req)
+        if err != nil {
+                return nil, fmt.Errorf("update request failed: %w", err)
+        }
+        defer resp.Body.Close()
+        body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+        if err != nil {
+                return nil, fmt.Errorf("reading update response failed (status: %s): %w", resp.Status, err)
+        }
+
+        // The body has one line per hostname, e.g. "good 203.0.113.5"; only one hostname is sent
+        line, _, _ := strings.Cut(strings.TrimSpace(string(body)), "\n")
+        code, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+        switch code {
+        case "good", "nochg":
+                if code == "nochg" {
+                        log.Printf("[%s] ℹ️ Server reported no change for %s (%s).", time.Now().Format("2006-01-02 15:04:05"), record.Name, line)
+                }
+                p.clearHold(record.Name)
+                return &record, nil
+        case "abuse":
+                p.hold(record.Name, code, dyndns2HoldAbuse)
+        case "911", "dnserr":
+                p.hold(record.Name, code, dyndns2HoldServer)
+        case "badauth", "badagent", "!donator", "notfqdn", "nohost", "numhost", "!yours":
+                p.hold(record.Name, code, 0) // Retrying cannot help until the settings or the account change
+        }
+        if msg, ok := dyndns2Errors[code]; ok {
+                return nil, fmt.Errorf("server returned '%s': %s", line, msg)
+        }
+        return nil, fmt.Errorf("unexpected response (status: %s): %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// activeHold 返回对主机名生效的暂停状态 (账号级暂停优先) 及其键
+func (p *dyndns2Provider) activeHold(hostname string) (string, dyndns2Hold, bool) {
+        for _, name := range []string{dyndns2HoldAll, strings.ToLower(hostname)} {
+                if h, ok := p.holds[name]; ok && (h.Until.IsZero() || p.now().Before(h.Until)) {
+                        return name, h, true
+                }
+        }
+        return "", dyndns2Hold{}, false
+}
+
+// hold 暂停主机名 (账号级返回码为全部主机名) 的更新, d 为零时一直暂停, 并写入状态文件
+func (p *dyndns2Provider) hold(hostname, code string, d time.Duration) {
+        name, h := strings.ToLower(hostname), dyndns2Hold{Code: code}
+        if dyndns2AccountCodes[code] {
+                name = dyndns2HoldAll
+        }
+        nowStr := time.Now().Format("2006-01-02 15:04:05")
+        if d > 0 {
+                h.Until = p.now().Add(d)
+                log.Printf("[%s] ⚠️ Warning: Server returned '%s'; pausing dyndns2 updates for %s until %s.", nowStr, code, name, h.Until.Format("2006-01-02 15:04:05"))
+        } else {
+                log.Printf("[%s] ⚠️ Warning: Server returned '%s'; pausing dyndns2 updates for %s until the configuration changes or '%s' is removed.", nowStr, code, name, p.holdPath)
+        }
+        if p.holds == nil {
+                p.holds = map[string]dyndns2Hold{}
+        }
+        p.holds[name] = h
+        p.saveHolds()
+}
+
+// clearHold 在更新成功后删除主机名的暂停状态; 成功也说明账号可用, 已过期的账号级暂停一并删除
+func (p *dyndns2Provider) clearHold(hostname string) {
+        if len(p.holds) == 0 {
+                return
+        }
+        delete(p.holds, strings.ToLower(hostname))
+        delete(p.holds, dyndns2HoldAll)
+        p.saveHolds()
+}
+
+// saveHolds 写入状态文件, 没有暂停时删除它
+// 第一行为 "config <设置摘要>", 之后每行为 "<主机名或 *> <返回码> <RFC 3339 时间或 ->"
+func (p *dyndns2Provider) saveHolds() {
+        if p.holdPath == "" {
+                return
+        }
+        if len(p.holds) == 0 {
+                os.Remove(p.holdPath)
+                return
+        }
+        names := make([]string, 0, len(p.holds))
+        for name := range p.holds {
+                names = append(names, name)
+        }
+        sort.Strings(names)
+        var b strings.Builder
+        b.WriteString("config " + p.configHash + "\n")
+        for _, name := range names {
+                until := "-"
+                if h := p.holds[name]; !h.Until.IsZero() {
+                        until = h.Until.Format(time.RFC3339)
+                }
+                b.WriteString(name + " " + p.holds[name].Code + " " + until + "\n")
+        }
+        err := os.MkdirAll(filepath.Dir(p.holdPath), 0750)
+        if err == nil {
+                err = os.WriteFile(p.holdPath, []byte(b.String()), 0600)
+        }
+        if err != nil {
+                log.Printf("[%s] ⚠️ Warning: Could not write dyndns2 hold file '%s': %v", time.Now().Format("2006-01-02 15:04:05"), p.holdPath, err)
+        }
+}
+
+// loadHolds 读取状态文件; 文件不存在表示没有暂停, 设置摘要不一致时删除文件
+// 旧版本的文件只有一行 "<RFC 3339 时间> <返回码>", 视为账号级暂停
+func (p *dyndns2Provider) loadHolds() {
+        p.holds = nil
+        if p.holdPath == "" {
+                return
+        }
+        content, err := os.ReadFile(p.holdPath)
+        if err != nil {
+                return
+        }
+        nowStr := time.Now().Format("2006-01-02 15:04:05")
+        lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+        if until, code, ok := strings.Cut(lines[0], " "); ok && len(lines) == 1 {
+                if t, err := time.Parse(time.RFC3339, until); err == nil {
+                        p.holds = map[string]dyndns2Hold{dyndns2HoldAll: {Code: code, Until: t}}
+                        return
+                }
+        }
+        hash, ok := strings.CutPrefix(lines[0], "config ")
+        if !ok {
+                log.Printf("[%s] ⚠️ Warning: Ignoring invalid dyndns2 hold file '%s'", nowStr, p.holdPath)
+                return
+        }
+        if hash != p.configHash {
+                log.Printf("[%s] ℹ️ The 'dyndns2' settings changed; removing the hold file '%s'.", nowStr, p.holdPath)
+                os.Remove(p.holdPath)
+                return
+        }
+        holds := map[string]dyndns2Hold{}
+        for _, line := range lines[1:] {
+                fields := strings.Fields(line)
+                if len(fields) != 3 {
+                        log.Printf("[%s] ⚠️ Warning: Ignoring invalid line '%s' in dyndns2 hold file '%s'", nowStr, line, p.holdPath)
+                        continue
+                }
+                h := dyndns2Hold{Code: fields[1]}
+                if fields[2] != "-" {
+                        if h.Until, err = time.Parse(time.RFC3339, fields[2]); err != nil {
+                                log.Printf("[%s] ⚠️ Warning: Ignoring invalid line '%s' in dyndns2 hold file '%s'", nowStr, line, p.holdPath)
+                                continue
+                        }
+                }
+                holds[fields[0]] = h
+        }
+        p.holds = holds
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+        "context"
+        "io"
+        "net/http"
+        "net/http/httptest"
+        "os"
+        "path/filepath"
+        "strings"
+        "sync"
+        "testing"
+        "time"
+)
+
+// dyndns2Server 是测试用的 /nic/update 服务器, replies 为主机名 -> 返回内容 (默认 "good <IP>")
+type dyndns2Server struct {
+        mu       sync.Mutex
+        replies  map[string]string
+        requests []string // "主机名 IP"
+}
+
+func startDynDNS2Server(t *testing.T) (*dyndns2Server, *httptest.Server) {
+        s := &dyndns2Server{replies: map[string]string{}}
+        srv := httptest.NewServer(s)
+        t.Cleanup(srv.Close)
+        return s, srv
+}
+
+func (s *dyndns2Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+                w.WriteHeader(http.StatusUnauthorized)
+                io.WriteString(w, "badauth")
+                return
+        }
+        q := r.URL.Query()
+        if r.URL.Path != "/nic/update" || r.Header.Get("User-Agent") != "cloudflare-ddns" {
+                w.WriteHeader(http.StatusBadRequest)
+                return
+        }
+        s.requests = append(s.requests, q.Get("hostname")+" "+q.Get("myip"))
+        if reply, ok := s.replies[q.Get("hostname")]; ok {
+                io.WriteString(w, reply)
+                return
+        }
+        io.WriteString(w, "good "+q.Get("myip")+"\n")
+}
+
+// reply 设置主机名的返回内容, 返回此前收到的请求数
+func (s *dyndns2Server) reply(hostname, body string) int {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        s.replies[hostname] = body
+        return len(s.requests)
+}
+
+func (s *dyndns2Server) count() int {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        return len(s.requests)
+}
+
+func (s *dyndns2Server) request(i int) string {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        if i >= len(s.requests) {
+                return ""
+        }
+        return s.requests[i]
+}
+
+func newTestDynDNS2(t *testing.T, srv *httptest.Server, password, holdPath string, now *time.Time) *dyndns2Provider {
+        t.Helper()
+        p, err := newDynDNS2Provider(&DynDNS2Config{Server: srv.URL, Username: "user", Password: password}, holdPath)
+        if err != nil {
+                t.Fatal(err)
+        }
+        p.now = func() time.Time { return *now }
+        return p
+}
+
+// updateHost 为主机名发送一次更新
+func updateHost(p *dyndns2Provider, hostname string) error {
+        _, err := p.Update(context.Background(), hostname, nil, Record{Type: "A", Name: hostname, Content: "203.0.113.5"})
+        return err
+}
+
+func TestDynDNS2Provider(t *testing.T) {
+        s, srv := startDynDNS2Server(t)
+        now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+        p := newTestDynDNS2(t, srv, "pass", filepath.Join(t.TempDir(), "config.json.dyndns2.hold"), &now)
+        ctx := context.Background()
+
+        target := recordTarget{FQDN: "home.example.org", Type: "A", TTL: 300, Provider: providerDynDNS2}
+        zone, _ := p.DetectZone(ctx, target.FQDN)
+        rec, ok := upsertRecord(ctx, p, zone, target, "203.0.113.5", nil)
+        if !ok || rec == nil || s.request(0) != "home.example.org 203.0.113.5" {
+                t.Fatalf("update: %+v, %v, first request %q", rec, ok, s.request(0))
+        }
+        // 有缓存的状态时不发送请求
+        if _, ok := upsertRecord(ctx, p, zone, target, "203.0.113.5", rec); !ok || s.count() != 1 {
+                t.Fatalf("cached record sent %d requests", s.count())
+        }
+        s.reply("home.example.org", "nochg 203.0.113.5")
+        if err := updateHost(p, "home.example.org"); err != nil {
+                t.Fatalf("nochg should count as success: %v", err)
+        }
+        s.reply("home.example.org", "<html>Service Unavailable</html>")
+        if err := updateHost(p, "home.example.org"); err == nil || !strings.Contains(err.Error(), "unexpected response") {
+                t.Fatalf("unexpected response error = %v", err)
+        }
+}
+
+func TestDynDNS2HoldPerHostname(t *testing.T) {
+        s, srv := startDynDNS2Server(t)
+        holdPath := filepath.Join(t.TempDir(), "state", "config.json.dyndns2.hold")
+        now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+        p := newTestDynDNS2(t, srv, "pass", holdPath, &now)
+
+        n := s.reply("home.example.org", "nohost")
+        if err := updateHost(p, "home.example.org"); err == nil || !strings.Contains(err.Error(), "does not exist") {
+                t.Fatalf("nohost error = %v", err)
+        }
+        // 配置错误的暂停不会过期, 也不影响其他主机名
+        now = now.Add(30 * 24 * time.Hour)
+        err := updateHost(p, "home.example.org")
+        if err == nil || !strings.Contains(err.Error(), "not sending updates for home.example.org because the server returned 'nohost'") {
+                t.Fatalf("held update error = %v", err)
+        }
+        if err := updateHost(p, "nas.example.org"); err != nil {
+                t.Fatalf("other hostname must not be held: %v", err)
+        }
+        if got := s.count(); got != n+2 {
+                t.Fatalf("server got %d requests, want %d", got, n+2)
+        }
+
+        // 下一次运行 (如 cron) 读取状态文件, 仍然暂停
+        if err := updateHost(newTestDynDNS2(t, srv, "pass", holdPath, &now), "home.example.org"); err == nil || s.count() != n+2 {
+                t.Fatalf("hold must survive a restart: %v", err)
+        }
+        // 删除状态文件后正在运行的进程也会恢复
+        s.reply("home.example.org", "good 203.0.113.5")
+        if err := os.Remove(holdPath); err != nil {
+                t.Fatal(err)
+        }
+        if err := updateHost(p, "home.example.org"); err != nil || s.count() != n+3 {
+                t.Fatalf("update after removing the hold file: %v", err)
+        }
+}
+
+func TestDynDNS2HoldClearedByConfigChange(t *testing.T) {
+        s, srv := startDynDNS2Server(t)
+        holdPath := filepath.Join(t.TempDir(), "config.json.dyndns2.hold")
+        now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+        // badauth 暂停整个账号
+        p := newTestDynDNS2(t, srv, "wrong", holdPath, &now)
+        if err := updateHost(p, "home.example.org"); err == nil || !strings.Contains(err.Error(), "invalid username or password") {
+                t.Fatalf("badauth error = %v", err)
+        }
+        if err := updateHost(p, "nas.example.org"); err == nil || !strings.Contains(err.Error(), "not sending updates for *") {
+                t.Fatalf("badauth must hold every hostname: %v", err)
+        }
+        if s.count() != 0 {
+                t.Fatalf("server got %d accepted requests", s.count())
+        }
+
+        // 修正密码后状态文件失效并被删除
+        p = newTestDynDNS2(t, srv, "pass", holdPath, &now)
+        if err := updateHost(p, "home.example.org"); err != nil || s.count() != 1 {
+                t.Fatalf("update after fixing the password: %v", err)
+        }
+        if _, err := os.Stat(holdPath); !os.IsNotExist(err) {
+                t.Fatalf("hold file should be removed, stat error = %v", err)
+        }
+}
+
+func TestDynDNS2TimedHold(t *testing.T) {
+        s, srv := startDynDNS2Server(t)
+        holdPath := filepath.Join(t.TempDir(), "config.json.dyndns2.hold")
+        now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+        p := newTestDynDNS2(t, srv, "pass", holdPath, &now)
+
+        n := s.reply("home.example.org", "911")
+        updateHost(p, "home.example.org")
+        now = now.Add(29 * time.Minute)
+        if err := updateHost(p, "nas.example.org"); err == nil || !strings.Contains(err.Error(), "until 2026-01-01 00:30:00") {
+                t.Fatalf("911 must hold every hostname for 30 minutes: %v", err)
+        }
+        now = now.Add(2 * time.Minute)
+        s.reply("home.example.org", "good 203.0.113.5")
+        if err := updateHost(p, "home.example.org"); err != nil || s.count() != n+2 {
+                t.Fatalf("update after the hold expired: %v", err)
+        }
+        if _, err := os.Stat(holdPath); !os.IsNotExist(err) {
+                t.Fatal("a successful update should remove the hold file")
+        }
+
+        // abuse 只暂停该主机名 24 小时
+        s.reply("home.example.org", "abuse")
+        updateHost(p, "home.example.org")
+        if err := updateHost(p, "home.example.org"); err == nil || !strings.Contains(err.Error(), "until 2026-01-02 00:31:00") {
+                t.Fatalf("abuse hold error = %v", err)
+        }
+        if err := updateHost(p, "nas.example.org"); err != nil {
+                t.Fatalf("abuse must not hold other hostnames: %v", err)
+        }
+
+        // 旧版本的状态文件视为账号级暂停
+        if err := os.WriteFile(holdPath, []byte(now.Add(time.Hour).Format(time.RFC3339)+" abuse\n"), 0600); err != nil {
+                t.Fatal(err)
+        }
+        if err := updateHost(p, "nas.example.org"); err == nil || !strings.Contains(err.Error(), "not sending updates for *") {
+                t.Fatalf("old hold file error = %v", err)
+        }
+}
+
+// 一个主机名被暂停时, 其他主机名仍然写入 .lastip 缓存, 下次运行不会重复提交相同的 IP (会被视为 abuse)
+func TestDynDNS2HeldHostnameDoesNotResendOthers(t *testing.T) {
+        s, srv := startDynDNS2Server(t)
+        dir := t.TempDir()
+        configPath := filepath.Join(dir, "config.json")
+        holdPath := configPath + ".dyndns2.hold"
+        now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+        s.reply("gone.example.org", "nohost")
+
+        run := func() bool {
+                u := newTestUpdater(t, configPath, newTestDynDNS2(t, srv, "pass", holdPath, &now), "203.0.113.5",
+                        recordTarget{FQDN: "home.example.org", Zone: "home.example.org", Type: "A", TTL: 300},
+                        recordTarget{FQDN: "gone.example.org", Zone: "gone.example.org", Type: "A", TTL: 300})
+                return u.runOnce(context.Background())
+        }
+        if run() || s.count() != 2 {
+                t.Fatalf("first run: want a failure after 2 requests, got %d", s.count())
+        }
+        if run() || s.count() != 2 {
+                t.Fatalf("second run sent %d requests, want none", s.count()-2)
+        }
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+        "bytes"
+        "context"
+        "encoding/json"
+        "errors"
+        "fmt"
+        "io"
+        "log"
+        "net/http"
+        "net/url"
+        "strings"
+        "time"
+)
+
+// PowerDNSConfig 配置 PowerDNS 权威服务器的 HTTP API 后端
+type PowerDNSConfig struct {
+        URL      string `json:"url"`                 // API 地址, 如 "http://127.0.0.1:8081" (webserver-address/port)
+        APIKey   string `json:"api_key"`             // 服务器配置中的 api-key, 以 X-API-Key 头发送
+        ServerID string `json:"server_id,omitempty"` // 服务器 ID, 默认 "localhost"
+}
+
+// validate 检查 PowerDNS 设置
+func (c *PowerDNSConfig) validate() error {
+        if c == nil || c.URL == "" {
+                return errors.New("missing required field 'url'")
+        }
+        if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+                return fmt.Errorf("invalid 'url' ('%s'), must be an http(s) URL", c.URL)
+        }
+        if c.APIKey == "" {
+                return errors.New("missing required field 'api_key'")
+        }
+        return nil
+}
+
+// powerDNSProvider 通过 PowerDNS API 以 RRset 为单位读取和替换记录
+type powerDNSProvider struct {
+        baseURL string // .../api/v1/servers/{server_id}
+        apiKey  string
+        client  *http.Client
+        zones   map[string]string // 自动识别区域时列出的全部区域, 只列出一次
+}
+
+// newPowerDNSProvider 根据配置创建 PowerDNS 后端
+func newPowerDNSProvider(config *PowerDNSConfig) (*powerDNSProvider, error) {
+        if err := config.validate(); err != nil {
+                return nil, fmt.Errorf("invalid 'powerdns': %w", err)
+        }
+        serverID := config.ServerID
+        if serverID == "" {
+                serverID = "localhost"
+        }
+        return &powerDNSProvider{
+                baseURL: strings.TrimSuffix(config.URL, "/") + "/api/v1/servers/" + url.PathEscape(serverID),
+                apiKey:  config.APIKey,
+                client:  &http.Client{Timeout: 30 * time.Second},
+        }, nil
+}
+
+// pdnsRRset 是 API 中的一个 RRset; 名称都是以点结尾的完整域名
+type pdnsRRset struct {
+        Name       string       `json:"name"`
+        Type       string       `json:"type"`
+        TTL        int          `json:"ttl,omitempty"`
+        ChangeType string       `json:"changetype,omitempty"` // PATCH 时为 "REPLACE" 或 "DELETE"
+        Records    []pdnsRecord `json:"records"`
+}
+
+type pdnsRecord struct {
+        Content  string `json:"content"`
+        Disabled bool   `json:"disabled"`
+}
+
+// pdnsZone 是 API 返回的区域 (只包含用到的字段)
+type pdnsZone struct {
+        ID     string      `json:"id"`
+        Name   string      `json:"name"`
+        RRsets []pdnsRRset `json:"rrsets"`
+}
+
+func (p *powerDNSProvider) Name() string {
+        return "PowerDNS API"
+}
+
+// do 发送一个 API 请求, out 非 nil 时解析 JSON 响应
+// 错误响应的正文为 {"error": "..."}
+func (p *powerDNSProvider) do(ctx context.Context, method, path string, body, out interface{}) error {
+        var payload io.Reader
+        if body != nil {
+                data, err := json.Marshal(body)
+                if err != nil {
+                        return fmt.Errorf("encoding request failed: %w", err)
+                }
+                payload = bytes.NewReader(data)
+        }
+        req, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, payload)
+        if err != nil {
+                return fmt.Errorf("creating request failed: %w", err)
+        }
+        req.Header.Set("X-API-Key", p.apiKey)
+        req.Header.Set("Accept", "application/json")
+        if body != nil {
+                req.Header.Set("Content-Type", "application/json")
+        }
+
+        resp, err := p.client.Do(req)
+        if err != nil {
+                return fmt.Errorf("%s %s failed: %w", method, path, err)
+        }
+        defer resp.Body.Close()
+        respBody, err := io.ReadAll(resp.Body)
+        if err != nil {
+                return fmt.Errorf("reading response of %s %s failed (status: %s): %w", method, path, resp.Status, err)
+        }
+
+        if resp.StatusCode < 200 || resp.StatusCode > 299 {
+                var apiErr struct {
+                        Error string `json:"error"`
+                }
+                msg := strings.TrimSpace(string(respBody))
+                if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Error != "" {
+                        msg = apiErr.Error
+                }
+                if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+                        msg += " (check 'api_key' and that the API is enabled with 'api=yes')"
+                }
+                return fmt.Errorf("%s %s failed: %s: %s", method, path, resp.Status, msg)
+        }
+        if out != nil {
+                if err := json.Unmarshal(respBody, out); err != nil {
+                        return fmt.Errorf("parsing response of %s %s failed: %w", method, path, err)
+                }
+        }
+        return nil
+}
+
+// zonePath 返回区域的 API 路径; 区域 ID 即以点结尾的区域名
+func zonePath(zone string) string {
+        return "/zones/" + url.PathEscape(canonicalDNSName(zone))
+}
+
+// DetectZone 列出服务器上的全部区域, 取最长的后缀匹配
+func (p *powerDNSProvider) DetectZone(ctx context.Context, fqdn string) (string, error) {
+        if p.zones == nil {
+                var result []pdnsZone
+                if err := p.do(ctx, http.MethodGet, "/zones", nil, &result); err != nil {
+                        return "", fmt.Errorf("listing zones failed: %w", err)
+                }
+                p.zones = make(map[string]string, len(result))
+                for _, z := range result {
+                        p.zones[strings.ToLower(strings.TrimSuffix(z.Name, "."))] = z.ID
+                }
+        }
+        zone, ok := findOwningZone(fqdn, p.zones)
+        if !ok {
+                return "", fmt.Errorf("none of the %d zones on the PowerDNS server owns '%s'", len(p.zones), fqdn)
+        }
+        log.Printf("[%s] ✅ Detected zone %s for %s", time.Now().Format("2006-01-02 15:04:05"), zone, fqdn)
+        return zone, nil
+}
+
+// Lookup 读取区域中的 RRset (rrset_name/rrset_type 过滤需要 PowerDNS 4.5+, 旧版本会返回整个区域)
+func (p *powerDNSProvider) Lookup(ctx context.Context, zone, fqdn, recordType string) (*Record, error) {
+        query := url.Values{"rrset_name": {canonicalDNSName(fqdn)}, "rrset_type": {recordType}}
+        var z pdnsZone
+        if err := p.do(ctx, http.MethodGet, zonePath(zone)+"?"+query.Encode(), nil, &z); err != nil {
+                return nil, fmt.Errorf("requesting DNS record %s (%s) failed: %w", fqdn, recordType, err)
+        }
+
+        nowStr := time.Now().Format("2006-01-02 15:04:05")
+        for _, rrset := range z.RRsets {
+                if rrset.Type != recordType || canonicalDNSName(rrset.Name) != canonicalDNSName(fqdn) {
+                        continue
+                }
+                var enabled []pdnsRecord
+                for _, r := range rrset.Records {
+                        if !r.Disabled {
+                                enabled = append(enabled, r)
+                        }
+                }
+                if len(enabled) == 0 {
+                        break
+                }
+                log.Printf("[%s] ℹ️ Found existing %s record for %s via API (IP: %s).", nowStr, recordType, fqdn, enabled[0].Content)
+                if len(enabled) > 1 {
+                        // Update replaces the whole RRset, so the extra addresses are removed on the next change
+                        log.Printf("[%s] ⚠️ Warning: Found %d %s records for %s. Comparing against the first one (%s).",
+                                nowStr, len(enabled), recordType, fqdn, enabled[0].Content)
+                }
+                return &Record{Type: recordType, Name: fqdn, Content: enabled[0].Content, TTL: rrset.TTL}, nil
+        }
+        log.Printf("[%s] ℹ️ No existing %s record found for %s via API.", nowStr, recordType, fqdn)
+        return nil, nil
+}
+
+// Create 与 Update 相同: 用一条记录替换整个 RRset
+func (p *powerDNSProvider) Create(ctx context.Context, zone string, record Record) (*Record, error) {
+        return p.Update(ctx, zone, nil, record)
+}
+
+func (p *powerDNSProvider) Update(ctx context.Context, zone string, existing *Record, record Record) (*Record, error) {
+        rrset := pdnsRRset{
+                Name:       canonicalDNSName(record.Name),
+                Type:       record.Type,
+                TTL:        record.TTL,
+                ChangeType: "REPLACE",
+                Records:    []pdnsRecord{{Content: record.Content}},
+        }
+        if err := p.patch(ctx, zone, rrset); err != nil {
+                return nil, err
+        }
+        return &record, nil
+}
+
+// patch 通过 PATCH /zones/{zone} 提交一个 RRset 变更, 成功时服务器返回 204
+func (p *powerDNSProvider) patch(ctx context.Context, zone string, rrset pdnsRRset) error {
+        body := struct {
+                RRsets []pdnsRRset `json:"rrsets"`
+        }{[]pdnsRRset{rrset}}
+        if err := p.do(ctx, http.MethodPatch, zonePath(zone), body, nil); err != nil {
+                return fmt.Errorf("updating RRset %s (%s) in zone %s failed: %w", rrset.Name, rrset.Type, zone, err)
+        }
+        return nil
+}
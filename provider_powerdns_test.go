@@ -0,0 +1,159 @@
+package main
+
+import (
+        "context"
+        "encoding/json"
+        "net/http"
+        "net/http/httptest"
+        "strings"
+        "sync"
+        "testing"
+)
+
+// fakePowerDNS 是内存中的 PowerDNS API, 提供区域 example.org. 和 lab.example.org.
+type fakePowerDNS struct {
+        mu      sync.Mutex
+        rrsets  map[string]pdnsRRset // "名称/类型" -> RRset
+        patches int
+}
+
+func startFakePowerDNS(t *testing.T) (*fakePowerDNS, *httptest.Server) {
+        f := &fakePowerDNS{rrsets: map[string]pdnsRRset{}}
+        srv := httptest.NewServer(f)
+        t.Cleanup(srv.Close)
+        return f, srv
+}
+
+func (f *fakePowerDNS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+        f.mu.Lock()
+        defer f.mu.Unlock()
+
+        fail := func(status int, msg string) {
+                w.WriteHeader(status)
+                json.NewEncoder(w).Encode(map[string]string{"error": msg})
+        }
+        if r.Header.Get("X-API-Key") != "secret" {
+                fail(http.StatusUnauthorized, "Unauthorized")
+                return
+        }
+        const zones = "/api/v1/servers/localhost/zones"
+        switch {
+        case r.Method == "GET" && r.URL.Path == zones:
+                json.NewEncoder(w).Encode([]pdnsZone{{ID: "example.org.", Name: "example.org."}, {ID: "lab.example.org.", Name: "lab.example.org."}})
+        case r.Method == "GET" && r.URL.Path == zones+"/example.org.":
+                z := pdnsZone{ID: "example.org.", Name: "example.org."}
+                q := r.URL.Query()
+                if rrset, ok := f.rrsets[q.Get("rrset_name")+"/"+q.Get("rrset_type")]; ok {
+                        z.RRsets = append(z.RRsets, rrset)
+                }
+                json.NewEncoder(w).Encode(z)
+        case r.Method == "PATCH" && r.URL.Path == zones+"/example.org.":
+                if r.Header.Get("Content-Type") != "application/json" {
+                        fail(http.StatusUnsupportedMediaType, "Unsupported Media Type")
+                        return
+                }
+                var body struct {
+                        RRsets []pdnsRRset `json:"rrsets"`
+                }
+                if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+                        fail(http.StatusBadRequest, err.Error())
+                        return
+                }
+                for _, rrset := range body.RRsets {
+                        if !strings.HasSuffix(rrset.Name, ".") {
+                                fail(http.StatusUnprocessableEntity, "RRset "+rrset.Name+" IN "+rrset.Type+": Name is not canonical")
+                                return
+                        }
+                        key := rrset.Name + "/" + rrset.Type
+                        switch rrset.ChangeType {
+                        case "REPLACE":
+                                rrset.ChangeType = ""
+                                f.rrsets[key] = rrset
+                        default:
+                                fail(http.StatusUnprocessableEntity, "Changetype not understood")
+                                return
+                        }
+                }
+                f.patches++
+                w.WriteHeader(http.StatusNoContent)
+        default:
+                fail(http.StatusNotFound, "Could not find domain '"+strings.TrimPrefix(r.URL.Path, zones+"/")+"'")
+        }
+}
+
+// state 返回已处理的 PATCH 次数和指定 RRset
+func (f *fakePowerDNS) state(key string) (int, pdnsRRset, bool) {
+        f.mu.Lock()
+        defer f.mu.Unlock()
+        rrset, ok := f.rrsets[key]
+        return f.patches, rrset, ok
+}
+
+func TestPowerDNSProvider(t *testing.T) {
+        f, srv := startFakePowerDNS(t)
+        p, err := newPowerDNSProvider(&PowerDNSConfig{URL: srv.URL + "/", APIKey: "secret"})
+        if err != nil {
+                t.Fatal(err)
+        }
+        ctx := context.Background()
+
+        zone, err := p.DetectZone(ctx, "home.example.org")
+        if err != nil || zone != "example.org" {
+                t.Fatalf("DetectZone = %q, %v", zone, err)
+        }
+        if zone, err := p.DetectZone(ctx, "nas.lab.example.org"); err != nil || zone != "lab.example.org" {
+                t.Fatalf("DetectZone(nas.lab) = %q, %v", zone, err)
+        }
+        if _, err := p.DetectZone(ctx, "home.example.net"); err == nil {
+                t.Fatal("DetectZone should fail for a domain the server does not serve")
+        }
+
+        target := recordTarget{FQDN: "home.example.org", Type: "A", TTL: 300, Provider: providerPowerDNS}
+        if _, ok := upsertRecord(ctx, p, zone, target, "203.0.113.5", nil); !ok {
+                t.Fatal("creating the record failed")
+        }
+        rec, ok := upsertRecord(ctx, p, zone, target, "203.0.113.5", nil)
+        if patches, _, _ := f.state(""); !ok || rec == nil || rec.TTL != 300 || patches != 1 {
+                t.Fatalf("unchanged record: %+v, %v, %d patches; want no PATCH", rec, ok, patches)
+        }
+
+        // 只有 TTL 变化时也要更新
+        target.TTL = 60
+        if _, ok := upsertRecord(ctx, p, zone, target, "203.0.113.5", rec); !ok {
+                t.Fatal("updating the TTL failed")
+        }
+        patches, rrset, _ := f.state("home.example.org./A")
+        if patches != 2 || rrset.TTL != 60 || len(rrset.Records) != 1 || rrset.Records[0].Content != "203.0.113.5" {
+                t.Fatalf("after TTL change: %d patches, %+v", patches, rrset)
+        }
+
+        // 禁用的记录视为不存在
+        f.mu.Lock()
+        f.rrsets["home.example.org./AAAA"] = pdnsRRset{Name: "home.example.org.", Type: "AAAA", TTL: 60, Records: []pdnsRecord{{Content: "2001:db8::1", Disabled: true}}}
+        f.mu.Unlock()
+        if rec, err := p.Lookup(ctx, zone, "home.example.org", "AAAA"); rec != nil || err != nil {
+                t.Fatalf("Lookup(disabled AAAA) = %+v, %v", rec, err)
+        }
+}
+
+func TestPowerDNSProviderErrors(t *testing.T) {
+        _, srv := startFakePowerDNS(t)
+        ctx := context.Background()
+
+        p, _ := newPowerDNSProvider(&PowerDNSConfig{URL: srv.URL, APIKey: "wrong"})
+        _, err := p.Lookup(ctx, "example.org", "home.example.org", "A")
+        if err == nil || !strings.Contains(err.Error(), "401 Unauthorized: Unauthorized (check 'api_key'") {
+                t.Fatalf("bad key error = %v", err)
+        }
+        p, _ = newPowerDNSProvider(&PowerDNSConfig{URL: srv.URL, APIKey: "secret"})
+        _, err = p.Update(ctx, "example.net", nil, Record{Type: "A", Name: "home.example.net", Content: "192.0.2.1", TTL: 60})
+        if err == nil || !strings.Contains(err.Error(), "Could not find domain 'example.net.'") {
+                t.Fatalf("unknown zone error = %v", err)
+        }
+
+        for _, c := range []*PowerDNSConfig{nil, {URL: "ftp://pdns", APIKey: "k"}, {URL: "http://pdns:8081"}} {
+                if _, err := newPowerDNSProvider(c); err == nil {
+                        t.Errorf("newPowerDNSProvider(%+v) should fail", c)
+                }
+        }
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+        "context"
+        "errors"
+        "fmt"
+        "log"
+        "net"
+        "strings"
+        "time"
+)
+
+// rfc2136Timeout 是单次 DNS 查询或更新等待响应的时间
+const rfc2136Timeout = 10 * time.Second
+
+// RFC2136Config 配置 RFC 2136 DNS UPDATE 后端 (BIND, Knot 等权威服务器)
+type RFC2136Config struct {
+        Server        string `json:"server"`                   // 主服务器地址, "host" 或 "host:port" (端口默认 53)
+        TSIGKeyName   string `json:"tsig_key_name,omitempty"`  // TSIG 密钥名; 为空时发送不签名的更新
+        TSIGSecret    string `json:"tsig_secret,omitempty"`    // base64 编码的 TSIG 密钥
+        TSIGAlgorithm string `json:"tsig_algorithm,omitempty"` // 仅支持 "hmac-sha256" (默认)
+}
+
+// validate 检查 RFC 2136 设置
+func (c *RFC2136Config) validate() error {
+        if c == nil || c.Server == "" {
+                return errors.New("missing required field 'server'")
+        }
+        if alg := strings.ToLower(strings.TrimSuffix(c.TSIGAlgorithm, ".")); alg != "" && alg != "hmac-sha256" {
+                return fmt.Errorf("unsupported 'tsig_algorithm' ('%s'), only 'hmac-sha256' is supported", c.TSIGAlgorithm)
+        }
+        if (c.TSIGKeyName == "") != (c.TSIGSecret == "") {
+                return errors.New("'tsig_key_name' and 'tsig_secret' must be set together")
+        }
+        if c.TSIGKeyName != "" {
+                if _, err := newTSIGKey(c.TSIGKeyName, c.TSIGSecret); err != nil {
+                        return err
+                }
+        }
+        return nil
+}
+
+// rfc2136Provider 通过 DNS UPDATE 报文直接修改权威服务器上的区域, 用普通 DNS 查询读取记录
+type rfc2136Provider struct {
+        server string
+        key    *tsigKey // 为 nil 时不签名
+}
+
+// newRFC2136Provider 根据配置创建 RFC 2136 后端
+func newRFC2136Provider(config *RFC2136Config) (*rfc2136Provider, error) {
+        if err := config.validate(); err != nil {
+                return nil, fmt.Errorf("invalid 'rfc2136': %w", err)
+        }
+        p := &rfc2136Provider{server: config.Server}
+        if _, _, err := net.SplitHostPort(p.server); err != nil {
+                p.server = net.JoinHostPort(strings.Trim(p.server, "[]"), "53")
+        }
+        if config.TSIGKeyName != "" {
+                p.key, _ = newTSIGKey(config.TSIGKeyName, config.TSIGSecret)
+        }
+        return p, nil
+}
+
+func (p *rfc2136Provider) Name() string {
+        return "DNS server " + p.server
+}
+
+// exchange 签名 (配置了 TSIG 时) 并发送报文, 校验响应的签名后返回解析结果
+func (p *rfc2136Provider) exchange(ctx context.Context, m *dnsMessage) (*dnsMessage, error) {
+        msg, err := m.Pack()
+        if err != nil {
+                return nil, err
+        }
+        var requestMAC []byte
+        if p.key != nil {
+                if msg, requestMAC, err = p.key.sign(msg, time.Now()); err != nil {
+                        return nil, err
+                }
+        }
+
+        ctx, cancel := context.WithTimeout(ctx, rfc2136Timeout)
+        defer cancel()
+        raw, err := dnsExchange(ctx, "udp", p.server, msg)
+        if err != nil {
+                return nil, err
+        }
+        resp, err := parseDNSMessage(raw)
+        if err != nil {
+                return nil, fmt.Errorf("parsing response from %s failed: %w", p.server, err)
+        }
+        if p.key != nil {
+                if err := p.key.verify(raw, requestMAC, time.Now()); err != nil {
+                        return nil, fmt.Errorf("response from %s: %w", p.server, err)
+                }
+        }
+        return resp, nil
+}
+
+// DetectZone 通过查询 SOA 找到拥有该完整域名的区域: 名称本身是区域顶点时 SOA 在回答段,
+// 否则权威服务器会在授权段中附上所属区域的 SOA
+func (p *rfc2136Provider) DetectZone(ctx context.Context, fqdn string) (string, error) {
+        q := newDNSQuery(fqdn, dnsTypeSOA, dnsClassIN)
+        q.Flags = 0
+        resp, err := p.exchange(ctx, q)
+        if err != nil {
+                return "", err
+        }
+        if rcode := resp.Rcode(); rcode != 0 && rcode != 3 { // NXDOMAIN still carries the zone's SOA
+                return "", fmt.Errorf("SOA query for %s answered %s", fqdn, rcodeName(rcode))
+        }
+        for _, rr := range append(resp.Answers, resp.Authority...) {
+                if rr.Type == dnsTypeSOA {
+                        zone := strings.ToLower(strings.TrimSuffix(rr.Name, "."))
+                        log.Printf("[%s] ✅ Detected zone %s for %s", time.Now().Format("2006-01-02 15:04:05"), zone, fqdn)
+                        return zone, nil
+                }
+        }
+        return "", fmt.Errorf("%s is not authoritative for '%s'", p.server, fqdn)
+}
+
+// Lookup 直接向服务器查询记录, TTL 为服务器上配置的原始值
+func (p *rfc2136Provider) Lookup(ctx context.Context, zone, fqdn, recordType string) (*Record, error) {
+        qtype := dnsTypeA
+        if recordType == "AAAA" {
+                qtype = dnsTypeAAAA
+        }
+        q := newDNSQuery(fqdn, qtype, dnsClassIN)
+        q.Flags = 0 // Ask the authoritative server itself, no recursion
+        resp, err := p.exchange(ctx, q)
+        if err != nil {
+                return nil, fmt.Errorf("querying %s (%s) failed: %w", fqdn, recordType, err)
+        }
+        nowStr := time.Now().Format("2006-01-02 15:04:05")
+        switch resp.Rcode() {
+        case 0:
+        case 3: // NXDOMAIN
+                log.Printf("[%s] ℹ️ No existing %s record found for %s on %s.", nowStr, recordType, fqdn, p.server)
+                return nil, nil
+        default:
+                return nil, fmt.Errorf("querying %s (%s) failed: server answered %s", fqdn, recordType, rcodeName(resp.Rcode()))
+        }
+
+        var records []Record
+        for _, rr := range resp.Answers {
+                if rr.Type != qtype || !strings.EqualFold(strings.TrimSuffix(rr.Name, "."), fqdn) {
+                        continue // e.g. a CNAME chain
+                }
+                ip := net.IP(rr.Data)
+                if len(rr.Data) != net.IPv4len && len(rr.Data) != net.IPv6len {
+                        continue
+                }
+                records = append(records, Record{Type: recordType, Name: fqdn, Content: ip.String(), TTL: int(rr.TTL)})
+        }
+        if len(records) == 0 {
+                log.Printf("[%s] ℹ️ No existing %s record found for %s on %s.", nowStr, recordType, fqdn, p.server)
+                return nil, nil
+        }
+        log.Printf("[%s] ℹ️ Found existing %s record for %s on %s (IP: %s).", nowStr, recordType, fqdn, p.server, records[0].Content)
+        if len(records) > 1 {
+                // Update replaces the whole RRset, so the extra addresses are removed on the next change
+                log.Printf("[%s] ⚠️ Warning: Found %d %s records for %s. Comparing against the first one (%s).",
+                        nowStr, len(records), recordType, fqdn, records[0].Content)
+        }
+        return &records[0], nil
+}
+
+// Create 与 Update 相同: 删除同名同类型的 RRset 后添加新记录, 在一个 UPDATE 报文中原子完成
+func (p *rfc2136Provider) Create(ctx context.Context, zone string, record Record) (*Record, error) {
+        return p.Update(ctx, zone, nil, record)
+}
+
+func (p *rfc2136Provider) Update(ctx context.Context, zone string, existing *Record, record Record) (*Record, error) {
+        rr, err := addressRR(record)
+        if err != nil {
+                return nil, err
+        }
+        deleteRRset := dnsRR{Name: record.Name, Type: rr.Type, Class: dnsClassANY}
+        if err := p.update(ctx, zone, deleteRRset, rr); err != nil {
+                return nil, err
+        }
+        return &record, nil
+}
+
+// update 发送一个没有前提条件的 UPDATE 报文, updates 为 Update 段的记录
+func (p *rfc2136Provider) update(ctx context.Context, zone string, updates ...dnsRR) error {
+        m := &dnsMessage{
+                ID:        randomDNSID(),
+                Flags:     dnsOpcodeUpdate << 11,
+                Questions: []dnsQuestion{{Name: zone, Type: dnsTypeSOA, Class: dnsClassIN}},
+                Authority: updates,
+        }
+        resp, err := p.exchange(ctx, m)
+        if err != nil {
+                return fmt.Errorf("DNS UPDATE of zone %s failed: %w", zone, err)
+        }
+        switch rcode := resp.Rcode(); rcode {
+        case 0:
+                return nil
+        case 5, 9: // REFUSED, NOTAUTH
+                return fmt.Errorf("DNS UPDATE of zone %s failed: server answered %s (check the TSIG key and the zone's update policy)", zone, rcodeName(rcode))
+        default:
+                return fmt.Errorf("DNS UPDATE of zone %s failed: server answered %s", zone, rcodeName(rcode))
+        }
+}
+
+// addressRR 将 A/AAAA 记录编码为资源记录
+func addressRR(record Record) (dnsRR, error) {
+        ip := net.ParseIP(record.Content)
+        if ip == nil {
+                return dnsRR{}, fmt.Errorf("invalid IP address '%s'", record.Content)
+        }
+        rr := dnsRR{Name: record.Name, Type: dnsTypeA, Class: dnsClassIN, TTL: uint32(record.TTL), Data: ip.To4()}
+        if record.Type == "AAAA" {
+                rr.Type, rr.Data = dnsTypeAAAA, ip.To16()
+        }
+        if rr.Data == nil {
+                return dnsRR{}, fmt.Errorf("'%s' is not a valid %s address", record.Content, record.Type)
+        }
+        return rr, nil
+}
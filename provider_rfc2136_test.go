@@ -0,0 +1,215 @@
+package main
+
+import (
+        "context"
+        "encoding/base64"
+        "net"
+        "strings"
+        "sync"
+        "sync/atomic"
+        "testing"
+        "time"
+)
+
+// authServer 是测试用的进程内权威服务器, 只有一个区域, 接受 TSIG 签名的 DNS UPDATE
+type authServer struct {
+        addr    string
+        zone    string
+        keyName string
+        // badSig 为 true 时响应的签名被破坏
+        badSig atomic.Bool
+
+        mu      sync.Mutex
+        rrsets  map[string][]dnsRR // "名称/类型" -> 记录
+        updates int
+}
+
+func startAuthServer(t *testing.T, zone string) *authServer {
+        t.Helper()
+        pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+        if err != nil {
+                t.Fatal(err)
+        }
+        t.Cleanup(func() { pc.Close() })
+        s := &authServer{addr: pc.LocalAddr().String(), zone: zone, keyName: "ddns-key", rrsets: map[string][]dnsRR{}}
+        go func() {
+                buf := make([]byte, 65535)
+                for {
+                        n, from, err := pc.ReadFrom(buf)
+                        if err != nil {
+                                return
+                        }
+                        // 存储的记录引用请求报文, 不能与读缓冲区共用
+                        if out := s.respond(t, append([]byte(nil), buf[:n]...)); out != nil {
+                                pc.WriteTo(out, from)
+                        }
+                }
+        }()
+        return s
+}
+
+// rrsetKey 返回 rrsets 的键
+func rrsetKey(name string, rrType uint16) string {
+        return canonicalDNSName(name) + "/" + rcodeName(int(rrType))
+}
+
+func (s *authServer) respond(t *testing.T, raw []byte) []byte {
+        m, err := parseDNSMessage(raw)
+        if err != nil || len(m.Questions) != 1 {
+                t.Errorf("bad request: %v", err)
+                return nil
+        }
+        resp := &dnsMessage{ID: m.ID, Flags: dnsFlagQR | uint16(m.Opcode())<<11, Questions: m.Questions}
+        requestMAC, err := checkTestTSIG(s.keyName, testTSIGSecret, raw)
+        if err != nil {
+                t.Errorf("TSIG check failed: %v", err)
+                resp.Flags |= 9 // NOTAUTH
+                out, _ := resp.Pack()
+                return out
+        }
+
+        s.mu.Lock()
+        zoneName := canonicalDNSName(s.zone)
+        name := canonicalDNSName(m.Questions[0].Name)
+        soa := dnsRR{Name: zoneName, Type: dnsTypeSOA, Class: dnsClassIN, TTL: 3600, Data: make([]byte, 22)}
+        switch {
+        case m.Opcode() == dnsOpcodeUpdate && requestMAC == nil:
+                resp.Flags |= 5 // REFUSED: 区域只允许签名的更新
+        case m.Opcode() == dnsOpcodeUpdate:
+                s.updates++
+                for _, rr := range m.Authority {
+                        key := rrsetKey(rr.Name, rr.Type)
+                        switch rr.Class {
+                        case dnsClassANY:
+                                delete(s.rrsets, key)
+                        default:
+                                s.rrsets[key] = append(s.rrsets[key], rr)
+                        }
+                }
+        case name != zoneName && !strings.HasSuffix(name, "."+zoneName):
+                resp.Flags |= 5
+        case m.Questions[0].Type == dnsTypeSOA && name == zoneName:
+                resp.Answers = []dnsRR{soa}
+        default:
+                resp.Answers = s.rrsets[rrsetKey(name, m.Questions[0].Type)]
+                if len(resp.Answers) == 0 {
+                        resp.Authority = []dnsRR{soa}
+                }
+        }
+        s.mu.Unlock()
+
+        out, _ := resp.Pack()
+        if requestMAC != nil {
+                out = signTestResponse(s.keyName, testTSIGSecret, out, requestMAC, time.Now(), 0)
+                if s.badSig.Load() {
+                        out[len(out)-20] ^= 1 // 落在 MAC 中
+                }
+        }
+        return out
+}
+
+// state 返回已处理的 UPDATE 次数和指定 RRset 中的记录数
+func (s *authServer) state(name string, rrType uint16) (updates, records int) {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        return s.updates, len(s.rrsets[rrsetKey(name, rrType)])
+}
+
+func newTestRFC2136Provider(t *testing.T, s *authServer, signed bool) *rfc2136Provider {
+        t.Helper()
+        config := &RFC2136Config{Server: s.addr}
+        if signed {
+                config.TSIGKeyName, config.TSIGSecret = "DDNS-Key", base64.StdEncoding.EncodeToString(testTSIGSecret)
+        }
+        p, err := newRFC2136Provider(config)
+        if err != nil {
+                t.Fatal(err)
+        }
+        return p
+}
+
+func TestRFC2136Provider(t *testing.T) {
+        s := startAuthServer(t, "example.org")
+        p := newTestRFC2136Provider(t, s, true)
+        ctx := context.Background()
+
+        zone, err := p.DetectZone(ctx, "home.example.org")
+        if err != nil || zone != "example.org" {
+                t.Fatalf("DetectZone = %q, %v", zone, err)
+        }
+        target := recordTarget{FQDN: "home.example.org", Zone: zone, Type: "A", TTL: 300, Provider: providerRFC2136}
+        if rec, ok := upsertRecord(ctx, p, zone, target, "203.0.113.5", nil); !ok || rec == nil {
+                t.Fatal("creating the A record failed")
+        }
+        if _, ok := upsertRecord(ctx, p, zone, target, "203.0.113.5", nil); !ok {
+                t.Fatal("checking the unchanged record failed")
+        }
+        if updates, _ := s.state("", 0); updates != 1 {
+                t.Fatalf("unchanged record must not be updated (%d updates)", updates)
+        }
+        if _, ok := upsertRecord(ctx, p, zone, target, "203.0.113.9", nil); !ok {
+                t.Fatal("updating the A record failed")
+        }
+        got, err := p.Lookup(ctx, zone, "home.example.org", "A")
+        if err != nil || got == nil || got.Content != "203.0.113.9" || got.TTL != 300 {
+                t.Fatalf("Lookup = %+v, %v", got, err)
+        }
+        if updates, n := s.state("home.example.org", dnsTypeA); updates != 2 || n != 1 {
+                t.Fatalf("update must replace the RRset, got %d updates and %d records", updates, n)
+        }
+
+        v6 := target
+        v6.Type = "AAAA"
+        if _, ok := upsertRecord(ctx, p, zone, v6, "2001:db8::5", nil); !ok {
+                t.Fatal("creating the AAAA record failed")
+        }
+        got, err = p.Lookup(ctx, zone, "home.example.org", "AAAA")
+        if err != nil || got == nil || got.Content != "2001:db8::5" {
+                t.Fatalf("Lookup(AAAA) = %+v, %v", got, err)
+        }
+}
+
+func TestRFC2136ProviderErrors(t *testing.T) {
+        s := startAuthServer(t, "example.org")
+        ctx := context.Background()
+        record := Record{Type: "A", Name: "home.example.org", Content: "192.0.2.1", TTL: 60}
+
+        _, err := newTestRFC2136Provider(t, s, false).Update(ctx, "example.org", nil, record)
+        if err == nil || !strings.Contains(err.Error(), "server answered REFUSED") {
+                t.Fatalf("unsigned update error = %v", err)
+        }
+        if _, err := newTestRFC2136Provider(t, s, true).DetectZone(ctx, "home.example.net"); err == nil {
+                t.Fatal("DetectZone should fail outside the served zone")
+        }
+
+        s.badSig.Store(true)
+        _, err = newTestRFC2136Provider(t, s, true).Update(ctx, "example.org", nil, record)
+        if err == nil || !strings.Contains(err.Error(), "signature does not match") {
+                t.Fatalf("forged response error = %v", err)
+        }
+}
+
+func TestRFC2136Config(t *testing.T) {
+        for _, c := range []*RFC2136Config{
+                nil,
+                {},
+                {Server: "ns1", TSIGKeyName: "k"},
+                {Server: "ns1", TSIGKeyName: "k", TSIGSecret: "!!"},
+                {Server: "ns1", TSIGAlgorithm: "hmac-md5"},
+        } {
+                if c.validate() == nil {
+                        t.Errorf("%+v should fail", c)
+                }
+        }
+        for server, want := range map[string]string{
+                "ns1.example.org":      "ns1.example.org:53",
+                "ns1.example.org:5353": "ns1.example.org:5353",
+                "2001:db8::1":          "[2001:db8::1]:53",
+                "[2001:db8::1]:5353":   "[2001:db8::1]:5353",
+        } {
+                p, err := newRFC2136Provider(&RFC2136Config{Server: server})
+                if err != nil || p.server != want {
+                        t.Errorf("server %q = %q, %v; want %q", server, p.server, err, want)
+                }
+        }
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+        "bytes"
+        "context"
+        "encoding/xml"
+        "fmt"
+        "io"
+        "log"
+        "net/http"
+        "net/url"
+        "strings"
+        "time"
+)
+
+// route53DefaultEndpoint 是 Route 53 API 的全局地址, 签名使用 us-east-1 区域
+const route53DefaultEndpoint = "https://route53.amazonaws.com"
+
+// route53APIVersion 是请求路径和 XML 命名空间中的 API 版本
+const route53APIVersion = "2013-04-01"
+
+// Route53Config 配置 AWS Route 53 后端; 访问密钥可省略, 此时从环境变量或共享凭据文件读取
+type Route53Config struct {
+        AccessKeyID     string            `json:"access_key_id,omitempty"`
+        SecretAccessKey string            `json:"secret_access_key,omitempty"`
+        SessionToken    string            `json:"session_token,omitempty"`   // 临时凭据的会话令牌
+        Profile         string            `json:"profile,omitempty"`         // 共享凭据文件中的 profile, 默认 AWS_PROFILE 或 "default"
+        HostedZoneIDs   map[string]string `json:"hosted_zone_ids,omitempty"` // 区域名 -> 托管区域 ID, 省略时按名称查找公有托管区域
+        Region          string            `json:"region,omitempty"`          // 签名区域, 默认 us-east-1 (中国区为 cn-northwest-1)
+        Endpoint        string            `json:"endpoint,omitempty"`        // 默认 https://route53.amazonaws.com
+}
+
+// validate 检查 Route 53 设置并返回找到的访问密钥 (nil 表示全部使用默认值)
+func (c *Route53Config) validate() (awsCredentials, error) {
+        if c != nil {
+                if err := validateEndpoint(c.Endpoint); err != nil {
+                        return awsCredentials{}, err
+                }
+        }
+        return loadAWSCredentials(c)
+}
+
+// route53Provider 通过 Route 53 REST API 管理记录, 请求使用 SigV4 签名
+type route53Provider struct {
+        endpoint string
+        region   string
+        creds    awsCredentials
+        client   *http.Client
+        now      func() time.Time  // 签名时间, 测试时可替换
+        zoneIDs  map[string]string // 配置中指定的托管区域 ID
+        zones    map[string]string // 列出的全部公有托管区域, 只列出一次
+}
+
+// newRoute53Provider 根据配置创建 Route 53 后端
+func newRoute53Provider(config *Route53Config) (*route53Provider, error) {
+        creds, err := config.validate()
+        if err != nil {
+                return nil, fmt.Errorf("invalid 'route53': %w", err)
+        }
+        p := &route53Provider{
+                endpoint: route53DefaultEndpoint,
+                region:   "us-east-1",
+                creds:    creds,
+                client:   &http.Client{Timeout: 30 * time.Second},
+                now:      time.Now,
+                zoneIDs:  map[string]string{},
+        }
+        if config != nil {
+                if config.Endpoint != "" {
+                        p.endpoint = strings.TrimSuffix(config.Endpoint, "/")
+                }
+                if config.Region != "" {
+                        p.region = config.Region
+                }
+                for zone, id := range config.HostedZoneIDs {
+                        p.zoneIDs[strings.ToLower(strings.TrimSuffix(zone, "."))] = strings.TrimPrefix(id, "/hostedzone/")
+                }
+        }
+        log.Printf("[%s] ℹ️ Using AWS credentials from %s (access key %s)", time.Now().Format("2006-01-02 15:04:05"), creds.Source, maskAccessKeyID(creds.AccessKeyID))
+        return p, nil
+}
+
+// maskAccessKeyID 只保留访问密钥 ID 的最后 4 个字符用于日志, 与 AWS CLI 的 "aws configure list" 相同
+func maskAccessKeyID(id string) string {
+        if len(id) <= 4 {
+                return strings.Repeat("*", len(id))
+        }
+        return strings.Repeat("*", len(id)-4) + id[len(id)-4:]
+}
+
+func (p *route53Provider) Name() string {
+        return "Route 53 API"
+}
+
+// route53Zone 是 ListHostedZones 返回的一个托管区域
+type route53Zone struct {
+        ID      string `xml:"Id"` // "/hostedzone/Z..."
+        Name    string `xml:"Name"`
+        Private bool   `xml:"Config>PrivateZone"`
+}
+
+// route53RRset 是一个资源记录集; 带 SetIdentifier (路由策略) 或 AliasTarget (别名) 的记录集不由本工具管理
+type route53RRset struct {
+        Name          string    `xml:"Name"`
+        Type          string    `xml:"Type"`
+        SetIdentifier string    `xml:"SetIdentifier,omitempty"`
+        TTL           int       `xml:"TTL,omitempty"`
+        Values        []string  `xml:"ResourceRecords>ResourceRecord>Value"`
+        AliasTarget   *struct{} `xml:"AliasTarget"`
+}
+
+// route53Change 是 ChangeResourceRecordSets 请求中的一项变更
+type route53Change struct {
+        Action string       `xml:"Action"` // "UPSERT" 或 "DELETE"
+        RRset  route53RRset `xml:"ResourceRecordSet"`
+}
+
+// do 发送一个签名的 API 请求, 成功时把 XML 响应解析到 out
+// 错误响应为 <ErrorResponse><Error><Code/><Message/></Error></ErrorResponse>, 无效的变更为 <InvalidChangeBatch>
+func (p *route53Provider) do(ctx context.Context, method, path string, body, out interface{}) error {
+        var payload []byte
+        if body != nil {
+                data, err := xml.Marshal(body)
+                if err != nil {
+                        return fmt.Errorf("encoding request failed: %w", err)
+                }
+                payload = append([]byte(xml.Header), data...)
+        }
+        req, err := http.NewRequestWithContext(ctx, method, p.endpoint+"/"+route53APIVersion+path, bytes.NewReader(payload))
+        if err != nil {
+                return fmt.Errorf("creating request failed: %w", err)
+        }
+        if body != nil {
+                req.Header.Set("Content-Type", "application/xml")
+        }
+        signV4(req, payload, p.creds, p.region, "route53", p.now())
+
+        resp, err := p.client.Do(req)
+        if err != nil {
+                return fmt.Errorf("%s %s failed: %w", method, path, err)
+        }
+        defer resp.Body.Close()
+        respBody, err := io.ReadAll(resp.Body)
+        if err != nil {
+                return fmt.Errorf("reading response of %s %s failed (status: %s): %w", method, path, resp.Status, err)
+        }
+
+        if resp.StatusCode < 200 || resp.StatusCode > 299 {
+                var apiErr struct {
+                        XMLName  xml.Name
+                        Code     string   `xml:"Error>Code"`
+                        Message  string   `xml:"Error>Message"`
+                        Messages []string `xml:"Messages>Message"` // InvalidChangeBatch
+                }
+                msg := strings.TrimSpace(string(respBody))
+                if xml.Unmarshal(respBody, &apiErr) == nil {
+                        switch {
+                        case apiErr.Code != "":
+                                msg = apiErr.Code + ": " + apiErr.Message
+                        case apiErr.XMLName.Local == "InvalidChangeBatch":
+                                msg = "InvalidChangeBatch: " + strings.Join(apiErr.Messages, "; ")
+                        }
+                }
+                switch apiErr.Code {
+                case "InvalidClientTokenId", "SignatureDoesNotMatch", "AccessDenied", "ExpiredToken", "UnrecognizedClientException":
+                        msg += fmt.Sprintf(" (check the AWS credentials from %s and their route53 permissions)", p.creds.Source)
+                }
+                return fmt.Errorf("%s %s failed: %s: %s", method, path, resp.Status, msg)
+        }
+        if out != nil {
+                if err := xml.Unmarshal(respBody, out); err != nil {
+                        return fmt.Errorf("parsing response of %s %s failed: %w", method, path, err)
+                }
+        }
+        return nil
+}
+
+// zoneID 返回区域的托管区域 ID: 优先使用配置, 否则在列出的公有托管区域中查找
+func (p *route53Provider) zoneID(ctx context.Context, zone string) (string, error) {
+        zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+        if id, ok := p.zoneIDs[zone]; ok {
+                return id, nil
+        }
+        if err := p.loadZones(ctx); err != nil {
+                return "", err
+        }
+        id, ok := p.zones[zone]
+        if !ok {
+                return "", fmt.Errorf("no public hosted zone named '%s' (set 'hosted_zone_ids' for private zones)", zone)
+        }
+        return id, nil
+}
+
+// loadZones 列出账号下的全部公有托管区域 (分页, 每页最多 100 个)
+func (p *route53Provider) loadZones(ctx context.Context) error {
+        if p.zones != nil {
+                return nil
+        }
+        zones := map[string]string{}
+        marker := ""
+        for {
+                var result struct {
+                        Zones       []route53Zone `xml:"HostedZones>HostedZone"`
+                        IsTruncated bool          `xml:"IsTruncated"`
+                        NextMarker  string        `xml:"NextMarker"`
+                }
+                path := "/hostedzone"
+                if marker != "" {
+                        path += "?marker=" + url.QueryEscape(marker)
+                }
+                if err := p.do(ctx, http.MethodGet, path, nil, &result); err != nil {
+                        return fmt.Errorf("listing hosted zones failed: %w", err)
+                }
+                for _, z := range result.Zones {
+                        if z.Private {
+                                continue
+                        }
+                        name := strings.ToLower(strings.TrimSuffix(z.Name, "."))
+                        id := strings.TrimPrefix(z.ID, "/hostedzone/")
+                        if existing, ok := zones[name]; ok {
+                                log.Printf("[%s] ⚠️ Warning: Found multiple public hosted zones named %s. Using the first one (ID: %s).", time.Now().Format("2006-01-02 15:04:05"), name, existing)
+                                continue
+                        }
+                        zones[name] = id
+                }
+                if !result.IsTruncated || result.NextMarker == "" {
+                        break
+                }
+                marker = result.NextMarker
+        }
+        p.zones = zones
+        return nil
+}
+
+// DetectZone 在公有托管区域中取最长的后缀匹配
+func (p *route53Provider) DetectZone(ctx context.Context, fqdn string) (string, error) {
+        if err := p.loadZones(ctx); err != nil {
+                return "", err
+        }
+        zone, ok := findOwningZone(fqdn, p.zones)
+        if !ok {
+                return "", fmt.Errorf("none of the %d public hosted zones owns '%s'", len(p.zones), fqdn)
+        }
+        log.Printf("[%s] ✅ Detected zone %s for %s", time.Now().Format("2006-01-02 15:04:05"), zone, fqdn)
+        return zone, nil
+}
+
+// Lookup 通过 ListResourceRecordSets 读取记录集; 该接口从指定名称和类型开始按顺序列出, 因此需要核对返回的名称
+func (p *route53Provider) Lookup(ctx context.Context, zone, fqdn, recordType string) (*Record, error) {
+        id, err := p.zoneID(ctx, zone)
+        if err != nil {
+                return nil, fmt.Errorf("requesting DNS record %s (%s) failed: %w", fqdn, recordType, err)
+        }
+        query := url.Values{"name": {canonicalDNSName(fqdn)}, "type": {recordType}, "maxitems": {"1"}}
+        var result struct {
+                RRsets []route53RRset `xml:"ResourceRecordSets>ResourceRecordSet"`
+        }
+        if err := p.do(ctx, http.MethodGet, "/hostedzone/"+id+"/rrset?"+query.Encode(), nil, &result); err != nil {
+                return nil, fmt.Errorf("requesting DNS record %s (%s) failed: %w", fqdn, recordType, err)
+        }
+
+        nowStr := time.Now().Format("2006-01-02 15:04:05")
+        if len(result.RRsets) == 0 || result.RRsets[0].Type != recordType || canonicalDNSName(result.RRsets[0].Name) != canonicalDNSName(fqdn) {
+                log.Printf("[%s] ℹ️ No existing %s record found for %s via API.", nowStr, recordType, fqdn)
+                return nil, nil
+        }
+        rrset := result.RRsets[0]
+        switch {
+        case rrset.AliasTarget != nil:
+                return nil, fmt.Errorf("%s record %s is an alias record, refusing to overwrite it", recordType, fqdn)
+        case rrset.SetIdentifier != "":
+                return nil, fmt.Errorf("%s record %s uses a routing policy (set identifier '%s'), which is not supported", recordType, fqdn, rrset.SetIdentifier)
+        case len(rrset.Values) == 0:
+                log.Printf("[%s] ℹ️ No existing %s record found for %s via API.", nowStr, recordType, fqdn)
+                return nil, nil
+        }
+        log.Printf("[%s] ℹ️ Found existing %s record for %s via API (IP: %s).", nowStr, recordType, fqdn, rrset.Values[0])
+        if len(rrset.Values) > 1 {
+                // UPSERT replaces the whole record set, so the extra addresses are removed on the next change
+                log.Printf("[%s] ⚠️ Warning: Found %d %s records for %s. Comparing against the first one (%s).",
+                        nowStr, len(rrset.Values), recordType, fqdn, rrset.Values[0])
+        }
+        return &Record{Type: recordType, Name: fqdn, Content: rrset.Values[0], TTL: rrset.TTL}, nil
+}
+
+// Create 与 Update 相同: 以 UPSERT 用一条记录替换整个记录集
+func (p *route53Provider) Create(ctx context.Context, zone string, record Record) (*Record, error) {
+        return p.Update(ctx, zone, nil, record)
+}
+
+func (p *route53Provider) Update(ctx context.Context, zone string, existing *Record, record Record) (*Record, error) {
+        if err := p.change(ctx, zone, "UPSERT", record); err != nil {
+                return nil, err
+        }
+        return &record, nil
+}
+
+// change 通过 ChangeResourceRecordSets 提交一项变更; 变更返回时状态为 PENDING, 通常在一分钟内同步到全部权威服务器
+func (p *route53Provider) change(ctx context.Context, zone, action string, record Record) error {
+        id, err := p.zoneID(ctx, zone)
+        if err != nil {
+                return fmt.Errorf("%s of %s (%s) failed: %w", action, record.Name, record.Type, err)
+        }
+        body := struct {
+                XMLName xml.Name        `xml:"ChangeResourceRecordSetsRequest"`
+                Xmlns   string          `xml:"xmlns,attr"`
+                Comment string          `xml:"ChangeBatch>Comment"`
+                Changes []route53Change `xml:"ChangeBatch>Changes>Change"`
+        }{
+                Xmlns:   "https://route53.amazonaws.com/doc/" + route53APIVersion + "/",
+                Comment: "dynamic DNS update",
+                Changes: []route53Change{{
+                        Action: action,
+                        RRset: route53RRset{
+                                Name:   canonicalDNSName(record.Name),
+                                Type:   record.Type,
+                                TTL:    record.TTL,
+                                Values: []string{record.Content},
+                        },
+                }},
+        }
+        var result struct {
+                ID     string `xml:"ChangeInfo>Id"`
+                Status string `xml:"ChangeInfo>Status"`
+        }
+        if err := p.do(ctx, http.MethodPost, "/hostedzone/"+id+"/rrset/", body, &result); err != nil {
+                return fmt.Errorf("%s of %s (%s) in zone %s failed: %w", action, record.Name, record.Type, zone, err)
+        }
+        if result.Status == "" {
+                return fmt.Errorf("%s of %s (%s) in zone %s failed: response has no ChangeInfo", action, record.Name, record.Type, zone)
+        }
+        log.Printf("[%s] ℹ️ Route 53 change %s is %s", time.Now().Format("2006-01-02 15:04:05"), strings.TrimPrefix(result.ID, "/change/"), result.Status)
+        return nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+        "crypto/sha256"
+        "encoding/hex"
+        "errors"
+        "fmt"
+        "log"
+        "sort"
+        "strings"
+        "time"
+)
+
+// 失败策略 (Config.FailurePolicy): 决定何时以非零状态退出
+const (
+        failurePolicyAny = "any" // 任意一条记录失败即失败 (默认)
+        failurePolicyAll = "all" // 只有全部记录都失败才算失败
+)
+
+// RecordConfig 描述 records 中的一条 DNS 记录, 省略的字段继承顶层配置
+type RecordConfig struct {
+        Name    string `json:"name"`              // 记录名: "@", "www" 或完整域名
+        Type    string `json:"type,omitempty"`    // "A" 或 "AAAA"; 省略时按 ipversion 生成
+        TTL     *int   `json:"ttl,omitempty"`     // 覆盖顶层 ttl
+        Proxied *bool  `json:"proxied,omitempty"` // 覆盖顶层 proxied
+        Zone    string `json:"zone,omitempty"`    // 覆盖顶层 zone
+        // Provider 覆盖顶层 provider, 同一份配置可以同时更新不同服务商上的记录
+        Provider string `json:"provider,omitempty"`
+        // IPv6InterfaceID 覆盖顶层 ipv6_interface_id, 用于为前缀下不同的 LAN 主机发布 AAAA 记录
+        IPv6InterfaceID string `json:"ipv6_interface_id,omitempty"`
+}
+
+// recordTarget 是展开后的一条待更新记录
+type recordTarget struct {
+        FQDN        string
+        Zone        string // 为空时在运行时根据 FQDN 自动识别
+        Type        string // "A" 或 "AAAA"
+        TTL         int
+        Proxied     bool
+        InterfaceID string // 非空时 AAAA 记录使用前缀 + 接口标识符模式
+        Provider    string // 服务商名称, 见 providerCloudflare 等
+}
+
+// family 返回记录类型对应的 IP 版本
+func (t recordTarget) family() string {
+        if t.Type == "AAAA" {
+                return "ipv6"
+        }
+        return "ipv4"
+}
+
+func (t recordTarget) String() string {
+        return fmt.Sprintf("%s (%s)", t.FQDN, t.Type)
+}
+
+// targetsFingerprint 返回一组记录设置的摘要, 与 IP 一起写入 .lastip 缓存
+// 记录的名称、区域、TTL、代理状态、服务商或前缀设置变化后摘要随之改变, 即使 IP 不变也会重新推送
+func targetsFingerprint(targets []recordTarget, prefixLen int) string {
+        lines := make([]string, len(targets))
+        for i, t := range targets {
+                lines[i] = fmt.Sprintf("%s|%s|%s|%d|%t|%s|%s", t.Provider, t.Zone, t.FQDN, t.TTL, t.Proxied, t.Type, t.InterfaceID)
+        }
+        sort.Strings(lines)
+        sum := sha256.Sum256([]byte(fmt.Sprintf("prefix=%d\n%s", prefixLen, strings.Join(lines, "\n"))))
+        return hex.EncodeToString(sum[:8])
+}
+
+// expandRecords 将 records (或旧的 record 字段) 按 ipversion 展开为具体的 A/AAAA 记录
+func expandRecords(config Config) ([]recordTarget, error) {
+        records := config.Records
+        if len(records) == 0 {
+                if config.Record == "" {
+                        return nil, errors.New("missing required field 'record' (or 'records')")
+                }
+                records = []RecordConfig{{Name: config.Record}}
+        } else if config.Record != "" {
+                return nil, errors.New("'record' and 'records' cannot be used together")
+        }
+
+        var targets []recordTarget
+        for i, rec := range records {
+                if rec.Name == "" {
+                        return nil, fmt.Errorf("records[%d] is missing 'name'", i)
+                }
+                zone := rec.Zone
+                if zone == "" {
+                        zone = config.Zone
+                }
+                // Without any zone the name must be fully qualified; the owning zone is detected at runtime
+                if zone == "" && (rec.Name == "@" || !strings.Contains(strings.Trim(rec.Name, "."), ".")) {
+                        return nil, fmt.Errorf("records[%d] (%s) has no 'zone' and is not a fully-qualified name", i, rec.Name)
+                }
+
+                provider := rec.Provider
+                if provider == "" {
+                        provider = config.Provider
+                }
+                if provider == "" {
+                        provider = providerCloudflare
+                }
+                if !validProvider(provider) {
+                        return nil, fmt.Errorf("records[%d] (%s) has unknown provider '%s'", i, rec.Name, provider)
+                }
+
+                base := recordTarget{
+                        FQDN:        buildFQDN(rec.Name, zone),
+                        Zone:        strings.ToLower(strings.TrimSuffix(zone, ".")),
+                        TTL:         config.TTL,
+                        Proxied:     config.Proxied,
+                        InterfaceID: config.IPv6InterfaceID,
+                        Provider:    provider,
+                }
+                if rec.TTL != nil {
+                        base.TTL = *rec.TTL
+                        if base.TTL < 1 { // TTL 1 means 'automatic' for Cloudflare
+                                base.TTL = 1
+                        }
+                }
+                if rec.Proxied != nil {
+                        base.Proxied = *rec.Proxied
+                }
+                if provider != providerCloudflare {
+                        if base.Proxied {
+                                return nil, fmt.Errorf("records[%d] (%s) sets 'proxied', which only the Cloudflare provider supports", i, rec.Name)
+                        }
+                        if base.TTL == 1 { // 'Automatic' only exists on Cloudflare
+                                base.TTL = max(defaultRecordTTL, providerMinTTL[provider])
+                        }
+                        // Below the minimum every update would be rejected by the API; the configuration itself is left as is
+                        if minTTL := providerMinTTL[provider]; base.TTL < minTTL {
+                                log.Printf("[%s] ⚠️ TTL value (%d) of %s is below the minimum of %d accepted by %s, using %d", time.Now().Format("2006-01-02 15:04:05"), base.TTL, rec.Name, minTTL, provider, minTTL)
+                                base.TTL = minTTL
+                        }
+                }
+                if rec.IPv6InterfaceID != "" {
+                        if _, err := parseInterfaceID(rec.IPv6InterfaceID); err != nil {
+                                return nil, fmt.Errorf("records[%d] (%s) has invalid 'ipv6_interface_id': %w", i, rec.Name, err)
+                        }
+                        base.InterfaceID = rec.IPv6InterfaceID
+                }
+
+                var types []string
+                switch strings.ToUpper(rec.Type) {
+                case "":
+                        for _, ipversion := range config.IPVersion {
+                                if ipversion == "ipv6" {
+                                        types = append(types, "AAAA")
+                                } else {
+                                        types = append(types, "A")
+                                }
+                        }
+                case "A":
+                        types = []string{"A"}
+                case "AAAA":
+                        types = []string{"AAAA"}
+                default:
+                        return nil, fmt.Errorf("records[%d] (%s) has invalid 'type' ('%s'), must be 'A' or 'AAAA'", i, rec.Name, rec.Type)
+                }
+
+                for _, recordType := range types {
+                        target := base
+                        target.Type = recordType
+                        if !config.IPVersion.has(target.family()) {
+                                return nil, fmt.Errorf("records[%d] (%s) is type %s but 'ipversion' does not include %s", i, rec.Name, recordType, target.family())
+                        }
+                        if recordType != "AAAA" {
+                                target.InterfaceID = ""
+                        }
+                        targets = append(targets, target)
+                }
+        }
+        return targets, nil
+}
+
+// buildFQDN 根据记录名和区域生成完整域名
+// "@" 或与区域同名表示根记录; 已经以区域结尾的名称视为完整域名
+func buildFQDN(name, zone string) string {
+        name = strings.TrimSuffix(name, ".")
+        zone = strings.TrimSuffix(zone, ".")
+        if zone == "" {
+                return name
+        }
+        if name == "@" || strings.EqualFold(name, zone) { // Handle both "@" and zone name itself for root
+                return zone
+        }
+        if strings.HasSuffix(strings.ToLower(name), "."+strings.ToLower(zone)) {
+                return name
+        }
+        return name + "." + zone
+}
+
+// findOwningZone 在 zones (区域名 -> Zone ID) 中查找拥有 fqdn 的区域
+// 从完整域名开始逐级去掉最左侧的标签, 第一个命中的就是最长的后缀匹配
+func findOwningZone(fqdn string, zones map[string]string) (string, bool) {
+        name := strings.ToLower(strings.TrimSuffix(fqdn, "."))
+        for {
+                if _, ok := zones[name]; ok {
+                        return name, true
+                }
+                dot := strings.IndexByte(name, '.')
+                if dot < 0 {
+                        return "", false
+                }
+                name = name[dot+1:]
+        }
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+        "encoding/json"
+        "os"
+        "path/filepath"
+        "strconv"
+        "strings"
+        "testing"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestRecordTTLProviderMinimum(t *testing.T) {
+        config := Config{
+                TTL:       120,
+                IPVersion: ipVersionList{"ipv4"},
+                Records: []RecordConfig{
+                        {Name: "home", Zone: "example.com"},
+                        {Name: "home", Zone: "example.cn", Provider: providerAliDNS},
+                        {Name: "nas", Zone: "example.cn", Provider: providerDNSPod, TTL: intPtr(1)},
+                        {Name: "www", Zone: "example.cn", Provider: providerDNSPod, TTL: intPtr(3600)},
+                        {Name: "lab", Zone: "example.org", Provider: providerPowerDNS, TTL: intPtr(1)},
+                },
+        }
+        targets, err := expandRecords(config)
+        if err != nil {
+                t.Fatal(err)
+        }
+        var got []string
+        for _, target := range targets {
+                got = append(got, target.Provider+"="+strconv.Itoa(target.TTL))
+        }
+        want := "cloudflare=120 alidns=600 dnspod=600 dnspod=3600 powerdns=300"
+        if strings.Join(got, " ") != want {
+                t.Fatalf("TTLs = %s, want %s", strings.Join(got, " "), want)
+        }
+        if config.TTL != 120 || config.Records[1].TTL != nil {
+                t.Fatal("expandRecords must not modify the configuration")
+        }
+
+        legacy := Config{TTL: 60, Provider: providerDNSPod, Zone: "example.cn", Record: "home", IPVersion: ipVersionList{"ipv4"}}
+        if targets, err := expandRecords(legacy); err != nil || targets[0].TTL != 600 || legacy.TTL != 60 {
+                t.Fatalf("legacy record: %+v, %v", targets, err)
+        }
+}
+
+// 写回配置文件 (缓存 Zone ID) 时不能带上按服务商最小值提高后的 TTL
+func TestWriteConfigKeepsRecordTTLs(t *testing.T) {
+        path := filepath.Join(t.TempDir(), "config.json")
+        data := `{
+  "api_token": "token",
+  "alidns": {"access_key_id": "id", "access_key_secret": "secret"},
+  "ipversion": "ipv4",
+  "interface": "eth0",
+  "ttl": 120,
+  "records": [
+    {"name": "home", "zone": "example.com"},
+    {"name": "home", "zone": "example.cn", "provider": "alidns"},
+    {"name": "www", "zone": "example.cn", "provider": "alidns", "ttl": 120}
+  ]
+}`
+        if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+                t.Fatal(err)
+        }
+        config, err := readConfig(path)
+        if err != nil {
+                t.Fatal(err)
+        }
+        if got := config.targets[2].TTL; got != 600 {
+                t.Fatalf("alidns record TTL = %d, want 600", got)
+        }
+        config.ZoneID = "zone-id"
+        if err := writeConfig(path, config); err != nil {
+                t.Fatal(err)
+        }
+
+        var saved struct {
+                TTL     int `json:"ttl"`
+                Records []struct {
+                        TTL *int `json:"ttl"`
+                } `json:"records"`
+        }
+        written, err := os.ReadFile(path)
+        if err != nil {
+                t.Fatal(err)
+        }
+        if err := json.Unmarshal(written, &saved); err != nil {
+                t.Fatal(err)
+        }
+        if saved.TTL != 120 || saved.Records[0].TTL != nil || saved.Records[1].TTL != nil || saved.Records[2].TTL == nil || *saved.Records[2].TTL != 120 {
+                t.Fatalf("TTLs changed in the saved configuration:\n%s", written)
+        }
+}
+
+func TestFindOwningZone(t *testing.T) {
+        zones := map[string]string{"example.co.uk": "z1", "lab.example.co.uk": "z2", "example.com": "z3"}
+        tests := []struct {
+                fqdn, want string
+        }{
+                {"home.lab.example.co.uk", "lab.example.co.uk"}, // delegated subzone wins over its parent
+                {"lab.example.co.uk", "lab.example.co.uk"},
+                {"www.example.co.uk", "example.co.uk"},
+                {"a.b.c.example.co.uk", "example.co.uk"},
+                {"HOME.Lab.Example.CO.UK.", "lab.example.co.uk"},
+                {"example.com.", "example.com"},
+                {"badexample.com", ""}, // only whole labels match
+                {"www.badexample.com", ""},
+                {"example.co", ""},
+                {"co.uk", ""},
+                {"", ""},
+        }
+        for _, tt := range tests {
+                got, ok := findOwningZone(tt.fqdn, zones)
+                if got != tt.want || ok != (tt.want != "") {
+                        t.Errorf("findOwningZone(%q) = %q, %v; want %q", tt.fqdn, got, ok, tt.want)
+                }
+        }
+}
+
+func TestBuildFQDN(t *testing.T) {
+        tests := []struct {
+                name, zone, want string
+        }{
+                {"@", "example.com", "example.com"},
+                {"www", "example.com", "www.example.com"},
+                {"www.", "example.com.", "www.example.com"},
+                {"example.com", "example.com", "example.com"},
+                {"Example.COM", "example.com", "example.com"},
+                {"www.example.com", "example.com", "www.example.com"},
+                {"WWW.Example.com", "example.com", "WWW.Example.com"},
+                {"www.badexample.com", "example.com", "www.badexample.com.example.com"},
+                {"home.lab.example.co.uk.", "", "home.lab.example.co.uk"},
+        }
+        for _, tt := range tests {
+                if got := buildFQDN(tt.name, tt.zone); got != tt.want {
+                        t.Errorf("buildFQDN(%q, %q) = %q, want %q", tt.name, tt.zone, got, tt.want)
+                }
+        }
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+        "fmt"
+        "time"
+
+        "github.com/Solvris/NetTools/cloudflare"
+)
+
+// 重试行为见 cloudflare.RetryPolicy; 这里只负责配置文件中的 retry 设置
+
+// RetryConfig 配置 Cloudflare API 请求的重试
+type RetryConfig struct {
+        MaxAttempts int `json:"max_attempts,omitempty"` // 最多尝试次数 (包括第一次), 默认 4; 1 表示不重试
+        BaseDelay   int `json:"base_delay,omitempty"`   // 第一次重试前的等待秒数, 之后每次翻倍, 默认 1
+        MaxDelay    int `json:"max_delay,omitempty"`    // 单次等待的上限秒数, 默认 30; Retry-After 超过它时放弃重试
+}
+
+// validate 检查重试设置 (nil 表示全部使用默认值)
+func (r *RetryConfig) validate() error {
+        if r == nil {
+                return nil
+        }
+        if r.MaxAttempts < 0 || r.BaseDelay < 0 || r.MaxDelay < 0 {
+                return fmt.Errorf("'max_attempts', 'base_delay' and 'max_delay' must not be negative")
+        }
+        if r.MaxDelay != 0 && r.BaseDelay > r.MaxDelay {
+                return fmt.Errorf("'base_delay' (%d) must not exceed 'max_delay' (%d)", r.BaseDelay, r.MaxDelay)
+        }
+        return nil
+}
+
+// policy 返回填好默认值的重试策略
+func (r *RetryConfig) policy() cloudflare.RetryPolicy {
+        p := cloudflare.DefaultRetryPolicy()
+        if r == nil {
+                return p
+        }
+        if r.MaxAttempts > 0 {
+                p.MaxAttempts = r.MaxAttempts
+        }
+        if r.BaseDelay > 0 {
+                p.BaseDelay = time.Duration(r.BaseDelay) * time.Second
+        }
+        if r.MaxDelay > 0 {
+                p.MaxDelay = time.Duration(r.MaxDelay) * time.Second
+        }
+        return p
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+        "bufio"
+        "errors"
+        "fmt"
+        "net/http"
+        "net/url"
+        "os"
+        "path/filepath"
+        "sort"
+        "strings"
+        "time"
+)
+
+// AWS Signature Version 4 请求签名, 以及按 AWS CLI 的顺序查找访问密钥; 只实现 Route 53 需要的部分
+
+const sigV4Algorithm = "AWS4-HMAC-SHA256"
+
+// awsCredentials 是 SigV4 签名使用的访问密钥
+type awsCredentials struct {
+        AccessKeyID     string
+        SecretAccessKey string
+        SessionToken    string // 临时凭据 (STS) 的会话令牌, 以 X-Amz-Security-Token 头发送
+        Source          string // 凭据来源, 用于日志和错误信息
+}
+
+// signV4 为请求签名: 设置 X-Amz-Date (有会话令牌时还有 X-Amz-Security-Token) 和 Authorization 头
+// 签入 host 和请求中已有的全部头; payload 是请求正文, 没有正文时为 nil
+func signV4(req *http.Request, payload []byte, creds awsCredentials, region, service string, t time.Time) {
+        amzDate := t.UTC().Format("20060102T150405Z")
+        date := amzDate[:8]
+        req.Header.Set("X-Amz-Date", amzDate)
+        if creds.SessionToken != "" {
+                req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+        }
+
+        canonicalHeaders, signedHeaders := sigV4Headers(req)
+        canonicalRequest := strings.Join([]string{
+                req.Method,
+                sigV4URI(req.URL),
+                sigV4Query(req.URL),
+                canonicalHeaders,
+                signedHeaders,
+                sha256Hex(payload),
+        }, "\n")
+
+        scope := date + "/" + region + "/" + service + "/aws4_request"
+        stringToSign := sigV4Algorithm + "\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+        key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+        key = hmacSHA256(key, region)
+        key = hmacSHA256(key, service)
+        key = hmacSHA256(key, "aws4_request")
+        signature := fmt.Sprintf("%x", hmacSHA256(key, stringToSign))
+
+        req.Header.Set("Authorization", sigV4Algorithm+" Credential="+creds.AccessKeyID+"/"+scope+
+                ", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// sigV4URI 返回规范 URI: 路径的每一段按 RFC 3986 编码一次
+func sigV4URI(u *url.URL) string {
+        if u.Path == "" {
+                return "/"
+        }
+        segments := strings.Split(u.Path, "/")
+        for i, s := range segments {
+                segments[i] = percentEncode(s)
+        }
+        return strings.Join(segments, "/")
+}
+
+// sigV4Query 返回规范查询字符串: 参数按名称 (同名时按值) 排序并编码
+func sigV4Query(u *url.URL) string {
+        query, _ := url.ParseQuery(u.RawQuery)
+        pairs := make([]string, 0, len(query))
+        for k, values := range query {
+                for _, v := range values {
+                        pairs = append(pairs, percentEncode(k)+"="+percentEncode(v))
+                }
+        }
+        sort.Strings(pairs)
+        return strings.Join(pairs, "&")
+}
+
+// sigV4Headers 返回规范头 (小写名称, 去掉首尾和连续空白) 和签入的头列表
+func sigV4Headers(req *http.Request) (canonical, signed string) {
+        headers := map[string]string{}
+        for name, values := range req.Header {
+                trimmed := make([]string, len(values))
+                for i, v := range values {
+                        trimmed[i] = strings.Join(strings.Fields(v), " ")
+                }
+                headers[strings.ToLower(name)] = strings.Join(trimmed, ",")
+        }
+        host := req.Host
+        if host == "" {
+                host = req.URL.Host
+        }
+        headers["host"] = host
+
+        names := make([]string, 0, len(headers))
+        for name := range headers {
+                names = append(names, name)
+        }
+        sort.Strings(names)
+        var b strings.Builder
+        for _, name := range names {
+                b.WriteString(name + ":" + headers[name] + "\n")
+        }
+        return b.String(), strings.Join(names, ";")
+}
+
+// loadAWSCredentials 按顺序查找访问密钥: 配置文件中的 access_key_id/secret_access_key,
+// 环境变量 AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY, 最后是共享凭据文件 (~/.aws/credentials) 中的 profile
+func loadAWSCredentials(config *Route53Config) (awsCredentials, error) {
+        if config != nil && config.AccessKeyID != "" {
+                if config.SecretAccessKey == "" {
+                        return awsCredentials{}, errors.New("'access_key_id' is set but 'secret_access_key' is missing")
+                }
+                return awsCredentials{
+                        AccessKeyID:     config.AccessKeyID,
+                        SecretAccessKey: config.SecretAccessKey,
+                        SessionToken:    config.SessionToken,
+                        Source:          "config file",
+                }, nil
+        }
+        if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+                return awsCredentials{
+                        AccessKeyID:     id,
+                        SecretAccessKey: secret,
+                        SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+                        Source:          "environment",
+                }, nil
+        }
+
+        profile := os.Getenv("AWS_PROFILE")
+        if config != nil && config.Profile != "" {
+                profile = config.Profile
+        }
+        if profile == "" {
+                profile = "default"
+        }
+        path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+        if path == "" {
+                home, err := os.UserHomeDir()
+                if err != nil {
+                        return awsCredentials{}, fmt.Errorf("no AWS credentials in the config file or environment, and the home directory is unknown: %w", err)
+                }
+                path = filepath.Join(home, ".aws", "credentials")
+        }
+        creds, err := readSharedCredentials(path, profile)
+        if err != nil {
+                return awsCredentials{}, fmt.Errorf("no AWS credentials in the config file or environment, and %w", err)
+        }
+        return creds, nil
+}
+
+// readSharedCredentials 读取共享凭据文件 (INI 格式) 中的一个 profile
+func readSharedCredentials(path, profile string) (awsCredentials, error) {
+        f, err := os.Open(path)
+        if err != nil {
+                return awsCredentials{}, fmt.Errorf("reading shared credentials file failed: %w", err)
+        }
+        defer f.Close()
+
+        creds := awsCredentials{Source: fmt.Sprintf("%s [%s]", path, profile)}
+        found := false
+        section := ""
+        scanner := bufio.NewScanner(f)
+        for scanner.Scan() {
+                line := strings.TrimSpace(scanner.Text())
+                if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+                        continue
+                }
+                if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+                        section = strings.TrimSpace(line[1 : len(line)-1])
+                        found = found || section == profile
+                        continue
+                }
+                key, value, ok := strings.Cut(line, "=")
+                if !ok || section != profile {
+                        continue
+                }
+                switch strings.ToLower(strings.TrimSpace(key)) {
+                case "aws_access_key_id":
+                        creds.AccessKeyID = strings.TrimSpace(value)
+                case "aws_secret_access_key":
+                        creds.SecretAccessKey = strings.TrimSpace(value)
+                case "aws_session_token":
+                        creds.SessionToken = strings.TrimSpace(value)
+                }
+        }
+        if err := scanner.Err(); err != nil {
+                return awsCredentials{}, fmt.Errorf("reading shared credentials file failed: %w", err)
+        }
+        if !found {
+                return awsCredentials{}, fmt.Errorf("profile '%s' not found in %s", profile, path)
+        }
+        if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+                return awsCredentials{}, fmt.Errorf("profile '%s' in %s has no aws_access_key_id/aws_secret_access_key", profile, path)
+        }
+        return creds, nil
+}
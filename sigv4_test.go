@@ -0,0 +1,120 @@
+package main
+
+import (
+        "net/http"
+        "os"
+        "path/filepath"
+        "strings"
+        "testing"
+        "time"
+)
+
+// 以下用例取自 AWS 的 SigV4 测试套件 (aws-sig-v4-test-suite): 凭据 AKIDEXAMPLE, 时间 20150830T123600Z,
+// 区域 us-east-1, 服务名 "service"
+var (
+        sigV4TestCreds = awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+        sigV4TestTime  = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+)
+
+const sigV4TestSessionToken = "AQoDYXdzEPT//////////wEXAMPLEtc764bNrC9SAPBSM22wDOk4x4HIZ8j4FZTwdQWLWsKWHGBuFqwAeMicRXmxfpSPfIeoIYRqTflfKD8YUuwthAx7mSEI/qkPpKPi/kMcGdQrmGdeehM4IC1NtBmUpp2wUE8phUZampKsburEDy0KPkyQDYwT7WZ0wq5VSXDvp75YU9HFvlRd8Tx6q6fE8YQcHNVXAkiY9q6d+xo0rKwT38xVqr7ZD0u0iPPkUL64lIZbqBAz+scqKmlzm8FDrypNC9Yjc8fPOLn9FX9KSYvKTr4rvx3iSIlTJabIQwj2ICCR/oLxBA=="
+
+func TestSignV4(t *testing.T) {
+        tests := []struct {
+                name          string
+                method        string
+                url           string
+                contentType   string
+                body          string
+                sessionToken  string
+                signedHeaders string
+                signature     string
+        }{
+                {"get-vanilla", "GET", "https://example.amazonaws.com/", "", "", "",
+                        "host;x-amz-date", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+                {"get-vanilla-query-order-key-case", "GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "", "", "",
+                        "host;x-amz-date", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+                {"get-vanilla-query-unreserved", "GET", "https://example.amazonaws.com/?-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ_abcdefghijklmnopqrstuvwxyz=-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ_abcdefghijklmnopqrstuvwxyz", "", "", "",
+                        "host;x-amz-date", "c0e2549664ab6caf8a0e49ec520df161cca33ec1de41067db4994a4467d458ff"},
+                {"get-utf8", "GET", "https://example.amazonaws.com/ሴ", "", "", "",
+                        "host;x-amz-date", "8318018e0b0f223aa2bbf98705b62bb787dc9c0e678f255a891fd03141be5d85"},
+                {"post-vanilla", "POST", "https://example.amazonaws.com/", "", "", "",
+                        "host;x-amz-date", "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+                {"post-x-www-form-urlencoded", "POST", "https://example.amazonaws.com/", "application/x-www-form-urlencoded", "Param1=value1", "",
+                        "content-type;host;x-amz-date", "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a"},
+                {"post-sts-header-after", "POST", "https://example.amazonaws.com/", "", "", sigV4TestSessionToken,
+                        "host;x-amz-date;x-amz-security-token", "85d96828115b5dc0cfc3bd16ad9e210dd772bbebba041836c64533a82be05ead"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        if tt.contentType != "" {
+                                req.Header.Set("Content-Type", tt.contentType)
+                        }
+                        var payload []byte
+                        if tt.body != "" {
+                                payload = []byte(tt.body)
+                        }
+                        creds := sigV4TestCreds
+                        creds.SessionToken = tt.sessionToken
+                        signV4(req, payload, creds, "us-east-1", "service", sigV4TestTime)
+
+                        want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=" +
+                                tt.signedHeaders + ", Signature=" + tt.signature
+                        if got := req.Header.Get("Authorization"); got != want {
+                                t.Fatalf("Authorization:\n got %s\nwant %s", got, want)
+                        }
+                        if req.Header.Get("X-Amz-Date") != "20150830T123600Z" {
+                                t.Fatalf("X-Amz-Date = %s", req.Header.Get("X-Amz-Date"))
+                        }
+                })
+        }
+}
+
+func TestLoadAWSCredentials(t *testing.T) {
+        dir := t.TempDir()
+        path := filepath.Join(dir, "credentials")
+        shared := "# comment\n[default]\naws_access_key_id = DEFAULT\naws_secret_access_key = DEFAULTSECRET\n\n" +
+                "[ddns]\naws_access_key_id=DDNS\naws_secret_access_key=DDNSSECRET\naws_session_token = TOKEN\n"
+        if err := os.WriteFile(path, []byte(shared), 0600); err != nil {
+                t.Fatal(err)
+        }
+        t.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+        t.Setenv("AWS_ACCESS_KEY_ID", "")
+        t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+        t.Setenv("AWS_SESSION_TOKEN", "")
+        t.Setenv("AWS_PROFILE", "")
+
+        creds, err := loadAWSCredentials(nil)
+        if err != nil || creds.AccessKeyID != "DEFAULT" || creds.SecretAccessKey != "DEFAULTSECRET" {
+                t.Fatalf("default profile = %+v, %v", creds, err)
+        }
+        t.Setenv("AWS_PROFILE", "ddns")
+        if creds, err = loadAWSCredentials(&Route53Config{}); err != nil || creds.AccessKeyID != "DDNS" || creds.SessionToken != "TOKEN" {
+                t.Fatalf("AWS_PROFILE = %+v, %v", creds, err)
+        }
+        if _, err = loadAWSCredentials(&Route53Config{Profile: "missing"}); err == nil || !strings.Contains(err.Error(), "profile 'missing' not found") {
+                t.Fatalf("missing profile error = %v", err)
+        }
+
+        // 环境变量优先于共享凭据文件, 配置文件又优先于环境变量
+        t.Setenv("AWS_ACCESS_KEY_ID", "ENV")
+        t.Setenv("AWS_SECRET_ACCESS_KEY", "ENVSECRET")
+        if creds, _ = loadAWSCredentials(&Route53Config{Profile: "ddns"}); creds.AccessKeyID != "ENV" || creds.Source != "environment" {
+                t.Fatalf("environment = %+v", creds)
+        }
+        if creds, _ = loadAWSCredentials(&Route53Config{AccessKeyID: "CONFIG", SecretAccessKey: "S"}); creds.AccessKeyID != "CONFIG" || creds.Source != "config file" {
+                t.Fatalf("config file = %+v", creds)
+        }
+        if _, err := loadAWSCredentials(&Route53Config{AccessKeyID: "CONFIG"}); err == nil {
+                t.Fatal("access_key_id without secret_access_key should fail")
+        }
+
+        t.Setenv("AWS_ACCESS_KEY_ID", "")
+        t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "missing"))
+        if _, err := (&Route53Config{}).validate(); err == nil {
+                t.Fatal("validate should fail without any credentials")
+        }
+}
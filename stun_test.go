@@ -0,0 +1,224 @@
+package main
+
+import (
+        "context"
+        "encoding/binary"
+        "net"
+        "strings"
+        "sync/atomic"
+        "testing"
+        "time"
+)
+
+// stunResponder 是测试用的进程内 STUN 服务器; reply 返回对第 n 个请求 (从 1 开始) 要发送的报文
+type stunResponder struct {
+        addr     string
+        requests atomic.Int32
+}
+
+func startSTUNResponder(t *testing.T, network string, reply func(n int, req []byte) [][]byte) *stunResponder {
+        t.Helper()
+        host := "127.0.0.1"
+        if network == "udp6" {
+                host = "::1"
+        }
+        pc, err := net.ListenPacket(network, net.JoinHostPort(host, "0"))
+        if err != nil {
+                t.Skipf("cannot listen on %s: %v", network, err)
+        }
+        t.Cleanup(func() { pc.Close() })
+        s := &stunResponder{addr: pc.LocalAddr().String()}
+        go func() {
+                buf := make([]byte, 1500)
+                for {
+                        n, from, err := pc.ReadFrom(buf)
+                        if err != nil {
+                                return
+                        }
+                        req := append([]byte(nil), buf[:n]...)
+                        for _, msg := range reply(int(s.requests.Add(1)), req) {
+                                pc.WriteTo(msg, from)
+                        }
+                }
+        }()
+        return s
+}
+
+// stunMessage 构造一个与 req 同事务的 STUN 报文
+func stunMessage(req []byte, msgType uint16, attrs ...[]byte) []byte {
+        msg := make([]byte, stunHeaderSize)
+        binary.BigEndian.PutUint16(msg[0:], msgType)
+        binary.BigEndian.PutUint32(msg[4:], stunMagicCookie)
+        copy(msg[8:20], req[8:20])
+        for _, attr := range attrs {
+                msg = append(msg, attr...)
+                for len(msg)%4 != 0 {
+                        msg = append(msg, 0)
+                }
+        }
+        binary.BigEndian.PutUint16(msg[2:], uint16(len(msg)-stunHeaderSize))
+        return msg
+}
+
+// stunAddressAttr 编码 (XOR-)MAPPED-ADDRESS 属性; XOR 类型使用 req 的魔术字和事务 ID 异或
+func stunAddressAttr(req []byte, attrType uint16, ip string) []byte {
+        addr := net.ParseIP(ip)
+        family := byte(0x02)
+        if v4 := addr.To4(); v4 != nil {
+                addr, family = v4, 0x01
+        }
+        value := append([]byte{0, family, 0x12, 0x34}, addr...)
+        if attrType != stunAttrMapped {
+                for i := range addr {
+                        value[4+i] ^= req[4+i]
+                }
+        }
+        attr := binary.BigEndian.AppendUint16(nil, attrType)
+        attr = binary.BigEndian.AppendUint16(attr, uint16(len(value)))
+        return append(attr, value...)
+}
+
+// stunErrorAttr 编码 ERROR-CODE 属性
+func stunErrorAttr(code int, reason string) []byte {
+        value := append([]byte{0, 0, byte(code / 100), byte(code % 100)}, reason...)
+        attr := binary.BigEndian.AppendUint16(nil, stunAttrErrorCode)
+        attr = binary.BigEndian.AppendUint16(attr, uint16(len(value)))
+        return append(attr, value...)
+}
+
+func TestSTUNSource(t *testing.T) {
+        tests := []struct {
+                name    string
+                network string
+                reply   func(n int, req []byte) [][]byte
+                want    string
+                wantErr string
+        }{
+                {
+                        name:    "xor mapped address",
+                        network: "udp4",
+                        reply: func(n int, req []byte) [][]byte {
+                                return [][]byte{stunMessage(req, stunBindingSuccess,
+                                        stunAddressAttr(req, stunAttrMapped, "10.0.0.1"),
+                                        stunAddressAttr(req, stunAttrXORMapped, "8.8.4.4"))}
+                        },
+                        want: "8.8.4.4",
+                },
+                {
+                        name:    "legacy mapped address",
+                        network: "udp4",
+                        reply: func(n int, req []byte) [][]byte {
+                                return [][]byte{stunMessage(req, stunBindingSuccess, stunAddressAttr(req, stunAttrMapped, "9.9.9.9"))}
+                        },
+                        want: "9.9.9.9",
+                },
+                {
+                        name:    "ipv6 xor mapped address",
+                        network: "udp6",
+                        reply: func(n int, req []byte) [][]byte {
+                                return [][]byte{stunMessage(req, stunBindingSuccess, stunAddressAttr(req, stunAttrXORMapped, "2606:4700:4700::1111"))}
+                        },
+                        want: "2606:4700:4700::1111",
+                },
+                {
+                        name:    "stray transaction ignored",
+                        network: "udp4",
+                        reply: func(n int, req []byte) [][]byte {
+                                other := append([]byte(nil), req...)
+                                other[19] ^= 0xff
+                                return [][]byte{
+                                        stunMessage(other, stunBindingSuccess, stunAddressAttr(other, stunAttrXORMapped, "1.2.3.4")),
+                                        stunMessage(req, stunBindingSuccess, stunAddressAttr(req, stunAttrXORMapped, "8.8.8.8")),
+                                }
+                        },
+                        want: "8.8.8.8",
+                },
+                {
+                        name:    "lost request retransmitted",
+                        network: "udp4",
+                        reply: func(n int, req []byte) [][]byte {
+                                if n == 1 {
+                                        return nil
+                                }
+                                return [][]byte{stunMessage(req, stunBindingSuccess, stunAddressAttr(req, stunAttrXORMapped, "1.1.1.1"))}
+                        },
+                        want: "1.1.1.1",
+                },
+                {
+                        name:    "error response",
+                        network: "udp4",
+                        reply: func(n int, req []byte) [][]byte {
+                                return [][]byte{stunMessage(req, stunBindingError, stunErrorAttr(420, "Unknown Attribute"))}
+                        },
+                        wantErr: "STUN error 420: Unknown Attribute",
+                },
+                {
+                        name:    "no address",
+                        network: "udp4",
+                        reply: func(n int, req []byte) [][]byte {
+                                return [][]byte{stunMessage(req, stunBindingSuccess)}
+                        },
+                        wantErr: "no mapped address",
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        srv := startSTUNResponder(t, tt.network, func(n int, req []byte) [][]byte {
+                                if binary.BigEndian.Uint16(req[0:]) != stunBindingRequest || binary.BigEndian.Uint32(req[4:]) != stunMagicCookie {
+                                        t.Errorf("not a binding request: %x", req)
+                                        return nil
+                                }
+                                return tt.reply(n, req)
+                        })
+                        src, err := newSTUNSource(IPSourceConfig{Type: "stun", Servers: []string{srv.addr}}, 3*time.Second)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        ipversion := "ipv4"
+                        if tt.network == "udp6" {
+                                ipversion = "ipv6"
+                        }
+                        got, err := detectFromSource(context.Background(), src, ipversion)
+                        if tt.wantErr != "" {
+                                if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                                        t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+                                }
+                                return
+                        }
+                        if err != nil || got != tt.want {
+                                t.Fatalf("got %q, %v; want %q", got, err, tt.want)
+                        }
+                })
+        }
+}
+
+func TestSTUNSourceTriesNextServer(t *testing.T) {
+        dead := startSTUNResponder(t, "udp4", func(n int, req []byte) [][]byte {
+                return [][]byte{stunMessage(req, stunBindingError, stunErrorAttr(500, "Server Error"))}
+        })
+        live := startSTUNResponder(t, "udp4", func(n int, req []byte) [][]byte {
+                return [][]byte{stunMessage(req, stunBindingSuccess, stunAddressAttr(req, stunAttrXORMapped, "8.8.4.4"))}
+        })
+        src, err := newSTUNSource(IPSourceConfig{Type: "stun", Servers: []string{dead.addr, live.addr}}, 3*time.Second)
+        if err != nil {
+                t.Fatal(err)
+        }
+        got, err := src.Detect(context.Background(), "ipv4")
+        if err != nil || got != "8.8.4.4" {
+                t.Fatalf("got %q, %v; want 8.8.4.4", got, err)
+        }
+        if dead.requests.Load() != 1 || live.requests.Load() != 1 {
+                t.Fatalf("requests = %d, %d; want 1, 1", dead.requests.Load(), live.requests.Load())
+        }
+}
+
+func TestNewSTUNSourceDefaultPort(t *testing.T) {
+        src, err := newSTUNSource(IPSourceConfig{Servers: []string{"stun.example.net", "[2001:db8::1]", "198.51.100.1:19302"}}, time.Second)
+        if err != nil {
+                t.Fatal(err)
+        }
+        want := "stun.example.net:3478,[2001:db8::1]:3478,198.51.100.1:19302"
+        if got := strings.Join(src.servers, ","); got != want {
+                t.Fatalf("servers = %s, want %s", got, want)
+        }
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+        "fmt"
+        "log"
+        "net"
+        "os"
+        "path/filepath"
+        "strconv"
+        "strings"
+        "sync"
+        "time"
+)
+
+// systemd 集成: sd_notify 状态通知 (Type=notify, WatchdogSec=) 和 LoadCredential= 凭据
+// 协议很简单, 直接通过 $NOTIFY_SOCKET 上的 unixgram 套接字实现, 不依赖 libsystemd
+
+// apiTokenCredential 是 $CREDENTIALS_DIRECTORY 中 API Token 凭据的文件名 (LoadCredential=api_token:...)
+const apiTokenCredential = "api_token"
+
+// sdNotify 向 systemd 发送状态通知 (如 "READY=1", "STATUS=..."); 不是由 systemd 启动时什么都不做
+func sdNotify(state string) error {
+        socket := os.Getenv("NOTIFY_SOCKET")
+        if socket == "" {
+                return nil
+        }
+        if socket[0] == '@' {
+                socket = "\x00" + socket[1:] // Abstract namespace socket
+        }
+        conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+        if err != nil {
+                return fmt.Errorf("connecting to notify socket failed: %w", err)
+        }
+        defer conn.Close()
+        if _, err := conn.Write([]byte(state)); err != nil {
+                return fmt.Errorf("sending notification failed: %w", err)
+        }
+        return nil
+}
+
+// sdWatchdogInterval 返回 watchdog ping 的间隔 (WatchdogSec 的一半); 未启用 watchdog 时返回 0
+func sdWatchdogInterval() time.Duration {
+        usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+        if err != nil || usec <= 0 {
+                return 0
+        }
+        if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+                return 0 // The watchdog is meant for another process
+        }
+        return time.Duration(usec) * time.Microsecond / 2
+}
+
+// watchdog 在启用 WatchdogSec= 时定期发送 WATCHDOG=1, 但只在检查循环正常时发送:
+// 一次检查运行超过 limit (如卡在没有响应的 API 或 IP 来源上) 后停止 ping, 由 systemd 重启服务
+type watchdog struct {
+        interval time.Duration
+        limit    time.Duration
+
+        mu         sync.Mutex
+        checkStart time.Time // 正在进行的检查的开始时间, 没有检查时为零值
+        stalled    bool      // 已经记录过检查超时的警告
+
+        done    chan struct{}
+        stopped chan struct{}
+}
+
+// startWatchdog 启动 watchdog goroutine, 每隔 sdWatchdogInterval (WatchdogSec 的一半) 检查一次;
+// 检查的时间上限也是这个间隔, 因此最迟在卡住后约 1.5 个 WatchdogSec 内被重启. 未启用 watchdog 时返回 nil
+func startWatchdog() *watchdog {
+        interval := sdWatchdogInterval()
+        if interval <= 0 {
+                return nil
+        }
+        log.Printf("[%s] ℹ️ systemd watchdog enabled, pinging every %s while checks finish within %s.", time.Now().Format("2006-01-02 15:04:05"), interval, interval)
+        w := &watchdog{interval: interval, limit: interval, done: make(chan struct{}), stopped: make(chan struct{})}
+        go func() {
+                defer close(w.stopped)
+                ticker := time.NewTicker(interval)
+                defer ticker.Stop()
+                for {
+                        select {
+                        case now := <-ticker.C:
+                                if w.healthy(now) {
+                                        notifySystemd("WATCHDOG=1")
+                                }
+                        case <-w.done:
+                                return
+                        }
+                }
+        }()
+        return w
+}
+
+// beginCheck 记录一次检查开始 (进度心跳)
+func (w *watchdog) beginCheck() {
+        if w == nil {
+                return
+        }
+        w.mu.Lock()
+        defer w.mu.Unlock()
+        w.checkStart, w.stalled = time.Now(), false
+}
+
+// endCheck 记录检查结束
+func (w *watchdog) endCheck() {
+        if w == nil {
+                return
+        }
+        w.mu.Lock()
+        defer w.mu.Unlock()
+        w.checkStart = time.Time{}
+}
+
+// healthy 报告此刻是否应当发送 ping: 没有正在进行的检查, 或者检查运行的时间还没有超过 limit
+func (w *watchdog) healthy(now time.Time) bool {
+        w.mu.Lock()
+        defer w.mu.Unlock()
+        if w.checkStart.IsZero() || now.Sub(w.checkStart) < w.limit {
+                return true
+        }
+        if !w.stalled {
+                w.stalled = true
+                log.Printf("[%s] ⚠️ Warning: The current check has been running for more than %s, no longer pinging the systemd watchdog.", time.Now().Format("2006-01-02 15:04:05"), w.limit)
+        }
+        return false
+}
+
+// stop 停止发送 ping
+func (w *watchdog) stop() {
+        if w == nil {
+                return
+        }
+        close(w.done)
+        <-w.stopped
+}
+
+// readCredential 读取 systemd 传入的凭据 ($CREDENTIALS_DIRECTORY/<name>)
+// 没有设置 CREDENTIALS_DIRECTORY 或凭据不存在时返回 false
+func readCredential(name string) (string, bool, error) {
+        dir := os.Getenv("CREDENTIALS_DIRECTORY")
+        if dir == "" {
+                return "", false, nil
+        }
+        data, err := os.ReadFile(filepath.Join(dir, name))
+        if os.IsNotExist(err) {
+                return "", false, nil
+        }
+        if err != nil {
+                return "", false, fmt.Errorf("reading credential '%s' failed: %w", name, err)
+        }
+        return strings.TrimSpace(string(data)), true, nil
+}
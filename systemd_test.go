@@ -0,0 +1,224 @@
+package main
+
+import (
+        "context"
+        "net"
+        "os"
+        "path/filepath"
+        "strconv"
+        "strings"
+        "sync"
+        "testing"
+        "time"
+)
+
+// notifyListener 是一个本地 unixgram 套接字, 代替 systemd 接收 sd_notify 消息
+type notifyListener struct {
+        mu       sync.Mutex
+        messages []string
+}
+
+// listenNotify 创建监听套接字并设置 NOTIFY_SOCKET; abstract 为 true 时使用抽象命名空间 ("@...")
+func listenNotify(t *testing.T, abstract bool) *notifyListener {
+        t.Helper()
+        name := filepath.Join(t.TempDir(), "notify.sock")
+        env := name
+        if abstract {
+                env = "@cloudflare-ddns-test-" + filepath.Base(filepath.Dir(name))
+                name = "\x00" + env[1:]
+        }
+        conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: name, Net: "unixgram"})
+        if err != nil {
+                t.Skipf("cannot listen on unixgram socket: %v", err)
+        }
+        t.Cleanup(func() { conn.Close() })
+        t.Setenv("NOTIFY_SOCKET", env)
+
+        l := &notifyListener{}
+        go func() {
+                buf := make([]byte, 4096)
+                for {
+                        n, err := conn.Read(buf)
+                        if err != nil {
+                                return
+                        }
+                        l.mu.Lock()
+                        l.messages = append(l.messages, string(buf[:n]))
+                        l.mu.Unlock()
+                }
+        }()
+        return l
+}
+
+// all 返回目前收到的全部消息
+func (l *notifyListener) all() []string {
+        l.mu.Lock()
+        defer l.mu.Unlock()
+        return append([]string(nil), l.messages...)
+}
+
+// waitFor 等待一条包含 substr 的消息, 返回它在收到的消息中的序号
+func (l *notifyListener) waitFor(t *testing.T, substr string) int {
+        t.Helper()
+        deadline := time.Now().Add(5 * time.Second)
+        for time.Now().Before(deadline) {
+                for i, msg := range l.all() {
+                        if strings.Contains(msg, substr) {
+                                return i
+                        }
+                }
+                time.Sleep(5 * time.Millisecond)
+        }
+        t.Fatalf("no notification containing %q, got %q", substr, l.all())
+        return -1
+}
+
+func TestSDNotify(t *testing.T) {
+        for _, abstract := range []bool{false, true} {
+                l := listenNotify(t, abstract)
+                if err := sdNotify("READY=1\nSTATUS=hello"); err != nil {
+                        t.Fatal(err)
+                }
+                l.waitFor(t, "READY=1\nSTATUS=hello")
+        }
+
+        t.Setenv("NOTIFY_SOCKET", "")
+        if err := sdNotify("READY=1"); err != nil {
+                t.Fatalf("without NOTIFY_SOCKET sdNotify should do nothing, got %v", err)
+        }
+        t.Setenv("NOTIFY_SOCKET", filepath.Join(t.TempDir(), "missing.sock"))
+        if err := sdNotify("READY=1"); err == nil {
+                t.Fatal("expected an error for a missing socket")
+        }
+}
+
+func TestSDWatchdogInterval(t *testing.T) {
+        tests := []struct {
+                usec, pid string
+                want      time.Duration
+        }{
+                {"", "", 0},
+                {"garbage", "", 0},
+                {"60000000", "", 30 * time.Second},
+                {"60000000", "1", 0}, // another process
+                {"60000000", "self", 30 * time.Second},
+        }
+        for _, tt := range tests {
+                if tt.pid == "self" {
+                        tt.pid = strconv.Itoa(os.Getpid())
+                }
+                t.Setenv("WATCHDOG_USEC", tt.usec)
+                t.Setenv("WATCHDOG_PID", tt.pid)
+                if got := sdWatchdogInterval(); got != tt.want {
+                        t.Errorf("WATCHDOG_USEC=%q WATCHDOG_PID=%q: got %s, want %s", tt.usec, tt.pid, got, tt.want)
+                }
+        }
+}
+
+func TestReadCredential(t *testing.T) {
+        dir := t.TempDir()
+        if err := os.WriteFile(filepath.Join(dir, apiTokenCredential), []byte("secret-token\n"), 0600); err != nil {
+                t.Fatal(err)
+        }
+        t.Setenv("CREDENTIALS_DIRECTORY", dir)
+        if value, ok, err := readCredential(apiTokenCredential); err != nil || !ok || value != "secret-token" {
+                t.Fatalf("got %q, %v, %v", value, ok, err)
+        }
+        if _, ok, err := readCredential("missing"); err != nil || ok {
+                t.Fatalf("missing credential: %v, %v", ok, err)
+        }
+}
+
+// slowProvider 在每次查询时等待一段时间, 模拟缓慢的 API
+type slowProvider struct {
+        *memProvider
+        delay   time.Duration
+        onStart func()
+}
+
+func (p *slowProvider) Lookup(ctx context.Context, zone, fqdn, recordType string) (*Record, error) {
+        p.onStart()
+        time.Sleep(p.delay)
+        return p.memProvider.Lookup(ctx, zone, fqdn, recordType)
+}
+
+func TestRunDaemonNotifications(t *testing.T) {
+        l := listenNotify(t, false)
+        t.Setenv("WATCHDOG_USEC", "100000") // ping every 50ms
+        t.Setenv("WATCHDOG_PID", "")
+
+        var readyBeforeCheck bool
+        p := &slowProvider{memProvider: newMemProvider(), delay: 300 * time.Millisecond}
+        p.onStart = func() {
+                readyBeforeCheck = l.waitFor(t, "READY=1") >= 0
+        }
+        u := newTestUpdater(t, filepath.Join(t.TempDir(), "config.json"), p, "9.9.9.9",
+                recordTarget{FQDN: "example.com", Zone: "example.com", Type: "A", TTL: 300})
+
+        ctx, cancel := context.WithCancel(context.Background())
+        done := make(chan struct{})
+        go func() {
+                runDaemon(ctx, u)
+                close(done)
+        }()
+        status := l.waitFor(t, "last check: 1/1 records OK")
+        for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+                if msgs := l.all(); len(msgs) > status+1 && strings.Contains(strings.Join(msgs[status+1:], "\n"), "WATCHDOG=1") {
+                        break
+                }
+        }
+        cancel()
+        <-done
+        l.waitFor(t, "STOPPING=1")
+
+        messages := l.all()
+        if !readyBeforeCheck || !strings.Contains(messages[0], "READY=1") || !strings.Contains(messages[0], "last successful update never") {
+                t.Fatalf("READY=1 must be sent before the first check, got %q", messages)
+        }
+        // 检查卡住 300ms, 远超 50ms 的上限: 最多只有上限之内的第一次 ping, 检查结束后恢复
+        pings := 0
+        for _, msg := range messages[:status] {
+                if msg == "WATCHDOG=1" {
+                        pings++
+                }
+        }
+        if pings > 1 {
+                t.Fatalf("a check stalled past the limit must stop watchdog pings, got %d in %q", pings, messages)
+        }
+        resumed := false
+        for _, msg := range messages[status:] {
+                resumed = resumed || msg == "WATCHDOG=1"
+        }
+        if !resumed {
+                t.Fatalf("watchdog pings should resume after the check, got %q", messages)
+        }
+        if strings.Contains(messages[status], "last successful update never") {
+                t.Fatalf("STATUS should report the successful update time, got %q", messages[status])
+        }
+}
+
+func TestStatusReportsLastSuccessfulUpdate(t *testing.T) {
+        u := newTestUpdater(t, filepath.Join(t.TempDir(), "config.json"), newMemProvider(), "9.9.9.9",
+                recordTarget{FQDN: "example.com", Zone: "example.com", Type: "A", TTL: 300})
+        u.runOnce(context.Background())
+        updated := u.lastUpdate
+        if updated.IsZero() || !strings.Contains(u.status(), "last successful update "+updated.Format("2006-01-02 15:04:05")) {
+                t.Fatalf("status = %q", u.status())
+        }
+
+        // 检查失败时保留上一次成功更新的时间
+        u.providers["memory"] = &failingProvider{}
+        u.records = make(map[string]*Record)
+        u.ignoreCache = true
+        u.runOnce(context.Background())
+        if !u.lastUpdate.Equal(updated) || !strings.Contains(u.status(), "last check: 0/1 records OK") {
+                t.Fatalf("status = %q", u.status())
+        }
+}
+
+// failingProvider 的每次查询都失败
+type failingProvider struct{ memProvider }
+
+func (*failingProvider) Lookup(ctx context.Context, zone, fqdn, recordType string) (*Record, error) {
+        return nil, context.DeadlineExceeded
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+        "crypto/hmac"
+        "crypto/sha256"
+        "encoding/base64"
+        "encoding/binary"
+        "errors"
+        "fmt"
+        "strings"
+        "time"
+)
+
+// TSIG (RFC 8945) 事务签名, 用于 DNS UPDATE 的身份验证; 只实现 HMAC-SHA256
+
+const (
+        tsigAlgHMACSHA256 = "hmac-sha256."
+        tsigFudge         = 300 // 允许的时钟偏差 (秒)
+)
+
+// tsigKey 是一把 TSIG 共享密钥
+type tsigKey struct {
+        Name   string // 密钥名, 须与服务器上配置的一致
+        Secret []byte
+}
+
+// newTSIGKey 根据密钥名和 base64 编码的密钥创建 TSIG 密钥
+func newTSIGKey(name, secret string) (*tsigKey, error) {
+        key, err := base64.StdEncoding.DecodeString(secret)
+        if err != nil {
+                return nil, fmt.Errorf("TSIG secret is not valid base64: %w", err)
+        }
+        if len(key) == 0 {
+                return nil, errors.New("TSIG secret is empty")
+        }
+        return &tsigKey{Name: canonicalDNSName(name), Secret: key}, nil
+}
+
+// canonicalDNSName 返回小写、以点结尾的域名
+func canonicalDNSName(name string) string {
+        return strings.ToLower(strings.TrimSuffix(name, ".")) + "."
+}
+
+// tsigVariables 编码参与 MAC 计算的 TSIG 变量 (RFC 8945 4.3.3)
+func tsigVariables(keyName string, signed time.Time, fudge, tsigErr uint16, other []byte) ([]byte, error) {
+        b, err := appendDNSName(nil, keyName)
+        if err != nil {
+                return nil, err
+        }
+        b = binary.BigEndian.AppendUint16(b, dnsClassANY)
+        b = binary.BigEndian.AppendUint32(b, 0) // TTL
+        if b, err = appendDNSName(b, tsigAlgHMACSHA256); err != nil {
+                return nil, err
+        }
+        b = appendUint48(b, uint64(signed.Unix()))
+        b = binary.BigEndian.AppendUint16(b, fudge)
+        b = binary.BigEndian.AppendUint16(b, tsigErr)
+        b = binary.BigEndian.AppendUint16(b, uint16(len(other)))
+        return append(b, other...), nil
+}
+
+func appendUint48(b []byte, v uint64) []byte {
+        return append(b, byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// sign 为已编码的报文追加 TSIG 记录, 返回签名后的报文和请求 MAC (校验响应时需要)
+func (k *tsigKey) sign(msg []byte, now time.Time) ([]byte, []byte, error) {
+        if len(msg) < 12 {
+                return nil, nil, errors.New("DNS message too short")
+        }
+        vars, err := tsigVariables(k.Name, now, tsigFudge, 0, nil)
+        if err != nil {
+                return nil, nil, err
+        }
+        mac := hmac.New(sha256.New, k.Secret)
+        mac.Write(msg)
+        mac.Write(vars)
+        sum := mac.Sum(nil)
+
+        rdata, _ := appendDNSName(nil, tsigAlgHMACSHA256)
+        rdata = appendUint48(rdata, uint64(now.Unix()))
+        rdata = binary.BigEndian.AppendUint16(rdata, tsigFudge)
+        rdata = binary.BigEndian.AppendUint16(rdata, uint16(len(sum)))
+        rdata = append(rdata, sum...)
+        rdata = append(rdata, msg[0], msg[1])           // Original ID
+        rdata = binary.BigEndian.AppendUint16(rdata, 0) // Error
+        rdata = binary.BigEndian.AppendUint16(rdata, 0) // Other Len
+
+        signed := append([]byte(nil), msg...)
+        binary.BigEndian.PutUint16(signed[10:], binary.BigEndian.Uint16(signed[10:])+1) // ARCOUNT
+        if signed, err = appendDNSRR(signed, dnsRR{Name: k.Name, Type: dnsTypeTSIG, Class: dnsClassANY, Data: rdata}); err != nil {
+                return nil, nil, err
+        }
+        return signed, sum, nil
+}
+
+// tsigRecord 是解析后的 TSIG RDATA
+type tsigRecord struct {
+        Algorithm  string
+        TimeSigned uint64
+        Fudge      uint16
+        MAC        []byte
+        OriginalID uint16
+        Error      uint16
+        Other      []byte
+}
+
+// parseTSIG 解析 TSIG 记录的 RDATA
+func parseTSIG(data []byte) (*tsigRecord, error) {
+        alg, off, err := readDNSName(data, 0)
+        if err != nil {
+                return nil, err
+        }
+        if off+10 > len(data) {
+                return nil, errors.New("TSIG record truncated")
+        }
+        t := &tsigRecord{Algorithm: strings.ToLower(alg)}
+        for _, x := range data[off : off+6] {
+                t.TimeSigned = t.TimeSigned<<8 | uint64(x)
+        }
+        t.Fudge = binary.BigEndian.Uint16(data[off+6:])
+        macLen := int(binary.BigEndian.Uint16(data[off+8:]))
+        off += 10
+        if off+macLen+6 > len(data) {
+                return nil, errors.New("TSIG record truncated")
+        }
+        t.MAC = data[off : off+macLen]
+        off += macLen
+        t.OriginalID = binary.BigEndian.Uint16(data[off:])
+        t.Error = binary.BigEndian.Uint16(data[off+2:])
+        otherLen := int(binary.BigEndian.Uint16(data[off+4:]))
+        off += 6
+        if off+otherLen > len(data) {
+                return nil, errors.New("TSIG record truncated")
+        }
+        t.Other = data[off : off+otherLen]
+        return t, nil
+}
+
+// verify 校验响应的 TSIG 签名, requestMAC 为对应请求的 MAC
+func (k *tsigKey) verify(resp []byte, requestMAC []byte, now time.Time) error {
+        m, err := parseDNSMessage(resp)
+        if err != nil {
+                return err
+        }
+        if len(m.Additional) == 0 || m.Additional[len(m.Additional)-1].Type != dnsTypeTSIG {
+                return fmt.Errorf("response is not signed (%s)", rcodeName(m.Rcode()))
+        }
+        off, err := lastRROffset(resp)
+        if err != nil {
+                return err
+        }
+        rr := m.Additional[len(m.Additional)-1]
+        t, err := parseTSIG(rr.Data)
+        if err != nil {
+                return err
+        }
+        if canonicalDNSName(rr.Name) != k.Name {
+                return fmt.Errorf("response is signed with unexpected key '%s'", rr.Name)
+        }
+        if t.Error != 0 {
+                return fmt.Errorf("server rejected the TSIG signature: %s", rcodeName(int(t.Error)))
+        }
+        if t.Algorithm != tsigAlgHMACSHA256 {
+                return fmt.Errorf("response is signed with unsupported algorithm '%s'", t.Algorithm)
+        }
+
+        // The MAC covers the request MAC, the response without its TSIG record and the TSIG variables
+        body := append([]byte(nil), resp[:off]...)
+        binary.BigEndian.PutUint16(body[0:], t.OriginalID)
+        binary.BigEndian.PutUint16(body[10:], binary.BigEndian.Uint16(body[10:])-1) // ARCOUNT
+        vars, err := tsigVariables(k.Name, time.Unix(int64(t.TimeSigned), 0), t.Fudge, t.Error, t.Other)
+        if err != nil {
+                return err
+        }
+        mac := hmac.New(sha256.New, k.Secret)
+        mac.Write(binary.BigEndian.AppendUint16(nil, uint16(len(requestMAC))))
+        mac.Write(requestMAC)
+        mac.Write(body)
+        mac.Write(vars)
+        if !hmac.Equal(mac.Sum(nil), t.MAC) {
+                return errors.New("response TSIG signature does not match")
+        }
+        if skew := now.Unix() - int64(t.TimeSigned); skew > int64(t.Fudge) || -skew > int64(t.Fudge) {
+                return fmt.Errorf("response TSIG time is off by %ds (check the clock)", skew)
+        }
+        return nil
+}
+
+// lastRROffset 返回报文中最后一条资源记录的起始偏移 (TSIG 记录总在最后)
+func lastRROffset(msg []byte) (int, error) {
+        if len(msg) < 12 {
+                return 0, errors.New("DNS message too short")
+        }
+        off := 12
+        for i := 0; i < int(binary.BigEndian.Uint16(msg[4:])); i++ {
+                _, next, err := readDNSName(msg, off)
+                if err != nil {
+                        return 0, err
+                }
+                off = next + 4
+        }
+        total := int(binary.BigEndian.Uint16(msg[6:])) + int(binary.BigEndian.Uint16(msg[8:])) + int(binary.BigEndian.Uint16(msg[10:]))
+        last := -1
+        for i := 0; i < total; i++ {
+                last = off
+                _, next, err := readDNSName(msg, off)
+                if err != nil {
+                        return 0, err
+                }
+                if next+10 > len(msg) {
+                        return 0, errors.New("DNS resource record truncated")
+                }
+                off = next + 10 + int(binary.BigEndian.Uint16(msg[next+8:]))
+        }
+        if last < 0 || off > len(msg) {
+                return 0, errors.New("DNS message has no resource records")
+        }
+        return last, nil
+}
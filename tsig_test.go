@@ -0,0 +1,167 @@
+package main
+
+import (
+        "bytes"
+        "crypto/hmac"
+        "crypto/sha256"
+        "encoding/base64"
+        "encoding/binary"
+        "errors"
+        "strings"
+        "testing"
+        "time"
+)
+
+// 以下辅助函数按 RFC 8945 独立实现服务器一侧的签名和校验, 不复用 tsig.go 的代码
+
+// testTSIGVariables 编码参与 MAC 计算的 TSIG 变量: 密钥名, 类别 ANY, TTL 0, 算法名, 时间, 偏差, 错误码, 空的 Other Data
+func testTSIGVariables(keyName string, signed int64, tsigErr uint16) []byte {
+        name, _ := appendDNSName(nil, strings.ToLower(keyName))
+        b := append(name, 0, 255, 0, 0, 0, 0)
+        b = append(b, "\x0bhmac-sha256\x00"...)
+        b = append(b, byte(signed>>40), byte(signed>>32), byte(signed>>24), byte(signed>>16), byte(signed>>8), byte(signed))
+        b = binary.BigEndian.AppendUint16(b, tsigFudge)
+        b = binary.BigEndian.AppendUint16(b, tsigErr)
+        return append(b, 0, 0)
+}
+
+// testTSIGRR 构造 TSIG 记录
+func testTSIGRR(keyName string, signed int64, mac []byte, id, tsigErr uint16) dnsRR {
+        rdata := append([]byte("\x0bhmac-sha256\x00"), byte(signed>>40), byte(signed>>32), byte(signed>>24), byte(signed>>16), byte(signed>>8), byte(signed))
+        rdata = binary.BigEndian.AppendUint16(rdata, tsigFudge)
+        rdata = binary.BigEndian.AppendUint16(rdata, uint16(len(mac)))
+        rdata = append(rdata, mac...)
+        rdata = binary.BigEndian.AppendUint16(rdata, id)
+        rdata = binary.BigEndian.AppendUint16(rdata, tsigErr)
+        rdata = append(rdata, 0, 0)
+        return dnsRR{Name: keyName, Type: dnsTypeTSIG, Class: dnsClassANY, Data: rdata}
+}
+
+// checkTestTSIG 校验请求报文末尾的 TSIG 记录, 返回请求 MAC; 报文没有签名时返回 nil, nil
+func checkTestTSIG(keyName string, secret, raw []byte) ([]byte, error) {
+        m, err := parseDNSMessage(raw)
+        if err != nil {
+                return nil, err
+        }
+        if len(m.Additional) == 0 || m.Additional[len(m.Additional)-1].Type != dnsTypeTSIG {
+                return nil, nil
+        }
+        rr := m.Additional[len(m.Additional)-1]
+        if !strings.EqualFold(strings.TrimSuffix(rr.Name, "."), strings.TrimSuffix(keyName, ".")) {
+                return nil, errors.New("unknown key " + rr.Name)
+        }
+        name, _ := appendDNSName(nil, rr.Name)
+        body := append([]byte(nil), raw[:len(raw)-len(name)-10-len(rr.Data)]...)
+        binary.BigEndian.PutUint16(body[10:], binary.BigEndian.Uint16(body[10:])-1)
+
+        algLen := len("\x0bhmac-sha256\x00")
+        if !bytes.Equal(rr.Data[:algLen], []byte("\x0bhmac-sha256\x00")) {
+                return nil, errors.New("unexpected algorithm")
+        }
+        var signed int64
+        for _, x := range rr.Data[algLen : algLen+6] {
+                signed = signed<<8 | int64(x)
+        }
+        macLen := int(binary.BigEndian.Uint16(rr.Data[algLen+8:]))
+        got := rr.Data[algLen+10 : algLen+10+macLen]
+
+        h := hmac.New(sha256.New, secret)
+        h.Write(body)
+        h.Write(testTSIGVariables(keyName, signed, 0))
+        if !hmac.Equal(h.Sum(nil), got) {
+                return nil, errors.New("request MAC mismatch")
+        }
+        return got, nil
+}
+
+// signTestResponse 为响应报文追加 TSIG 记录, MAC 覆盖请求 MAC、响应和 TSIG 变量
+func signTestResponse(keyName string, secret, resp, requestMAC []byte, signed time.Time, tsigErr uint16) []byte {
+        h := hmac.New(sha256.New, secret)
+        h.Write(binary.BigEndian.AppendUint16(nil, uint16(len(requestMAC))))
+        h.Write(requestMAC)
+        h.Write(resp)
+        h.Write(testTSIGVariables(keyName, signed.Unix(), tsigErr))
+        out := append([]byte(nil), resp...)
+        binary.BigEndian.PutUint16(out[10:], binary.BigEndian.Uint16(out[10:])+1)
+        out, _ = appendDNSRR(out, testTSIGRR(keyName, signed.Unix(), h.Sum(nil), binary.BigEndian.Uint16(resp), tsigErr))
+        return out
+}
+
+var testTSIGSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func TestTSIGSign(t *testing.T) {
+        key, err := newTSIGKey("DDNS-Key.Example.", base64.StdEncoding.EncodeToString(testTSIGSecret))
+        if err != nil {
+                t.Fatal(err)
+        }
+        if key.Name != "ddns-key.example." {
+                t.Fatalf("key name = %q", key.Name)
+        }
+        q := newDNSQuery("home.example.org", dnsTypeSOA, dnsClassIN)
+        q.ID = 0x1234
+        msg, _ := q.Pack()
+        now := time.Unix(1700000000, 0)
+
+        signed, mac, err := key.sign(msg, now)
+        if err != nil {
+                t.Fatal(err)
+        }
+        got, err := checkTestTSIG("ddns-key.example", testTSIGSecret, signed)
+        if err != nil || !bytes.Equal(got, mac) {
+                t.Fatalf("request MAC %x, %v; sign returned %x", got, err, mac)
+        }
+        tsig, err := parseTSIG(signed[len(msg)+len("\x08ddns-key\x07example\x00")+10:])
+        if err != nil {
+                t.Fatal(err)
+        }
+        if tsig.TimeSigned != 1700000000 || tsig.Fudge != tsigFudge || tsig.OriginalID != 0x1234 || tsig.Error != 0 {
+                t.Fatalf("unexpected TSIG record %+v", tsig)
+        }
+        // 签名不修改原报文
+        if binary.BigEndian.Uint16(msg[10:]) != 0 || binary.BigEndian.Uint16(signed[10:]) != 1 {
+                t.Fatal("ARCOUNT must only change in the signed copy")
+        }
+}
+
+func TestTSIGVerify(t *testing.T) {
+        key, _ := newTSIGKey("ddns-key", base64.StdEncoding.EncodeToString(testTSIGSecret))
+        requestMAC := bytes.Repeat([]byte{0xab}, sha256.Size)
+        resp, _ := (&dnsMessage{ID: 0x4321, Flags: dnsFlagQR | dnsOpcodeUpdate<<11, Questions: []dnsQuestion{{Name: "example.org", Type: dnsTypeSOA, Class: dnsClassIN}}}).Pack()
+        now := time.Now()
+
+        if err := key.verify(signTestResponse("ddns-key", testTSIGSecret, resp, requestMAC, now, 0), requestMAC, now); err != nil {
+                t.Fatalf("valid response rejected: %v", err)
+        }
+
+        tampered := signTestResponse("ddns-key", testTSIGSecret, resp, requestMAC, now, 0)
+        tampered[3] |= 5 // REFUSED
+        tests := []struct {
+                name    string
+                resp    []byte
+                wantErr string
+        }{
+                {"tampered", tampered, "signature does not match"},
+                {"other request", signTestResponse("ddns-key", testTSIGSecret, resp, requestMAC[1:], now, 0), "signature does not match"},
+                {"wrong secret", signTestResponse("ddns-key", []byte("secret"), resp, requestMAC, now, 0), "signature does not match"},
+                {"wrong key", signTestResponse("other-key", testTSIGSecret, resp, requestMAC, now, 0), "unexpected key 'other-key.'"},
+                {"clock skew", signTestResponse("ddns-key", testTSIGSecret, resp, requestMAC, now.Add(-time.Hour), 0), "time is off by 3600s"},
+                {"BADSIG", signTestResponse("ddns-key", testTSIGSecret, resp, nil, now, 16), "server rejected the TSIG signature: BADSIG"},
+                {"unsigned", resp, "response is not signed (NOERROR)"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        err := key.verify(tt.resp, requestMAC, now)
+                        if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                                t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+                        }
+                })
+        }
+}
+
+func TestNewTSIGKeyValidation(t *testing.T) {
+        for _, secret := range []string{"", "not base64!"} {
+                if _, err := newTSIGKey("ddns-key", secret); err == nil {
+                        t.Errorf("newTSIGKey(%q) should fail", secret)
+                }
+        }
+}